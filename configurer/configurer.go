@@ -18,6 +18,7 @@ type Config interface {
 	GetString(string) string
 
 	GetStringMapString(string) map[string]string
+	AllSettings() map[string]interface{}
 
 	SetConfigName(string)
 	SetConfigType(string)
@@ -63,13 +64,25 @@ type DataPoint struct {
 
 // LoadConfig loads a configuration file specified by the function argument or a path provided the command line.
 func LoadConfig(configFile string, prefix string) Config {
-	var err error
+	var (
+		err        error
+		rootConfig Config
+	)
 
+	if rootConfig, err = ReadConfig(configFile, prefix); err != nil {
+		fmt.Printf("[error] configuration file could not be read: %v", err)
+		const exitCode = 1
+		os.Exit(exitCode)
+	}
+
+	return rootConfig
+}
+
+// ReadConfig reads a configuration file in the same way as LoadConfig, but returns any error to the caller.
+func ReadConfig(configFile string, prefix string) (rootConfig Config, err error) {
 	var configPath string
 	var configName string
 
-	var rootConfig Config
-
 	if configFile == "" {
 		configName = "config.yaml"
 		configPath = os.Getenv("GOPATH") + "/src/github.com/cambridge-blockchain/emf"
@@ -88,10 +101,8 @@ func LoadConfig(configFile string, prefix string) Config {
 	rootConfig.AutomaticEnv()
 
 	if err = rootConfig.ReadInConfig(); err != nil {
-		fmt.Printf("[error] configuration file could not be read: %v", err)
-		const exitCode = 1
-		os.Exit(exitCode)
+		return nil, err
 	}
 
-	return rootConfig
+	return rootConfig, nil
 }
@@ -0,0 +1,252 @@
+package configurer
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// maxDiffValue is the length after which values are truncated in a reload diff
+const maxDiffValue = 64
+
+// reloadDebounce is how long Watch waits for the config file to stop changing before reading it, as a single
+// save often raises several events
+const reloadDebounce = 100 * time.Millisecond
+
+// Store holds the current Config of a service and swaps it atomically when the config file is reloaded.
+// Store implements ConfigReader and DomainsReader by reading from the current Config, so any component
+// holding the Store sees reloaded values on its next read.
+type Store struct {
+	current atomic.Value
+
+	mu          sync.Mutex
+	validators  []namedFunc
+	subscribers []namedFunc
+	stopped     bool
+	debounce    time.Duration
+	pending     *time.Timer
+	logf        func(format string, args ...interface{})
+}
+
+type namedFunc struct {
+	name string
+	f    func(next Config) error
+}
+
+type configHolder struct{ Config }
+
+// NewStore creates a Store holding conf
+func NewStore(conf Config) *Store {
+	var s = &Store{debounce: reloadDebounce, logf: func(string, ...interface{}) {}}
+	s.current.Store(configHolder{conf})
+	return s
+}
+
+// Get returns the current Config
+func (s *Store) Get() Config {
+	return s.current.Load().(configHolder).Config
+}
+
+// GetInt reads from the current Config
+func (s *Store) GetInt(key string) int { return s.Get().GetInt(key) }
+
+// GetBool reads from the current Config
+func (s *Store) GetBool(key string) bool { return s.Get().GetBool(key) }
+
+// GetInt64 reads from the current Config
+func (s *Store) GetInt64(key string) int64 { return s.Get().GetInt64(key) }
+
+// GetString reads from the current Config
+func (s *Store) GetString(key string) string { return s.Get().GetString(key) }
+
+// GetStringMapString reads from the current Config
+func (s *Store) GetStringMapString(key string) map[string]string {
+	return s.Get().GetStringMapString(key)
+}
+
+// AllSettings reads from the current Config
+func (s *Store) AllSettings() map[string]interface{} { return s.Get().AllSettings() }
+
+// UnmarshalKey reads from the current Config
+func (s *Store) UnmarshalKey(key string, rawVal interface{}, opts ...viper.DecoderConfigOption) error {
+	return s.Get().UnmarshalKey(key, rawVal, opts...)
+}
+
+// Validate registers a check run against every reloaded Config before it is swapped in.
+// A failing check rejects the reload and the current Config is kept.
+func (s *Store) Validate(name string, f func(next Config) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators = append(s.validators, namedFunc{name: name, f: f})
+}
+
+// Subscribe registers a callback run after a reloaded Config has been swapped in, in registration order.
+// A failing subscriber is logged, but does not stop the others.
+func (s *Store) Subscribe(name string, f func(next Config) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, namedFunc{name: name, f: f})
+}
+
+// Reload validates next, swaps it in and notifies the subscribers.
+// It returns an error, and keeps the current Config, if any validator rejects next.
+func (s *Store) Reload(next Config) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes = Diff(s.Get(), next)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, v := range s.validators {
+		if err = v.f(next); err != nil {
+			s.logf("Config reload rejected by '%s': %s. Changes: %s", v.name, err, strings.Join(changes, ", "))
+			return fmt.Errorf("config reload rejected by '%s': %w", v.name, err)
+		}
+	}
+
+	s.current.Store(configHolder{next})
+	s.logf("Config reloaded. Changes: %s", strings.Join(changes, ", "))
+
+	for _, sub := range s.subscribers {
+		if subErr := sub.f(next); subErr != nil {
+			s.logf("Config subscriber '%s' failed to apply the reloaded config: %s", sub.name, subErr)
+		}
+	}
+	return nil
+}
+
+// Watch reloads the config file whenever it changes on disk, using viper's file watching.
+// Changes are debounced, and the file is then read from scratch into a new Config, so a partially written or
+// invalid file never replaces the current Config. logf receives the reload diffs and failures and may be nil.
+// Watch should only be called once.
+func (s *Store) Watch(prefix string, logf func(format string, args ...interface{})) error {
+	var file string
+	if used, ok := s.Get().(interface{ ConfigFileUsed() string }); ok {
+		file = used.ConfigFileUsed()
+	}
+	if file == "" {
+		return fmt.Errorf("the config file to watch is unknown")
+	}
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("the config file to watch could not be found: %w", err)
+	}
+
+	s.mu.Lock()
+	if logf != nil {
+		s.logf = logf
+	}
+	s.stopped = false
+	s.mu.Unlock()
+
+	// viper watches the file through its own instance, every change is then re-read independently
+	var watcher = viper.New()
+	watcher.SetConfigFile(file)
+	watcher.OnConfigChange(func(fsnotify.Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.stopped {
+			return
+		}
+
+		// Every event restarts the delay, so the file is read once it stopped changing
+		if s.pending != nil {
+			s.pending.Stop()
+		}
+		s.pending = time.AfterFunc(s.debounce, func() { s.reloadFile(file, prefix) })
+	})
+	watcher.WatchConfig()
+	return nil
+}
+
+// reloadFile reads the watched config file and reloads it, unless the Store was stopped meanwhile
+func (s *Store) reloadFile(file, prefix string) {
+	s.mu.Lock()
+	var stopped, logf = s.stopped, s.logf
+	s.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	var next, err = ReadConfig(file, prefix)
+	if err != nil {
+		logf("Config reload failed, keeping the current config: %s", err)
+		return
+	}
+	s.Reload(next) //nolint:errcheck // rejections are logged by Reload
+}
+
+// Stop ignores any further change to the config file, including a change waiting for its debounce delay
+func (s *Store) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.pending != nil {
+		s.pending.Stop()
+	}
+}
+
+// Diff lists the settings that differ between two Configs, as "key: old -> new".
+// Values of keys that look like secrets are redacted and long values are truncated.
+func Diff(prev, next Config) (changes []string) {
+	var before, after = map[string]interface{}{}, map[string]interface{}{}
+	flatten(prev.AllSettings(), "", before)
+	flatten(next.AllSettings(), "", after)
+
+	var keys = map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		var b, bok = before[k]
+		var a, aok = after[k]
+		switch {
+		case !bok:
+			changes = append(changes, fmt.Sprintf("%s: added %s", k, diffValue(k, a)))
+		case !aok:
+			changes = append(changes, fmt.Sprintf("%s: removed", k))
+		case !reflect.DeepEqual(a, b):
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, diffValue(k, b), diffValue(k, a)))
+		}
+	}
+	sort.Strings(changes)
+	return
+}
+
+func flatten(settings map[string]interface{}, prefix string, out map[string]interface{}) {
+	for key, val := range settings {
+		if child, ok := val.(map[string]interface{}); ok {
+			flatten(child, prefix+key+".", out)
+			continue
+		}
+		out[prefix+key] = val
+	}
+}
+
+func diffValue(key string, val interface{}) string {
+	var name = strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, secret := range []string{"secret", "password", "private", "token"} {
+		if strings.Contains(name, secret) {
+			return "<redacted>"
+		}
+	}
+
+	var s = fmt.Sprintf("%q", fmt.Sprint(val))
+	if len(s) > maxDiffValue {
+		s = s[:maxDiffValue] + "...\""
+	}
+	return s
+}
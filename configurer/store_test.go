@@ -0,0 +1,217 @@
+package configurer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// recorder collects the log lines and subscriber calls of a Store
+type recorder struct {
+	mu    sync.Mutex
+	lines []string
+	calls []string
+}
+
+func (r *recorder) logf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) subscriber(name string, err error) func(Config) error {
+	return func(next Config) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, name+"="+next.GetString("domains.ledger"))
+		return err
+	}
+}
+
+func (r *recorder) snapshot() (lines, calls []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...), append([]string(nil), r.calls...)
+}
+
+func config(settings map[string]interface{}) Config {
+	var v = viper.New()
+	for k, val := range settings {
+		v.Set(k, val)
+	}
+	return v
+}
+
+func TestStoreReload(t *testing.T) {
+	var r = &recorder{}
+	var s = NewStore(config(map[string]interface{}{"domains.ledger": "http://a", "api.port": "8080"}))
+	s.logf = r.logf
+
+	s.Validate("api", func(next Config) error {
+		if next.GetString("api.port") != "8080" {
+			return errors.New("api.port cannot change")
+		}
+		return nil
+	})
+	s.Subscribe("first", r.subscriber("first", errors.New("failure")))
+	s.Subscribe("second", r.subscriber("second", nil))
+
+	var cases = []struct {
+		name     string
+		settings map[string]interface{}
+		ok       bool
+		ledger   string
+		calls    []string
+	}{
+		{"unchanged", map[string]interface{}{"domains.ledger": "http://a", "api.port": "8080"}, true, "http://a", nil},
+		{"rejected", map[string]interface{}{"domains.ledger": "http://b", "api.port": "9090"}, false, "http://a", nil},
+		// Every subscriber is notified in order, even after one failed
+		{"reloaded", map[string]interface{}{"domains.ledger": "http://b", "api.port": "8080"}, true, "http://b",
+			[]string{"first=http://b", "second=http://b"}},
+	}
+
+	for _, c := range cases {
+		var err = s.Reload(config(c.settings))
+		if c.ok != (err == nil) {
+			t.Errorf("%s: expected success: %v, got %v", c.name, c.ok, err)
+		}
+		if got := s.GetString("domains.ledger"); got != c.ledger {
+			t.Errorf("%s: expected domains.ledger %s, got %s", c.name, c.ledger, got)
+		}
+		var _, calls = r.snapshot()
+		if !reflect.DeepEqual(calls, c.calls) {
+			t.Errorf("%s: expected the subscriber calls %v, got %v", c.name, c.calls, calls)
+		}
+	}
+
+	var lines, _ = r.snapshot()
+	var logged = strings.Join(lines, "\n")
+	for _, want := range []string{"rejected by 'api'", "Config reloaded", "subscriber 'first' failed"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected the log to contain %q, got %s", want, logged)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var prev = config(map[string]interface{}{
+		"domains.ledger":  "http://a",
+		"domains.kyc":     "http://kyc",
+		"auth.secret_key": "old",
+	})
+	var next = config(map[string]interface{}{
+		"domains.ledger":  "http://b",
+		"domains.vault":   strings.Repeat("v", 100),
+		"auth.secret_key": "new",
+	})
+
+	var expected = []string{
+		`auth.secret_key: <redacted> -> <redacted>`,
+		`domains.kyc: removed`,
+		`domains.ledger: "http://a" -> "http://b"`,
+		`domains.vault: added "` + strings.Repeat("v", maxDiffValue-1) + `..."`,
+	}
+	if got := Diff(prev, next); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+// watched writes a config file in a new directory and returns a Store holding it
+func watched(t *testing.T, content string) (s *Store, file string, cleanup func()) {
+	var dir, err = ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf Config
+	if conf, err = ReadConfig(file, "storetest"); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(conf), file, func() { os.RemoveAll(dir) }
+}
+
+// eventually polls cond until it holds or a second has elapsed
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func TestStoreWatch(t *testing.T) {
+	var s, file, cleanup = watched(t, "domains:\n  ledger: http://a\n")
+	defer cleanup()
+
+	var r = &recorder{}
+	s.Subscribe("ledger", r.subscriber("ledger", nil))
+	if err := s.Watch("storetest", r.logf); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// Saves in quick succession are debounced into a single reload of the last content
+	for _, ledger := range []string{"http://b", "http://c", "http://d"} {
+		if err := ioutil.WriteFile(file, []byte("domains:\n  ledger: "+ledger+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !eventually(func() bool { return s.GetString("domains.ledger") == "http://d" }) {
+		t.Fatalf("expected the config to be reloaded, got %s", s.GetString("domains.ledger"))
+	}
+	time.Sleep(2 * reloadDebounce)
+	if _, calls := r.snapshot(); !reflect.DeepEqual(calls, []string{"ledger=http://d"}) {
+		t.Errorf("expected a single reload, got %v", calls)
+	}
+
+	// An invalid file is logged and the current config is kept
+	if err := ioutil.WriteFile(file, []byte("domains: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !eventually(func() bool {
+		var lines, _ = r.snapshot()
+		return strings.Contains(strings.Join(lines, "\n"), "Config reload failed")
+	}) {
+		t.Error("expected the invalid config to be logged")
+	}
+	if got := s.GetString("domains.ledger"); got != "http://d" {
+		t.Errorf("expected the current config to be kept, got %s", got)
+	}
+
+	// Changes are ignored once stopped
+	s.Stop()
+	if err := ioutil.WriteFile(file, []byte("domains:\n  ledger: http://e\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * reloadDebounce)
+	if got := s.GetString("domains.ledger"); got != "http://d" {
+		t.Errorf("expected changes to be ignored after Stop, got %s", got)
+	}
+}
+
+func TestStoreWatchUnknownFile(t *testing.T) {
+	if err := NewStore(viper.New()).Watch("storetest", nil); err == nil {
+		t.Error("expected a Store without a config file to fail to watch")
+	}
+
+	var s, file, cleanup = watched(t, "domains: {}\n")
+	defer cleanup()
+	os.Remove(file)
+	if err := s.Watch("storetest", nil); err == nil {
+		t.Error("expected a missing config file to fail to watch")
+	}
+}
@@ -0,0 +1,215 @@
+// Package apikey authenticates machine-to-machine callers with API keys, as an alternative to JWTs.
+// Keys are never stored: stores hold the hex encoded SHA-256 hash of each key and the Principal it maps to.
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+const (
+	// DefaultHeader is the header read by an Authenticator unless configured otherwise
+	DefaultHeader = "X-API-Key"
+	// Claim is the claim holding the Principal ID in the claims of an API key
+	Claim = "apikey"
+
+	defaultCachePrefix = "emf:apikey:"
+)
+
+// Principal is the identity an API key maps to
+type Principal struct {
+	ID     string                 `json:"id" mapstructure:"id"`
+	Roles  []string               `json:"roles,omitempty" mapstructure:"roles"`
+	Target string                 `json:"target,omitempty" mapstructure:"target"`
+	Scopes []string               `json:"scopes,omitempty" mapstructure:"scopes"`
+	Claims map[string]interface{} `json:"claims,omitempty" mapstructure:"claims"`
+}
+
+// MapClaims returns the principal as JWT claims, so handlers read it with GetClaim like a token:
+// sub and apikey hold the ID, rol the first role, roles every role, tgt the target and scope the scopes
+func (p *Principal) MapClaims() jwt.MapClaims {
+	var claims = jwt.MapClaims{}
+	for k, v := range p.Claims {
+		claims[k] = v
+	}
+
+	claims["sub"] = p.ID
+	claims[Claim] = p.ID
+	if len(p.Roles) > 0 {
+		var roles = make([]interface{}, len(p.Roles))
+		for i, r := range p.Roles {
+			roles[i] = r
+		}
+		claims["rol"] = p.Roles[0]
+		claims["roles"] = roles
+	}
+	if p.Target != "" {
+		claims["tgt"] = p.Target
+	}
+	if len(p.Scopes) > 0 {
+		claims["scope"] = strings.Join(p.Scopes, " ")
+	}
+	return claims
+}
+
+// Store looks up the Principal of a hashed key. It returns nil without an error for unknown keys.
+type Store interface {
+	Lookup(hash string) (*Principal, error)
+}
+
+// StoreFunc is a callback implementing Store
+type StoreFunc func(hash string) (*Principal, error)
+
+// Lookup calls f
+func (f StoreFunc) Lookup(hash string) (*Principal, error) {
+	return f(hash)
+}
+
+// HashedKey is an entry of a config file Store
+type HashedKey struct {
+	Hash      string `mapstructure:"hash"`
+	Principal `mapstructure:",squash"`
+}
+
+// ConfigStore is a Store of keys listed in the config file
+type ConfigStore map[string]Principal
+
+// NewConfigStore indexes hashed keys by hash
+func NewConfigStore(keys []HashedKey) ConfigStore {
+	var cs = ConfigStore{}
+	for _, k := range keys {
+		cs[strings.ToLower(k.Hash)] = k.Principal
+	}
+	return cs
+}
+
+// Lookup returns the Principal of hash
+func (cs ConfigStore) Lookup(hash string) (*Principal, error) {
+	if p, ok := cs[hash]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+// Getter is the subset of cache.Client used by a CacheStore
+type Getter interface {
+	Get(path string) ([]byte, bool, error)
+}
+
+// CacheStore is a Store of the JSON encoded Principals held in a cache.Client under a prefix and the key hash
+type CacheStore struct {
+	client Getter
+	prefix string
+}
+
+// NewCacheStore returns a CacheStore reading client. An empty prefix defaults to "emf:apikey:".
+func NewCacheStore(client Getter, prefix string) *CacheStore {
+	if prefix == "" {
+		prefix = defaultCachePrefix
+	}
+	return &CacheStore{client: client, prefix: prefix}
+}
+
+// Lookup returns the Principal of hash
+func (cs *CacheStore) Lookup(hash string) (*Principal, error) {
+	var data, found, err = cs.client.Get(cs.prefix + hash)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var p Principal
+	if err = json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid API key principal: %w", err)
+	}
+	return &p, nil
+}
+
+// Authenticator reads API keys from a header or a query parameter and looks them up in a Store
+type Authenticator struct {
+	store  Store
+	header string
+	param  string
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for an Authenticator.
+type Option func(*Authenticator)
+
+// WithHeader sets the header holding the key, X-API-Key by default
+func WithHeader(header string) Option {
+	return func(a *Authenticator) {
+		if header != "" {
+			a.header = header
+		}
+	}
+}
+
+// WithQueryParam also reads the key from a query parameter. Query strings are often logged, so
+// keys are only read from the header by default.
+func WithQueryParam(param string) Option {
+	return func(a *Authenticator) { a.param = param }
+}
+
+// New is a variadic constructor for an Authenticator looking keys up in store
+func New(store Store, opts ...Option) *Authenticator {
+	var a = &Authenticator{store: store, header: DefaultHeader}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Configure returns an Authenticator reading the api.api_keys settings, looking keys up in store or else
+// in the api.api_keys.keys list. It returns nil when there is no store and no key is listed.
+func Configure(cfg configurer.ConfigReader, store Store) (*Authenticator, error) {
+	if store == nil {
+		var keys []HashedKey
+		if err := cfg.UnmarshalKey("api.api_keys.keys", &keys); err != nil {
+			return nil, fmt.Errorf("invalid api.api_keys.keys: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		store = NewConfigStore(keys)
+	}
+
+	return New(store,
+		WithHeader(cfg.GetString("api.api_keys.header")),
+		WithQueryParam(cfg.GetString("api.api_keys.query_param")),
+	), nil
+}
+
+// Key returns the API key of a request, or ""
+func (a *Authenticator) Key(r *http.Request) string {
+	if key := r.Header.Get(a.header); key != "" {
+		return key
+	}
+	if a.param != "" {
+		return r.URL.Query().Get(a.param)
+	}
+	return ""
+}
+
+// Authenticate returns the Principal of key, or nil when the key is unknown
+func (a *Authenticator) Authenticate(key string) (*Principal, error) {
+	var p, err = a.store.Lookup(Hash(key))
+	if err != nil {
+		return nil, fmt.Errorf("API key lookup failed: %w", err)
+	}
+	return p, nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of a key, as held by stores
+func Hash(key string) string {
+	var sum = sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,183 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+)
+
+// getter is a cache.Client holding JSON values
+type getter map[string]string
+
+func (g getter) Get(path string) ([]byte, bool, error) {
+	if path == "emf:apikey:broken" {
+		return nil, false, errors.New("connection refused")
+	}
+	var v, ok = g[path]
+	return []byte(v), ok, nil
+}
+
+func TestHash(t *testing.T) {
+	var cases = map[string]string{
+		"abc": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"":    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}
+	for key, expected := range cases {
+		if got := Hash(key); got != expected {
+			t.Errorf("Hash(%q): expected %s, got %s", key, expected, got)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	var store = NewConfigStore([]HashedKey{
+		{Hash: Hash("ledger-key"), Principal: Principal{ID: "ledger"}},
+		// Hashes are compared case-insensitively
+		{Hash: strings.ToUpper(Hash("kyc-key")), Principal: Principal{ID: "kyc"}},
+	})
+
+	var cases = []struct {
+		name string
+		key  string
+		id   string
+	}{
+		{"known key", "ledger-key", "ledger"},
+		{"uppercase hash", "kyc-key", "kyc"},
+		{"unknown key", "other-key", ""},
+		{"hash of a key", Hash("ledger-key"), ""},
+		{"empty key", "", ""},
+	}
+
+	var a = New(store)
+	for _, c := range cases {
+		var p, err = a.Authenticate(c.key)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if c.id == "" && p != nil {
+			t.Errorf("%s: expected no Principal, got %+v", c.name, p)
+		} else if c.id != "" && (p == nil || p.ID != c.id) {
+			t.Errorf("%s: expected the Principal %s, got %+v", c.name, c.id, p)
+		}
+	}
+
+	var failing = New(StoreFunc(func(string) (*Principal, error) { return nil, errors.New("timeout") }))
+	if _, err := failing.Authenticate("ledger-key"); err == nil {
+		t.Error("expected the store error to be returned")
+	}
+}
+
+func TestKey(t *testing.T) {
+	var cases = []struct {
+		name     string
+		opts     []Option
+		header   map[string]string
+		query    string
+		expected string
+	}{
+		{"default header", nil, map[string]string{DefaultHeader: "k1"}, "", "k1"},
+		{"query ignored by default", nil, nil, "api_key=k1", ""},
+		{"custom header", []Option{WithHeader("X-Key")}, map[string]string{"X-Key": "k1", DefaultHeader: "k2"}, "",
+			"k1"},
+		{"query parameter", []Option{WithQueryParam("api_key")}, nil, "api_key=k1", "k1"},
+		{"header first", []Option{WithQueryParam("api_key")}, map[string]string{DefaultHeader: "k1"},
+			"api_key=k2", "k1"},
+	}
+
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodGet, "/accounts?"+c.query, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		if got := New(ConfigStore{}, c.opts...).Key(req); got != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	var v = viper.New()
+	if a, err := Configure(v, nil); a != nil || err != nil {
+		t.Errorf("expected no Authenticator without keys, got %v %v", a, err)
+	}
+
+	v.Set("api.api_keys", map[string]interface{}{
+		"header":      "X-Key",
+		"query_param": "key",
+		"keys": []interface{}{
+			map[string]interface{}{"hash": Hash("ledger-key"), "id": "ledger", "roles": []interface{}{"svc"}},
+		},
+	})
+	var a, err = Configure(v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.header != "X-Key" || a.param != "key" {
+		t.Errorf("expected the configured header and query parameter, got %q %q", a.header, a.param)
+	}
+	if p, _ := a.Authenticate("ledger-key"); p == nil || p.ID != "ledger" || !reflect.DeepEqual(p.Roles, []string{"svc"}) {
+		t.Errorf("expected the configured key to be known, got %+v", p)
+	}
+
+	v.Set("api.api_keys.keys", "ledger-key")
+	if _, err = Configure(v, nil); err == nil {
+		t.Error("expected invalid keys to be reported")
+	}
+	if a, err = Configure(v, ConfigStore{}); a == nil || err != nil {
+		t.Errorf("expected the keys list to be ignored with a store, got %v %v", a, err)
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	var store = NewCacheStore(getter{
+		"emf:apikey:ledger":  `{"id":"ledger","roles":["svc"]}`,
+		"emf:apikey:invalid": `{"id":`,
+	}, "")
+
+	var cases = []struct {
+		hash string
+		id   string
+		ok   bool
+	}{
+		{"ledger", "ledger", true},
+		{"unknown", "", true},
+		{"invalid", "", false},
+		{"broken", "", false},
+	}
+	for _, c := range cases {
+		var p, err = store.Lookup(c.hash)
+		if c.ok != (err == nil) {
+			t.Errorf("%s: expected an error: %v, got %v", c.hash, !c.ok, err)
+		} else if (c.id == "") != (p == nil) || (p != nil && p.ID != c.id) {
+			t.Errorf("%s: expected the Principal %q, got %+v", c.hash, c.id, p)
+		}
+	}
+}
+
+func TestMapClaims(t *testing.T) {
+	var p = Principal{
+		ID:     "ledger",
+		Roles:  []string{"svc", "admin"},
+		Target: "vault",
+		Scopes: []string{"read", "write"},
+		Claims: map[string]interface{}{"tenant": "acme", "sub": "ignored"},
+	}
+
+	var expected = jwt.MapClaims{
+		"sub":    "ledger",
+		Claim:    "ledger",
+		"rol":    "svc",
+		"roles":  []interface{}{"svc", "admin"},
+		"tgt":    "vault",
+		"scope":  "read write",
+		"tenant": "acme",
+	}
+	if got := p.MapClaims(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,279 @@
+// Package balancer spreads the Requester calls to a component across its endpoints, with a round-robin,
+// least-outstanding-requests or weighted strategy. Endpoints failing repeatedly are ejected for a while, then
+// probed back in with a single call.
+package balancer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+)
+
+// Strategies of Settings.Strategy
+const (
+	RoundRobin       = "round_robin"
+	LeastOutstanding = "least_outstanding"
+	Weighted         = "weighted"
+)
+
+const defaultEjectionTime = 30 * time.Second
+
+var ejectedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "emf_requester_endpoint_ejected",
+	Help: "Whether an endpoint of a component is ejected from load balancing after repeated failures",
+}, []string{"component", "endpoint"})
+
+// Settings is the load balancing configuration of a component
+type Settings struct {
+	// Strategy is round_robin, least_outstanding or weighted
+	Strategy string `mapstructure:"strategy"`
+	// MaxFailures is the number of consecutive failed calls that ejects an endpoint. 0 disables ejection.
+	MaxFailures int `mapstructure:"max_failures"`
+	// EjectionTime is how long an endpoint is first ejected, 30 seconds by default, doubled on every ejection
+	// up to MaxEjectionTime
+	EjectionTime    time.Duration `mapstructure:"ejection_time"`
+	MaxEjectionTime time.Duration `mapstructure:"max_ejection_time"`
+}
+
+// Validate checks the strategy
+func (s Settings) Validate() error {
+	switch s.Strategy {
+	case "", RoundRobin, LeastOutstanding, Weighted:
+		return nil
+	default:
+		return fmt.Errorf("unknown load balancing strategy '%s'", s.Strategy)
+	}
+}
+
+// endpoint is the state of an endpoint of a component
+type endpoint struct {
+	outstanding  int
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+	probing      bool
+}
+
+// ejected reports whether the endpoint is out of the rotation at now
+func (e *endpoint) ejected(now time.Time) bool {
+	return e.probing || now.Before(e.ejectedUntil)
+}
+
+// Balancer picks the endpoint of every call to a component
+type Balancer struct {
+	component string
+	settings  Settings
+
+	mu        sync.Mutex
+	next      int
+	endpoints map[string]*endpoint
+}
+
+// New returns a Balancer for component
+func New(component string, settings Settings) *Balancer {
+	if settings.EjectionTime <= 0 {
+		settings.EjectionTime = defaultEjectionTime
+	}
+	return &Balancer{component: component, settings: settings, endpoints: map[string]*endpoint{}}
+}
+
+// Pick chooses one of endpoints, which must not be empty, for a call, and returns a callback reporting whether
+// the call failed. Ejected endpoints are skipped, unless their ejection is over and they are probed with this
+// call. When every endpoint is ejected, one is picked anyway rather than failing the call.
+func (b *Balancer) Pick(endpoints []discovery.Endpoint) (picked discovery.Endpoint, done func(failed bool)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var now = time.Now()
+	var available = make([]discovery.Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		var state = b.state(e.URL)
+		if !state.ejected(now) {
+			available = append(available, e)
+		}
+	}
+
+	var probe bool
+	for _, e := range available {
+		// An endpoint whose ejection is over gets a single probe call before it is back in the rotation
+		if state := b.endpoints[e.URL]; state.ejections > 0 {
+			picked, probe = e, true
+			break
+		}
+	}
+
+	if !probe {
+		if len(available) == 0 {
+			available = endpoints
+		}
+		picked = b.choose(available)
+	}
+
+	var state = b.endpoints[picked.URL]
+	state.outstanding++
+	if probe {
+		state.probing = true
+	}
+	return picked, func(failed bool) { b.done(picked.URL, state, probe, failed) }
+}
+
+// choose applies the strategy to the available endpoints
+func (b *Balancer) choose(available []discovery.Endpoint) discovery.Endpoint {
+	b.next++
+	switch b.settings.Strategy {
+	case LeastOutstanding:
+		var best, least = 0, math.MaxInt32
+		for i := range available {
+			// Start from the round-robin position so ties are spread
+			var j = (b.next + i) % len(available)
+			if o := b.endpoints[available[j].URL].outstanding; o < least {
+				best, least = j, o
+			}
+		}
+		return available[best]
+	case Weighted:
+		var total = 0
+		for _, e := range available {
+			total += weight(e)
+		}
+		var n = rand.Intn(total) //nolint:gosec
+		for _, e := range available {
+			if n -= weight(e); n < 0 {
+				return e
+			}
+		}
+		return available[len(available)-1]
+	default:
+		return available[b.next%len(available)]
+	}
+}
+
+// done records the result of a call to an endpoint, probe telling whether it was the probe call of the endpoint
+func (b *Balancer) done(url string, state *endpoint, probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state.outstanding--
+	if probe {
+		state.probing = false
+	}
+
+	if !failed {
+		state.failures = 0
+		if probe {
+			state.ejections = 0
+			ejectedGauge.WithLabelValues(b.component, url).Set(0)
+		}
+		return
+	}
+
+	state.failures++
+	if probe || (b.settings.MaxFailures > 0 && state.failures >= b.settings.MaxFailures && state.ejections == 0) {
+		var d = b.settings.EjectionTime * time.Duration(1<<uint(minInt(state.ejections, 16)))
+		if b.settings.MaxEjectionTime > 0 && d > b.settings.MaxEjectionTime {
+			d = b.settings.MaxEjectionTime
+		}
+		state.ejections++
+		state.failures = 0
+		state.ejectedUntil = time.Now().Add(d)
+		ejectedGauge.WithLabelValues(b.component, url).Set(1)
+	}
+}
+
+// state returns the state of the endpoint at url, creating it on first use. It must be called with mu held.
+func (b *Balancer) state(url string) *endpoint {
+	var state, ok = b.endpoints[url]
+	if !ok {
+		state = &endpoint{}
+		b.endpoints[url] = state
+	}
+	return state
+}
+
+func weight(e discovery.Endpoint) int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Balancers holds the Balancer of every component, configured by the requester.balancer block: the default
+// settings, overridden per component under requester.balancer.components.<name>.
+type Balancers struct {
+	cfg       configurer.ConfigReader
+	mu        sync.Mutex
+	balancers map[string]*Balancer
+}
+
+// NewBalancers returns the Balancers of the requester.balancer config block
+func NewBalancers(cfg configurer.ConfigReader) *Balancers {
+	return &Balancers{cfg: cfg, balancers: map[string]*Balancer{}}
+}
+
+// Validate checks that the default and component settings of the config can be decoded
+func (bs *Balancers) Validate() error {
+	var settings, err = bs.settings("")
+	if err == nil {
+		err = settings.Validate()
+	}
+	if err != nil {
+		return err
+	}
+
+	var components map[string]interface{}
+	if err = bs.cfg.UnmarshalKey("requester.balancer.components", &components); err != nil {
+		return err
+	}
+	for name := range components {
+		if settings, err = bs.settings(name); err == nil {
+			err = settings.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("component '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// For returns the Balancer of component, or nil for an empty component
+func (bs *Balancers) For(component string) *Balancer {
+	if bs == nil || component == "" {
+		return nil
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if b, ok := bs.balancers[component]; ok {
+		return b
+	}
+
+	// Invalid settings are reported by Validate on startup, calls are sent round-robin meanwhile
+	var settings, _ = bs.settings(component)
+	var b = New(component, settings)
+	bs.balancers[component] = b
+	return b
+}
+
+func (bs *Balancers) settings(component string) (settings Settings, err error) {
+	if err = bs.cfg.UnmarshalKey("requester.balancer", &settings); err != nil || component == "" {
+		return
+	}
+	err = bs.cfg.UnmarshalKey("requester.balancer.components."+component, &settings)
+	return
+}
@@ -0,0 +1,54 @@
+package balancer
+
+import (
+	"testing"
+
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+)
+
+var endpoints = []discovery.Endpoint{{URL: "http://a", Weight: 3}, {URL: "http://b", Weight: 1}}
+
+func TestBalancerLeastOutstanding(t *testing.T) {
+	var b = New("ledger", Settings{Strategy: LeastOutstanding})
+
+	// A slow call keeps its endpoint busy, so the next calls go to the other one
+	var slow, doneSlow = b.Pick(endpoints)
+	for i := 0; i < 3; i++ {
+		var picked, done = b.Pick(endpoints)
+		if picked.URL == slow.URL {
+			t.Errorf("call %d was sent to the busy endpoint %s", i, slow.URL)
+		}
+		done(false)
+	}
+	doneSlow(false)
+}
+
+func TestBalancerWeighted(t *testing.T) {
+	var b = New("ledger", Settings{Strategy: Weighted})
+
+	var counts = map[string]int{}
+	for i := 0; i < 4000; i++ {
+		var picked, done = b.Pick(endpoints)
+		counts[picked.URL]++
+		done(false)
+	}
+	if counts["http://a"] < 2700 || counts["http://a"] > 3300 {
+		t.Errorf("expected about 3000 of 4000 calls to the endpoint of weight 3, got %d", counts["http://a"])
+	}
+}
+
+func TestBalancerPanicMode(t *testing.T) {
+	var b = New("ledger", Settings{MaxFailures: 1, EjectionTime: 1 << 40})
+
+	for _, e := range endpoints {
+		var _, done = b.Pick([]discovery.Endpoint{e})
+		done(true)
+	}
+
+	// Every endpoint is ejected, calls are sent anyway
+	var picked, done = b.Pick(endpoints)
+	if picked.URL == "" {
+		t.Error("expected an endpoint to be picked when every endpoint is ejected")
+	}
+	done(false)
+}
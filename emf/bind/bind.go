@@ -5,6 +5,7 @@
 // 		- lines 36-48 of that file are removed as calling bindData on json payloads breaks binding arrays
 // 		- long lines are shortened to satisfy the linter,
 // 		- variables from outside bind.go are imported from the latest echo package
+// 		- JSON and the other media types of codec.Default are decoded with their registered codec
 package bind
 
 import (
@@ -19,6 +20,8 @@ import (
 	"strings"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/cambridge-blockchain/emf/emf/codec"
 )
 
 type (
@@ -48,21 +51,6 @@ func (b *DefaultBinder) Bind(i interface{}, c echo.Context) (err error) {
 	}
 	ctype := req.Header.Get(echo.HeaderContentType)
 	switch {
-	case strings.HasPrefix(ctype, echo.MIMEApplicationJSON):
-		if err = json.NewDecoder(req.Body).Decode(i); err != nil {
-			if ute, ok := err.(*json.UnmarshalTypeError); ok {
-				return echo.NewHTTPError(http.StatusBadRequest,
-					fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v",
-						ute.Type, ute.Value, ute.Field, ute.Offset,
-					)).SetInternal(err)
-			} else if se, ok := err.(*json.SyntaxError); ok {
-				return echo.NewHTTPError(http.StatusBadRequest,
-					fmt.Sprintf("Syntax error: offset=%v, error=%v",
-						se.Offset, se.Error(),
-					)).SetInternal(err)
-			}
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
-		}
 	case strings.HasPrefix(ctype, echo.MIMEApplicationXML), strings.HasPrefix(ctype, echo.MIMETextXML):
 		if err = xml.NewDecoder(req.Body).Decode(i); err != nil {
 			if ute, ok := err.(*xml.UnsupportedTypeError); ok {
@@ -85,11 +73,33 @@ func (b *DefaultBinder) Bind(i interface{}, c echo.Context) (err error) {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
 		}
 	default:
-		return echo.ErrUnsupportedMediaType
+		dec, ok := codec.Default.Get(ctype)
+		if !ok {
+			return echo.ErrUnsupportedMediaType
+		}
+		if err = dec.Decode(req.Body, i); err != nil {
+			return decodeError(err)
+		}
 	}
 	return
 }
 
+// decodeError converts the errors of codecs into bad requests, detailing those of the JSON codec
+func decodeError(err error) error {
+	if ute, ok := err.(*json.UnmarshalTypeError); ok {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Unmarshal type error: expected=%v, got=%v, field=%v, offset=%v",
+				ute.Type, ute.Value, ute.Field, ute.Offset,
+			)).SetInternal(err)
+	} else if se, ok := err.(*json.SyntaxError); ok {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Syntax error: offset=%v, error=%v",
+				se.Offset, se.Error(),
+			)).SetInternal(err)
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+}
+
 //nolint: gocognit,gocyclo
 func (b *DefaultBinder) bindData(ptr interface{}, data map[string][]string, tag string) error {
 	if ptr == nil || len(data) == 0 {
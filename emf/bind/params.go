@@ -0,0 +1,108 @@
+package bind
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ParamError reports the request parameter that could not be bound
+type ParamError struct {
+	Param string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("parameter '%s': %s", e.Param, e.Err)
+}
+
+// Unwrap returns the conversion error
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// BindParams binds the values of data, such as path or query parameters or headers, to the fields of the
+// struct pointed to by ptr which carry tag. Unlike the form binding of DefaultBinder, untagged fields are
+// never bound, so body fields cannot be set from parameters. Names are matched case insensitively.
+func BindParams(ptr interface{}, data map[string][]string, tag string) error {
+	if ptr == nil || len(data) == 0 {
+		return nil
+	}
+
+	var val = reflect.ValueOf(ptr)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binding element must be a pointer to a struct")
+	}
+	return bindTagged(val.Elem(), data, tag)
+}
+
+func bindTagged(val reflect.Value, data map[string][]string, tag string) error {
+	var typ = val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		var typeField, structField = typ.Field(i), val.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+
+		var name = typeField.Tag.Get(tag)
+		if name == "" {
+			if typeField.Anonymous && structField.Kind() == reflect.Struct {
+				if err := bindTagged(structField, data, tag); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		var values, ok = lookupParam(data, name)
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		if err := setParam(typeField.Type, values, structField); err != nil {
+			return &ParamError{Param: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func lookupParam(data map[string][]string, name string) ([]string, bool) {
+	if values, ok := data[name]; ok {
+		return values, true
+	}
+	for k, values := range data {
+		if strings.EqualFold(k, name) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+func setParam(typ reflect.Type, values []string, field reflect.Value) error {
+	// Call this first, in case we're dealing with an alias to an array type
+	if ok, err := unmarshalField(typ.Kind(), values[0], field); ok {
+		return err
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		var elem = reflect.New(typ.Elem())
+		if err := setParam(typ.Elem(), values, elem.Elem()); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		var slice = reflect.MakeSlice(typ, len(values), len(values))
+		for j := range values {
+			if err := setWithProperType(typ.Elem().Kind(), values[j], slice.Index(j)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	default:
+		return setWithProperType(typ.Kind(), values[0], field)
+	}
+}
@@ -0,0 +1,335 @@
+// Package breaker stops sending Requester calls to a component that keeps failing, so callers fail fast
+// instead of waiting for timeouts. Each component has a circuit breaker that opens when the failure rate of
+// its calls crosses a threshold, rejects calls during a cool-down, then lets a few probe calls through
+// (half-open) to decide whether to close again.
+package breaker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+// The states of a circuit breaker, also the values of the emf_requester_breaker_state metric
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+var stateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "emf_requester_breaker_state",
+	Help: "State of the circuit breaker of each component: 0 closed, 1 half-open, 2 open",
+}, []string{"component"})
+
+var rejectedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "emf_requester_breaker_rejected_total",
+	Help: "Number of Requester calls rejected by an open circuit breaker, by component",
+}, []string{"component"})
+
+// Default settings, used for the settings left to zero
+const (
+	defaultWindow           = 10 * time.Second
+	defaultMinRequests      = 20
+	defaultFailureRate      = 0.5
+	defaultCoolDown         = 5 * time.Second
+	defaultHalfOpenRequests = 3
+)
+
+// Settings is the circuit breaker configuration of a component. Settings left to zero take their default.
+type Settings struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is the period over which the failure rate is measured while closed, 10 seconds by default
+	Window time.Duration `mapstructure:"window"`
+	// MinRequests is the number of calls in a window below which the breaker never opens, 20 by default
+	MinRequests int `mapstructure:"min_requests"`
+	// FailureRate is the fraction of failed calls, above 0 and up to 1, that opens the breaker, 0.5 by default
+	FailureRate float64 `mapstructure:"failure_rate"`
+	// CoolDown is how long calls are rejected once the breaker opened, 5 seconds by default
+	CoolDown time.Duration `mapstructure:"cool_down"`
+	// HalfOpenRequests is the number of probe calls let through after the cool-down, which must all
+	// succeed to close the breaker, 3 by default
+	HalfOpenRequests int `mapstructure:"half_open_requests"`
+}
+
+// withDefaults returns the settings with the defaults of the settings left to zero
+func (s Settings) withDefaults() Settings {
+	if s.Window == 0 {
+		s.Window = defaultWindow
+	}
+	if s.MinRequests == 0 {
+		s.MinRequests = defaultMinRequests
+	}
+	if s.FailureRate == 0 {
+		s.FailureRate = defaultFailureRate
+	}
+	if s.CoolDown == 0 {
+		s.CoolDown = defaultCoolDown
+	}
+	if s.HalfOpenRequests == 0 {
+		s.HalfOpenRequests = defaultHalfOpenRequests
+	}
+	return s
+}
+
+// Validate checks the ranges of the settings, once the defaults are applied
+func (s Settings) Validate() error {
+	s = s.withDefaults()
+	switch {
+	case s.Window < 0:
+		return fmt.Errorf("window must be positive, got %s", s.Window)
+	case s.MinRequests < 1:
+		return fmt.Errorf("min_requests must be at least 1, got %d", s.MinRequests)
+	case s.FailureRate <= 0 || s.FailureRate > 1:
+		return fmt.Errorf("failure_rate must be above 0 and up to 1, got %v", s.FailureRate)
+	case s.CoolDown < 0:
+		return fmt.Errorf("cool_down must be positive, got %s", s.CoolDown)
+	case s.HalfOpenRequests < 1:
+		return fmt.Errorf("half_open_requests must be at least 1, got %d", s.HalfOpenRequests)
+	}
+	return nil
+}
+
+// OpenError is returned for calls rejected by an open breaker
+type OpenError struct {
+	Component string
+	RetryIn   time.Duration
+}
+
+// Error implements the standard error interface
+func (oe *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of component '%s' is open, retry in %s", oe.Component, oe.RetryIn)
+}
+
+// Status is a snapshot of a circuit breaker, as listed by the breakers endpoint
+type Status struct {
+	Component string     `json:"component"`
+	State     string     `json:"state"`
+	Requests  int        `json:"requests"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+}
+
+// Breaker is the circuit breaker of a component
+type Breaker struct {
+	component string
+	settings  Settings
+
+	mu          sync.Mutex
+	state       State
+	generation  int
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	probes      int
+	successes   int
+}
+
+// New returns a closed Breaker for component. Settings left to zero take their default, and settings must
+// otherwise pass Validate.
+func New(component string, settings Settings) *Breaker {
+	settings = settings.withDefaults()
+	var b = &Breaker{component: component, settings: settings, windowStart: time.Now()}
+	stateGauge.WithLabelValues(component).Set(float64(Closed))
+	return b
+}
+
+// Allow returns an *OpenError if the call must be rejected, or else a callback reporting whether the call
+// failed. A nil Breaker allows every call.
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	if b == nil {
+		return func(bool) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var now = time.Now()
+	switch b.state {
+	case Open:
+		if wait := b.settings.CoolDown - now.Sub(b.openedAt); wait > 0 {
+			rejectedCounter.WithLabelValues(b.component).Inc()
+			return nil, &OpenError{Component: b.component, RetryIn: wait}
+		}
+		b.transition(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			rejectedCounter.WithLabelValues(b.component).Inc()
+			return nil, &OpenError{Component: b.component}
+		}
+		b.probes++
+	case Closed:
+		if now.Sub(b.windowStart) >= b.settings.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	}
+
+	var generation = b.generation
+	return func(failed bool) { b.record(generation, failed) }, nil
+}
+
+// record counts the result of a call allowed in generation, ignoring calls allowed before a state change
+func (b *Breaker) record(generation int, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.settings.MinRequests &&
+			float64(b.failures) >= b.settings.FailureRate*float64(b.requests) {
+			b.transition(Open)
+		}
+	case HalfOpen:
+		if failed {
+			b.transition(Open)
+			return
+		}
+		if b.successes++; b.successes >= b.settings.HalfOpenRequests {
+			b.transition(Closed)
+		}
+	}
+}
+
+// transition changes the state and resets the counters. It must be called with mu held.
+func (b *Breaker) transition(state State) {
+	var now = time.Now()
+	b.state = state
+	b.generation++
+	b.requests, b.failures, b.probes, b.successes = 0, 0, 0, 0
+	b.windowStart = now
+	if state == Open {
+		b.openedAt = now
+	}
+	stateGauge.WithLabelValues(b.component).Set(float64(state))
+}
+
+// Status returns a snapshot of the breaker
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var s = Status{Component: b.component, State: b.state.String(), Requests: b.requests, Failures: b.failures}
+	if b.state != Closed {
+		var openedAt = b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+// Breakers holds the Breaker of every component, configured by the requester.breaker block: the default
+// settings, overridden per component under requester.breaker.components.<name>.
+type Breakers struct {
+	cfg      configurer.ConfigReader
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewBreakers returns the Breakers of the requester.breaker config block
+func NewBreakers(cfg configurer.ConfigReader) *Breakers {
+	return &Breakers{cfg: cfg, breakers: map[string]*Breaker{}}
+}
+
+// Validate checks that the default and component settings of the config can be decoded and are in range
+func (bs *Breakers) Validate() error {
+	var settings, err = bs.settings("")
+	if err == nil {
+		err = settings.Validate()
+	}
+	if err != nil {
+		return err
+	}
+
+	var components map[string]interface{}
+	if err = bs.cfg.UnmarshalKey("requester.breaker.components", &components); err != nil {
+		return err
+	}
+	for name := range components {
+		if settings, err = bs.settings(name); err == nil {
+			err = settings.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("component '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// For returns the Breaker of component, or nil when breakers are disabled for it
+func (bs *Breakers) For(component string) *Breaker {
+	if bs == nil || component == "" {
+		return nil
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if b, ok := bs.breakers[component]; ok {
+		return b
+	}
+
+	// Invalid settings are reported by Validate on startup, calls are never rejected meanwhile
+	var settings, err = bs.settings(component)
+	if err == nil {
+		err = settings.Validate()
+	}
+	var b *Breaker
+	if err == nil && settings.Enabled {
+		b = New(component, settings)
+	}
+	bs.breakers[component] = b
+	return b
+}
+
+// Statuses returns a snapshot of the breaker of every component called so far, by component name
+func (bs *Breakers) Statuses() []Status {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	var statuses = []Status{}
+	for _, b := range bs.breakers {
+		if b != nil {
+			statuses = append(statuses, b.Status())
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Component < statuses[j].Component })
+	return statuses
+}
+
+func (bs *Breakers) settings(component string) (settings Settings, err error) {
+	if err = bs.cfg.UnmarshalKey("requester.breaker", &settings); err != nil || component == "" {
+		return
+	}
+	err = bs.cfg.UnmarshalKey("requester.breaker.components."+component, &settings)
+	return
+}
@@ -0,0 +1,134 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	var cases = []struct {
+		name     string
+		settings Settings
+		ok       bool
+	}{
+		{"defaults", Settings{}, true},
+		{"full rate", Settings{FailureRate: 1, MinRequests: 1, CoolDown: time.Second}, true},
+		{"negative rate", Settings{FailureRate: -0.5}, false},
+		{"rate above 1", Settings{FailureRate: 1.5}, false},
+		{"negative min requests", Settings{MinRequests: -1}, false},
+		{"negative cool down", Settings{CoolDown: -time.Second}, false},
+		{"negative window", Settings{Window: -time.Second}, false},
+		{"negative probes", Settings{HalfOpenRequests: -1}, false},
+	}
+	for _, c := range cases {
+		if err := c.settings.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: expected valid: %v, got %v", c.name, c.ok, err)
+		}
+	}
+}
+
+// call sends a call through b, returning whether it was allowed
+func call(b *Breaker, failed bool) bool {
+	var done, err = b.Allow()
+	if err != nil {
+		return false
+	}
+	done(failed)
+	return true
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	var b = New("ledger", Settings{
+		Window: time.Minute, MinRequests: 4, FailureRate: 0.5, CoolDown: 50 * time.Millisecond, HalfOpenRequests: 2,
+	})
+
+	var steps = []struct {
+		name    string
+		failed  bool
+		allowed bool
+		state   State
+	}{
+		{"success", false, true, Closed},
+		{"failure below min requests", true, true, Closed},
+		{"second success", false, true, Closed},
+		{"failure reaching the rate", true, true, Open},
+		{"rejected while open", false, false, Open},
+	}
+	for _, s := range steps {
+		if allowed := call(b, s.failed); allowed != s.allowed || b.state != s.state {
+			t.Fatalf("%s: expected allowed %v and %s, got %v and %s", s.name, s.allowed, s.state, allowed, b.state)
+		}
+	}
+
+	// After the cool-down, the probes of the half-open breaker close it
+	time.Sleep(60 * time.Millisecond)
+	var done1, err = b.Allow()
+	if err != nil || b.state != HalfOpen {
+		t.Fatalf("expected a probe of the half-open breaker, got %s, %v", b.state, err)
+	}
+	var done2, _ = b.Allow()
+	if _, err = b.Allow(); err == nil {
+		t.Error("expected calls beyond the probes to be rejected")
+	}
+	done1(false)
+	done2(false)
+	if b.state != Closed {
+		t.Errorf("expected the successful probes to close the breaker, got %s", b.state)
+	}
+}
+
+func TestBreakerReopensOnFailedProbe(t *testing.T) {
+	var b = New("ledger", Settings{MinRequests: 1, FailureRate: 1, CoolDown: 20 * time.Millisecond})
+
+	call(b, true)
+	time.Sleep(30 * time.Millisecond)
+	if !call(b, true) || b.state != Open {
+		t.Fatalf("expected a failed probe to open the breaker again, got %s", b.state)
+	}
+
+	// Results of calls allowed before the breaker opened are ignored
+	time.Sleep(30 * time.Millisecond)
+	var probe, _ = b.Allow()
+	b.mu.Lock()
+	b.transition(Closed)
+	b.mu.Unlock()
+	probe(true)
+	if b.state != Closed {
+		t.Errorf("expected a stale result to be ignored, got %s", b.state)
+	}
+}
+
+func TestBreakerDefaults(t *testing.T) {
+	// A breaker without failure_rate does not open on its first call
+	var b = New("ledger", Settings{Enabled: true})
+	for i := 0; i < defaultMinRequests-1; i++ {
+		call(b, i%2 == 0)
+	}
+	if b.state != Closed {
+		t.Errorf("expected the breaker to stay closed below min_requests, got %s", b.state)
+	}
+
+	var v = viper.New()
+	v.Set("requester.breaker", map[string]interface{}{
+		"enabled": true, "components": map[string]interface{}{"ledger": map[string]interface{}{"failure_rate": 2}},
+	})
+	var bs = NewBreakers(v)
+	if err := bs.Validate(); err == nil {
+		t.Error("expected an out of range component setting to be reported")
+	}
+	if bs.For("ledger") != nil {
+		t.Error("expected no breaker for invalid settings")
+	}
+	if bs.For("vault") == nil {
+		t.Error("expected a breaker with the default settings")
+	}
+}
+
+func TestNilBreakerAllows(t *testing.T) {
+	var b *Breaker
+	if !call(b, true) {
+		t.Error("expected a nil breaker to allow calls")
+	}
+}
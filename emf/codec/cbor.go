@@ -0,0 +1,362 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// CBOR is the codec of application/cbor (RFC 8949). Values are encoded as their JSON form, with the map keys
+// sorted as deterministic encoding requires. Decoding accepts any well-formed item of the JSON data model,
+// skipping tags.
+type CBOR struct{}
+
+// ContentType returns application/cbor
+func (CBOR) ContentType() string { return MIMECBOR }
+
+// Encode writes v as CBOR
+func (CBOR) Encode(w io.Writer, v interface{}) error {
+	var tree, err = toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = encodeCBOR(&buf, tree); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// Decode reads a CBOR item into v
+func (CBOR) Decode(r io.Reader, v interface{}) error {
+	var b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var d = cborDecoder{data: b}
+	var tree interface{}
+	if tree, err = d.item(0); err != nil {
+		return err
+	}
+	return fromGeneric(tree, v)
+}
+
+// Major types of CBOR items
+const (
+	cborUint byte = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+	cborMaxDepth   = 128
+)
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+func encodeCBOR(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | 22)
+	case bool:
+		if v {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case int64:
+		if v < 0 {
+			cborHead(buf, cborNegInt, uint64(-(v + 1)))
+		} else {
+			cborHead(buf, cborUint, uint64(v))
+		}
+	case float64:
+		buf.WriteByte(cborSimple<<5 | 27)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		buf.Write(b[:])
+	case string:
+		cborHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		cborHead(buf, cborArray, uint64(len(v)))
+		for _, e := range v {
+			if err := encodeCBOR(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Deterministic encoding sorts the keys by their encoded bytes
+		var keys = make([][]byte, 0, len(v))
+		for k := range v {
+			var kb bytes.Buffer
+			cborHead(&kb, cborText, uint64(len(k)))
+			kb.WriteString(k)
+			keys = append(keys, kb.Bytes())
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		cborHead(buf, cborMap, uint64(len(v)))
+		for _, k := range keys {
+			buf.Write(k)
+			var _, n = cborArgument(k)
+			if err := encodeCBOR(buf, v[string(k[n:])]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor cannot encode %T: %w", v, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// cborHead writes the head of an item: its major type and argument, in the shortest form
+func cborHead(buf *bytes.Buffer, major byte, arg uint64) {
+	var b [9]byte
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+		return
+	case arg <= math.MaxUint8:
+		b[0], b[1] = major<<5|24, byte(arg)
+		buf.Write(b[:2])
+	case arg <= math.MaxUint16:
+		b[0] = major<<5 | 25
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		buf.Write(b[:3])
+	case arg <= math.MaxUint32:
+		b[0] = major<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		buf.Write(b[:5])
+	default:
+		b[0] = major<<5 | 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+		buf.Write(b[:9])
+	}
+}
+
+// cborArgument returns the argument of the well-formed head at the start of b and the length of the head
+func cborArgument(b []byte) (arg uint64, n int) {
+	switch info := b[0] & 0x1f; {
+	case info < 24:
+		return uint64(info), 1
+	case info == 24:
+		return uint64(b[1]), 2
+	case info == 25:
+		return uint64(binary.BigEndian.Uint16(b[1:])), 3
+	case info == 26:
+		return uint64(binary.BigEndian.Uint32(b[1:])), 5
+	default:
+		return binary.BigEndian.Uint64(b[1:]), 9
+	}
+}
+
+// cborDecoder reads the items of a CBOR document
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the head of an item. indefinite is set for the indefinite length marker.
+func (d *cborDecoder) head() (major, info byte, arg uint64, indefinite bool, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, false, errCBORTruncated
+	}
+	var b = d.data[d.pos]
+	major, info = b>>5, b&0x1f
+
+	var size int
+	switch {
+	case info < 24:
+		d.pos++
+		return major, info, uint64(info), false, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == cborIndefinite && major >= cborBytes && major <= cborMap:
+		d.pos++
+		return major, info, 0, true, nil
+	default:
+		return 0, 0, 0, false, fmt.Errorf("cbor: invalid additional information %d at offset %d", info, d.pos)
+	}
+
+	if d.pos+1+size > len(d.data) {
+		return 0, 0, 0, false, errCBORTruncated
+	}
+	var raw = d.data[d.pos+1 : d.pos+1+size]
+	for _, c := range raw {
+		arg = arg<<8 | uint64(c)
+	}
+	d.pos += 1 + size
+	return major, info, arg, false, nil
+}
+
+// item reads the next item as a value of the JSON data model: byte strings are []byte, integers int64 or
+// uint64, and map keys that are not text are formatted as strings.
+func (d *cborDecoder) item(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("cbor: items nested deeper than %d", cborMaxDepth)
+	}
+
+	var major, info, arg, indefinite, err = d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		var s []byte
+		if s, err = d.str(major, arg, indefinite); err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(s), nil
+		}
+		return s, nil
+	case cborArray:
+		var list = []interface{}{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.brk() {
+				break
+			}
+			var e interface{}
+			if e, err = d.item(depth + 1); err != nil {
+				return nil, err
+			}
+			list = append(list, e)
+		}
+		return list, nil
+	case cborMap:
+		var m = map[string]interface{}{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.brk() {
+				break
+			}
+			var k, e interface{}
+			if k, err = d.item(depth + 1); err != nil {
+				return nil, err
+			}
+			if e, err = d.item(depth + 1); err != nil {
+				return nil, err
+			}
+			if s, ok := k.(string); ok {
+				m[s] = e
+			} else {
+				m[fmt.Sprint(k)] = e
+			}
+		}
+		return m, nil
+	case cborTag:
+		return d.item(depth + 1)
+	default:
+		return d.simple(info, arg)
+	}
+}
+
+// str reads the content of a byte or text string, concatenating the chunks of indefinite length strings
+func (d *cborDecoder) str(major byte, length uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if length > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		var s = d.data[d.pos : d.pos+int(length)]
+		d.pos += int(length)
+		return s, nil
+	}
+
+	var s []byte
+	for !d.brk() {
+		var chunkMajor, _, n, chunkIndefinite, err = d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, fmt.Errorf("cbor: invalid chunk of indefinite length string at offset %d", d.pos)
+		}
+		var chunk []byte
+		if chunk, err = d.str(major, n, false); err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+	return s, nil
+}
+
+// simple converts the simple values and floats of major type 7
+func (d *cborDecoder) simple(info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", arg)
+	}
+}
+
+// brk consumes the break marker ending an indefinite length item, if it is next
+func (d *cborDecoder) brk() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == cborBreak {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// halfFloat converts an IEEE 754 half-precision float
+func halfFloat(h uint16) float64 {
+	var exp, mant = int(h>>10) & 0x1f, float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
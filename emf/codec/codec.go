@@ -0,0 +1,190 @@
+// Package codec is the registry of the media types EMF encodes and decodes: the request bodies bound by
+// bind.DefaultBinder, the bodies sent by the Requester, the responses it decodes and the responses of typed
+// handlers. JSON, Gob, MessagePack, Protocol Buffers and CBOR are registered by default, and services can
+// register their own codecs with Register.
+package codec
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Media types of the default codecs
+const (
+	MIMEJSON        = "application/json"
+	MIMEGob         = "application/x-gob"
+	MIMEMessagePack = "application/msgpack"
+	MIMEProtobuf    = "application/x-protobuf"
+	MIMECBOR        = "application/cbor"
+)
+
+// ErrUnsupportedType is wrapped by the errors of codecs that cannot encode or decode a type, such as the
+// Protocol Buffers codec for types that are not a proto.Message
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// Codec encodes and decodes the bodies of a media type
+type Codec interface {
+	// ContentType returns the media type written in the Content-Type header of encoded bodies
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Registry holds the codecs of media types
+type Registry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+	order  []Codec
+}
+
+// Default is the Registry shared by the binder, the Requester and typed handlers
+var Default = NewRegistry()
+
+func init() {
+	Default.Register(JSON{})
+	Default.Register(Gob{})
+	Default.Register(MessagePack{}, "application/x-msgpack", "application/vnd.msgpack")
+	Default.Register(Protobuf{}, "application/protobuf", "application/vnd.google.protobuf")
+	Default.Register(CBOR{})
+}
+
+// Register adds a codec to the Default Registry
+func Register(c Codec, aliases ...string) {
+	Default.Register(c, aliases...)
+}
+
+// NewRegistry returns a Registry of codecs
+func NewRegistry(codecs ...Codec) *Registry {
+	var r = &Registry{codecs: map[string]Codec{}}
+	for _, c := range codecs {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds c for its content type and aliases, replacing the codec registered for them.
+// The first codec registered is used when any media type is acceptable.
+func (r *Registry) Register(c Codec, aliases ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, mt := range append([]string{c.ContentType()}, aliases...) {
+		mt = strings.ToLower(mt)
+		if prev, ok := r.codecs[mt]; ok {
+			for i := range r.order {
+				if r.order[i] == prev {
+					r.order[i] = c
+				}
+			}
+		}
+		r.codecs[mt] = c
+	}
+
+	for _, o := range r.order {
+		if o == c {
+			return
+		}
+	}
+	r.order = append(r.order, c)
+}
+
+// Get returns the codec of a Content-Type header value, ignoring its parameters. Media types with a
+// structured syntax suffix, such as application/problem+json, use the codec of their suffix.
+func (r *Registry) Get(contentType string) (Codec, bool) {
+	var mt = mediaType(contentType)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.codecs[mt]; ok {
+		return c, true
+	}
+	if i := strings.LastIndex(mt, "+"); i >= 0 {
+		var c, ok = r.codecs["application/"+mt[i+1:]]
+		return c, ok
+	}
+	return nil, false
+}
+
+// Negotiate returns the codec of the preferred media type of an Accept header value. An empty header accepts
+// any media type, answered with the first codec registered. ok is false when no codec is acceptable.
+func (r *Registry) Negotiate(accept string) (c Codec, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+
+	for _, mt := range acceptable(accept) {
+		if c, ok = r.match(mt); ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// match returns the codec of a media range of an Accept header
+func (r *Registry) match(mediaRange string) (Codec, bool) {
+	var prefix = strings.TrimSuffix(mediaRange, "*")
+	if prefix == mediaRange {
+		return r.Get(mediaRange)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.order {
+		if prefix == "*/" || strings.HasPrefix(c.ContentType(), prefix) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// mediaType returns the lowercase media type of a header value, without its parameters. Values with invalid
+// parameters are accepted, as the binder always did for JSON.
+func mediaType(value string) string {
+	if mt, _, err := mime.ParseMediaType(value); err == nil {
+		return mt
+	}
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// acceptable returns the media ranges of an Accept header value by decreasing quality, skipping the
+// ranges of quality 0
+func acceptable(accept string) []string {
+	type mediaRange struct {
+		mt string
+		q  float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		var mt, params, err = mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		var q = 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mt, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	var types = make([]string, len(ranges))
+	for i, r := range ranges {
+		types[i] = r.mt
+	}
+	return types
+}
@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type account struct {
+	ID      string            `json:"id"`
+	Balance int64             `json:"balance"`
+	Rate    float64           `json:"rate"`
+	Tags    []string          `json:"tags"`
+	Meta    map[string]string `json:"meta"`
+	Key     []byte            `json:"key"`
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	var in = account{
+		ID: "acc-1", Balance: -42, Rate: 0.25, Tags: []string{"a", "b"},
+		Meta: map[string]string{"z": "1", "a": "2"}, Key: []byte{0, 1, 2},
+	}
+
+	for _, mt := range []string{MIMEJSON, MIMEGob, MIMEMessagePack, MIMECBOR, "application/x-msgpack"} {
+		var c, ok = Default.Get(mt + "; charset=utf-8")
+		if !ok {
+			t.Fatalf("no codec registered for %s", mt)
+		}
+
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, in); err != nil {
+			t.Fatalf("%s: encode failed: %s", mt, err)
+		}
+		var out account
+		if err := c.Decode(&buf, &out); err != nil {
+			t.Fatalf("%s: decode failed: %s", mt, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %+v, got %+v", mt, in, out)
+		}
+	}
+}
+
+func TestProtobufRequiresMessages(t *testing.T) {
+	if err := (Protobuf{}).Encode(&bytes.Buffer{}, account{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func list(items ...int64) []interface{} {
+	var l = make([]interface{}, len(items))
+	for i, item := range items {
+		l[i] = item
+	}
+	return l
+}
+
+func TestCBORDecode(t *testing.T) {
+	// Examples of RFC 8949 appendix A
+	var cases = map[string]interface{}{
+		"1903e8":                     int64(1000),
+		"3903e7":                     int64(-1000),
+		"f93e00":                     1.5,
+		"fa47c35000":                 100000.0,
+		"f5":                         true,
+		"f6":                         nil,
+		"6449455446":                 "IETF",
+		"7f657374726561646d696e67ff": "streaming",
+		"9f018202039f0405ffff":       []interface{}{int64(1), list(2, 3), list(4, 5)},
+		"a26161016162820203":         map[string]interface{}{"a": int64(1), "b": list(2, 3)},
+		"c074323031332d30332d32315432303a30343a30305a": "2013-03-21T20:04:00Z",
+	}
+
+	for in, want := range cases {
+		var b, _ = hex.DecodeString(in)
+		var d = cborDecoder{data: b}
+		var got, err = d.item(0)
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", in, want, got)
+		}
+	}
+
+	var d = cborDecoder{data: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}
+	if _, err := d.item(0); err == nil {
+		t.Error("expected an error for an array longer than its data")
+	}
+}
+
+func TestRegistryNegotiate(t *testing.T) {
+	var cases = map[string]string{
+		"":                                      MIMEJSON,
+		"*/*":                                   MIMEJSON,
+		"application/cbor":                      MIMECBOR,
+		"text/html, application/msgpack;q=0.8":  MIMEMessagePack,
+		"application/json;q=0.5, application/*": MIMEJSON,
+		"application/cbor;q=0.5, application/x-gob": MIMEGob,
+	}
+	for accept, want := range cases {
+		var c, ok = Default.Negotiate(accept)
+		if !ok || c.ContentType() != want {
+			t.Errorf("Accept '%s': expected %s, got %v", accept, want, c)
+		}
+	}
+
+	if _, ok := Default.Negotiate("text/html, application/json;q=0"); ok {
+		t.Error("expected no acceptable codec")
+	}
+	if c, ok := Default.Get("application/problem+json"); !ok || c.ContentType() != MIMEJSON {
+		t.Error("expected the JSON codec for a +json media type")
+	}
+}
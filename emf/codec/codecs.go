@@ -0,0 +1,31 @@
+package codec
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// JSON is the codec of application/json
+type JSON struct{}
+
+// ContentType returns application/json
+func (JSON) ContentType() string { return MIMEJSON }
+
+// Encode writes v as JSON
+func (JSON) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+// Decode reads JSON into v
+func (JSON) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+// Gob is the codec of application/x-gob
+type Gob struct{}
+
+// ContentType returns application/x-gob
+func (Gob) ContentType() string { return MIMEGob }
+
+// Encode writes v as a gob
+func (Gob) Encode(w io.Writer, v interface{}) error { return gob.NewEncoder(w).Encode(v) }
+
+// Decode reads a gob into v
+func (Gob) Decode(r io.Reader, v interface{}) error { return gob.NewDecoder(r).Decode(v) }
@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// toGeneric converts v to the maps, slices, strings, numbers, booleans and nils of its JSON form, so the
+// MessagePack and CBOR codecs honour the json tags of the types they encode. Integers are int64 and other
+// numbers float64.
+func toGeneric(v interface{}) (interface{}, error) {
+	var b, err = json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var d = json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var tree interface{}
+	if err = d.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return numbers(tree), nil
+}
+
+func numbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		var f, _ = v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
+
+// fromGeneric stores a tree decoded by the MessagePack or CBOR codecs into v, as if it was JSON
+func fromGeneric(tree, v interface{}) error {
+	var b, err = json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// MessagePack is the codec of application/msgpack. Types generated by msgp are encoded with their generated
+// methods, other types as their JSON form.
+type MessagePack struct{}
+
+// ContentType returns application/msgpack
+func (MessagePack) ContentType() string { return MIMEMessagePack }
+
+// Encode writes v as MessagePack
+func (MessagePack) Encode(w io.Writer, v interface{}) error {
+	if e, ok := v.(msgp.Encodable); ok {
+		return msgp.Encode(w, e)
+	}
+
+	var tree, err = toGeneric(v)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	if b, err = msgp.AppendIntf(nil, tree); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode reads MessagePack into v
+func (MessagePack) Decode(r io.Reader, v interface{}) error {
+	if d, ok := v.(msgp.Decodable); ok {
+		return msgp.Decode(r, d)
+	}
+
+	var b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var tree interface{}
+	if tree, _, err = msgp.ReadIntfBytes(b); err != nil {
+		return err
+	}
+	return fromGeneric(tree, v)
+}
@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Protobuf is the codec of application/x-protobuf, for the types generated by protoc
+type Protobuf struct{}
+
+// ContentType returns application/x-protobuf
+func (Protobuf) ContentType() string { return MIMEProtobuf }
+
+// Encode writes v, which must be a proto.Message, in the protobuf wire format
+func (Protobuf) Encode(w io.Writer, v interface{}) error {
+	var m, ok = v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf cannot encode %T: %w", v, ErrUnsupportedType)
+	}
+	var b, err = proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Decode reads the protobuf wire format into v, which must be a proto.Message
+func (Protobuf) Decode(r io.Reader, v interface{}) error {
+	var m, ok = v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf cannot decode into %T: %w", v, ErrUnsupportedType)
+	}
+	var b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
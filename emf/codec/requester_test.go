@@ -0,0 +1,83 @@
+package codec_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/bind"
+	"github.com/cambridge-blockchain/emf/emf/codec"
+	emfcontext "github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// The Requester and the binder live outside of the codec package, which they import, so sending a body from
+// one service to another is tested from this external test package
+
+type transfer struct {
+	ID     string            `json:"id"`
+	Amount int64             `json:"amount"`
+	Tags   []string          `json:"tags"`
+	Meta   map[string]string `json:"meta"`
+}
+
+// newBindingServer binds every request body into a transfer with the DefaultBinder and answers it as JSON
+func newBindingServer() *httptest.Server {
+	var e = echo.New()
+	e.Binder = &bind.DefaultBinder{}
+	e.POST("/transfers", func(c echo.Context) error {
+		var t transfer
+		if err := c.Bind(&t); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, t)
+	})
+	return httptest.NewServer(e)
+}
+
+func TestRequesterToBinder(t *testing.T) {
+	var s = newBindingServer()
+	defer s.Close()
+
+	var v = viper.New()
+	v.Set("domains.ledger", s.URL)
+	var rh = emfcontext.NewRequestHandler(v, log.New("test"))
+
+	var in = transfer{ID: "t-1", Amount: -42, Tags: []string{"a", "b"}, Meta: map[string]string{"k": "v"}}
+
+	// Requester encodes the method specific Gob and JSON bodies
+	for _, method := range []string{emfcontext.MethodPostGob, http.MethodPost} {
+		var out transfer
+		if err := rh.Requester(method, "ledger", "/transfers", in, &out); err != nil {
+			t.Errorf("%s: %v", method, err)
+		} else if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %+v, got %+v", method, in, out)
+		}
+	}
+
+	// InitRequest encodes the media type of any registered codec
+	for _, mt := range []string{codec.MIMEJSON, codec.MIMEGob, codec.MIMEMessagePack, codec.MIMECBOR} {
+		var out transfer
+		var req, err = rh.InitRequest(mt, http.MethodPost, s.URL, "/transfers", in)
+		if err == nil {
+			err = rh.SendRequest(req, &out)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", mt, err)
+		} else if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: expected %+v, got %+v", mt, in, out)
+		}
+	}
+
+	// A pointer to the input is sent like the input
+	var out transfer
+	if err := rh.Requester(emfcontext.MethodPostGob, "ledger", "/transfers", &in, &out); err != nil {
+		t.Errorf("pointer input: %v", err)
+	} else if !reflect.DeepEqual(in, out) {
+		t.Errorf("pointer input: expected %+v, got %+v", in, out)
+	}
+}
@@ -0,0 +1,147 @@
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/balancer"
+	"github.com/cambridge-blockchain/emf/emf/retry"
+)
+
+// endpointServer is an endpoint of a component that counts its calls, and fails with a 500 when told to
+type endpointServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	hits    int
+	failing bool
+}
+
+func newEndpointServer() *endpointServer {
+	var s = &endpointServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hits++
+		if s.failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`)) //nolint:errcheck
+	}))
+	return s
+}
+
+func (s *endpointServer) set(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+// reset returns the number of calls since the last reset
+func (s *endpointServer) reset() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hits = s.hits
+	s.hits = 0
+	return hits
+}
+
+func balancedHandler(balancing map[string]interface{}, servers ...*endpointServer) *RequestHandlerType {
+	var urls = make([]interface{}, len(servers))
+	for i, s := range servers {
+		urls[i] = s.URL + "/api"
+	}
+
+	var v = viper.New()
+	v.Set("domains.ledger", urls)
+	v.Set("requester.balancer", balancing)
+	v.Set("requester.retry", map[string]interface{}{
+		"max_attempts": 2, "retry_on": []string{retry.ClassConnection}, "retryable_status": []int{500},
+	})
+	return NewRequestHandler(v, log.New("test"),
+		WithBalancers(balancer.NewBalancers(v)),
+		WithRetries(retry.NewPolicies(v)),
+	)
+}
+
+func TestRequesterRoundRobin(t *testing.T) {
+	var servers = []*endpointServer{newEndpointServer(), newEndpointServer(), newEndpointServer()}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	var rh = balancedHandler(map[string]interface{}{"strategy": balancer.RoundRobin}, servers...)
+
+	for i := 0; i < 6; i++ {
+		var out struct{ Path string }
+		if err := rh.Requester(http.MethodGet, "ledger", "/accounts?limit=1", nil, &out); err != nil {
+			t.Fatalf("call %d failed: %s", i, err)
+		}
+		if out.Path != "/api/accounts" {
+			t.Errorf("expected the path under the endpoint URL, got '%s'", out.Path)
+		}
+	}
+
+	for i, s := range servers {
+		if hits := s.reset(); hits != 2 {
+			t.Errorf("expected endpoint %d to get 2 of 6 calls, got %d", i, hits)
+		}
+	}
+}
+
+func TestRequesterEjectsFailingEndpoint(t *testing.T) {
+	var healthy, failing = newEndpointServer(), newEndpointServer()
+	defer healthy.Close()
+	defer failing.Close()
+	var rh = balancedHandler(map[string]interface{}{
+		"strategy": balancer.RoundRobin, "max_failures": 2, "ejection_time": "100ms",
+	}, healthy, failing)
+	failing.set(true)
+
+	// Calls sent to the failing endpoint are retried on the healthy one
+	for i := 0; i < 8; i++ {
+		var out struct{ Path string }
+		if err := rh.Requester(http.MethodGet, "ledger", "/accounts", nil, &out); err != nil {
+			t.Fatalf("call %d was not failed over: %s", i, err)
+		}
+	}
+	if hits := failing.reset(); hits != 2 {
+		t.Errorf("expected the failing endpoint to be ejected after 2 calls, got %d", hits)
+	}
+	healthy.reset()
+
+	// Once the ejection is over, the endpoint is probed back in
+	failing.set(false)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		var out struct{ Path string }
+		if err := rh.Requester(http.MethodGet, "ledger", "/accounts", nil, &out); err != nil {
+			t.Fatalf("call %d failed: %s", i, err)
+		}
+	}
+	if hits := failing.reset(); hits < 2 {
+		t.Errorf("expected the recovered endpoint to be back in the rotation, got %d of 4 calls", hits)
+	}
+}
+
+func TestRequesterFailsOverUnreachableEndpoint(t *testing.T) {
+	var healthy, down = newEndpointServer(), newEndpointServer()
+	defer healthy.Close()
+	down.Close()
+	var rh = balancedHandler(map[string]interface{}{"strategy": balancer.RoundRobin, "max_failures": 1}, down, healthy)
+
+	for i := 0; i < 4; i++ {
+		var out struct{ Path string }
+		if err := rh.Requester(http.MethodGet, "ledger", "/accounts", nil, &out); err != nil {
+			t.Fatalf("call %d was not failed over: %s", i, err)
+		}
+	}
+	if hits := healthy.reset(); hits != 4 {
+		t.Errorf("expected every call to reach the healthy endpoint, got %d", hits)
+	}
+}
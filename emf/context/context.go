@@ -1,16 +1,29 @@
 package context
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
 
 	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/balancer"
+	"github.com/cambridge-blockchain/emf/emf/breaker"
 	"github.com/cambridge-blockchain/emf/emf/context/errors"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+	"github.com/cambridge-blockchain/emf/emf/retry"
+)
+
+const (
+	// IntrospectionKey is the context key of the claims returned by token introspection
+	IntrospectionKey = "introspection"
+	// ServiceClaim is the claim naming the calling component in service tokens
+	ServiceClaim = "svc"
 )
 
 // EMFContext is the Interface which is passed into each EMF request handler as part of each request
@@ -24,6 +37,25 @@ type EMFContext interface {
 	GetRequestLimited(domain, path string) (req *http.Request, err error)
 }
 
+// ServiceContext is implemented by EMFContexts naming the component calling with a service token.
+// EMFContextType implements it, so handlers can type-assert their EMFContext for it.
+type ServiceContext interface {
+	GetService() string
+}
+
+// IntrospectionContext is implemented by EMFContexts exposing the scopes and introspection result of the token
+// of the request. EMFContextType implements it, so handlers can type-assert their EMFContext for it.
+type IntrospectionContext interface {
+	GetScopes() []string
+	GetIntrospectionClaims() map[string]interface{}
+}
+
+// TLSContext is implemented by EMFContexts exposing the TLS connection of the request.
+// EMFContextType implements it, so handlers can type-assert their EMFContext for it.
+type TLSContext interface {
+	GetClientCertificate() *x509.Certificate
+}
+
 // EMFContextType is the default implementation of the EMFContext interface
 type EMFContextType struct {
 	echo.Context
@@ -36,11 +68,79 @@ type Option func(*EMFContextType)
 
 // WithContextClient is used to specify the HTTP Client for the Requester to use.
 func WithContextClient(client *http.Client) Option {
+	return WithRequestHandlerOptions(WithHTTPClient(client))
+}
+
+// WithRequestHandlerOptions applies RHOptions, such as WithRetries or WithServiceTokens, to the default
+// RequestHandler of the context
+func WithRequestHandlerOptions(opts ...RHOption) Option {
 	return func(ctx *EMFContextType) {
-		ctx.RequestHandler.(*RequestHandlerType).client = client
+		if rh, ok := ctx.RequestHandler.(*RequestHandlerType); ok {
+			for _, opt := range opts {
+				opt(rh)
+			}
+		}
 	}
 }
 
+// WithErrorCatalog is used to share a preloaded Catalog of Error Templates with the context's ErrorHandler
+//
+// Deprecated: use WithRequestHandlerOptions(WithRHErrorCatalog(c))
+func WithErrorCatalog(c *errors.Catalog) Option {
+	return WithRequestHandlerOptions(WithRHErrorCatalog(c))
+}
+
+// WithContextTracker is used to track goroutines started with Go, so they are drained on shutdown
+//
+// Deprecated: use WithRequestHandlerOptions(WithBackgroundTracker(tracker))
+func WithContextTracker(tracker BackgroundTracker) Option {
+	return WithRequestHandlerOptions(WithBackgroundTracker(tracker))
+}
+
+// WithContextServiceTokens is used to authenticate the Requester with service tokens when the request
+// carries no user token
+//
+// Deprecated: use WithRequestHandlerOptions(WithServiceTokens(tokens))
+func WithContextServiceTokens(tokens TokenSource) Option {
+	return WithRequestHandlerOptions(WithServiceTokens(tokens))
+}
+
+// WithContextCredentials is used to authorize requests to external APIs with their own credentials
+//
+// Deprecated: use WithRequestHandlerOptions(WithCredentials(creds))
+func WithContextCredentials(creds CredentialsSource) Option {
+	return WithRequestHandlerOptions(WithCredentials(creds))
+}
+
+// WithContextRetries is used to send failed requests again, as configured per component by the Policies
+//
+// Deprecated: use WithRequestHandlerOptions(WithRetries(p))
+func WithContextRetries(p *retry.Policies) Option {
+	return WithRequestHandlerOptions(WithRetries(p))
+}
+
+// WithContextBreakers is used to fail fast on requests to components whose circuit breaker is open
+//
+// Deprecated: use WithRequestHandlerOptions(WithBreakers(b))
+func WithContextBreakers(b *breaker.Breakers) Option {
+	return WithRequestHandlerOptions(WithBreakers(b))
+}
+
+// WithContextResolver is used to resolve the components called by the Requester with r instead of the
+// domains block
+//
+// Deprecated: use WithRequestHandlerOptions(WithResolver(r))
+func WithContextResolver(r discovery.Resolver) Option {
+	return WithRequestHandlerOptions(WithResolver(r))
+}
+
+// WithContextBalancers is used to spread the requests to components with several endpoints across them
+//
+// Deprecated: use WithRequestHandlerOptions(WithBalancers(b))
+func WithContextBalancers(b *balancer.Balancers) Option {
+	return WithRequestHandlerOptions(WithBalancers(b))
+}
+
 // WithRequestHandler is used to modify the default RequestHandler object
 func WithRequestHandler(rh RequestHandler) Option {
 	return func(ctx *EMFContextType) { ctx.RequestHandler = rh }
@@ -49,10 +149,9 @@ func WithRequestHandler(rh RequestHandler) Option {
 // NewEMFContext is a variadic constructor for a EMFContext.
 func NewEMFContext(c echo.Context, cfg configurer.ConfigReader, opts ...Option) (ctx *EMFContextType) {
 	rh := &RequestHandlerType{
-		cfg,
-		&http.Client{},
-		nil,
-		http.Header{},
+		cfg:    cfg,
+		client: &http.Client{},
+		header: http.Header{},
 	}
 	ctx = &EMFContextType{
 		c,
@@ -61,12 +160,16 @@ func NewEMFContext(c echo.Context, cfg configurer.ConfigReader, opts ...Option)
 	rh.eh = NewEMFErrorHandler(ctx,
 		ctx.IsDebug(),
 		errors.WithLogger(ctx.Context.Logger()),
-		errors.WithTemplate(cfg.GetString("errors.configPath")),
 	)
 
 	for _, opt := range opts {
 		opt(ctx)
 	}
+
+	// Only read the errors file when no Catalog was shared with this context
+	if rh.eh.Catalog() == nil {
+		errors.WithTemplate(cfg.GetString("errors.configPath"))(rh.eh)
+	}
 	return
 }
 
@@ -99,6 +202,33 @@ func (ctx *EMFContextType) NewError(code string, data map[string]interface{}, er
 	return ctx.ErrorHandler().NewError(code, data, errors...)
 }
 
+// Go runs f with the RequestHandler of the context when it is a BackgroundTracker, or in a new goroutine otherwise
+func (ctx *EMFContextType) Go(f func()) {
+	if tracker, ok := ctx.RequestHandler.(BackgroundTracker); ok {
+		tracker.Go(f)
+		return
+	}
+	go f()
+}
+
+// Call forwards to the RequestHandler of the context when it is a ResponseRequester
+func (ctx *EMFContextType) Call(method, component, path string, input interface{}) (*Response, error) {
+	var rr, ok = ctx.RequestHandler.(ResponseRequester)
+	if !ok {
+		return nil, fmt.Errorf("the RequestHandler of the context does not return responses")
+	}
+	return rr.Call(method, component, path, input)
+}
+
+// Send forwards to the RequestHandler of the context when it is a ResponseRequester
+func (ctx *EMFContextType) Send(request *http.Request) (*Response, error) {
+	var rr, ok = ctx.RequestHandler.(ResponseRequester)
+	if !ok {
+		return nil, fmt.Errorf("the RequestHandler of the context does not return responses")
+	}
+	return rr.Send(request)
+}
+
 // GetRequestHandler is a getter for the RequestHandler object embedded in the context
 func (ctx *EMFContextType) GetRequestHandler() RequestHandler {
 	return ctx.RequestHandler
@@ -118,7 +248,7 @@ func (ctx *EMFContextType) GetClaim(claim string) (c string, err error) {
 		i      interface{}
 	)
 
-	if token = ctx.Get("user").(*jwt.Token); token == nil {
+	if token, _ = ctx.Get("user").(*jwt.Token); token == nil {
 		err = fmt.Errorf("invalid JWT Token in Context, run Auth middleware first")
 		return
 	}
@@ -152,11 +282,63 @@ func (ctx *EMFContextType) GetClaim(claim string) (c string, err error) {
 	}
 }
 
+// GetService returns the name of the calling component when the request was authenticated with a
+// service token, or "" for user tokens. The rol claim of service tokens is "svc-" followed by this name.
+func (ctx *EMFContextType) GetService() string {
+	var svc, err = ctx.GetClaim(ServiceClaim)
+	if err != nil {
+		return ""
+	}
+	return svc
+}
+
+// GetScopes returns the scopes granted to the token of the request, from its introspection result
+// when the token was introspected, or else from the scope or scp claim of the JWT
+func (ctx *EMFContextType) GetScopes() []string {
+	var claims = ctx.GetIntrospectionClaims()
+	if claims == nil {
+		if token, ok := ctx.Get("user").(*jwt.Token); ok && token != nil {
+			claims, _ = token.Claims.(jwt.MapClaims)
+		}
+	}
+
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	var scopes []string
+	if scp, ok := claims["scp"].([]interface{}); ok {
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+	return scopes
+}
+
+// GetIntrospectionClaims returns every member of the introspection result of the token of the request,
+// or nil when the token was not introspected
+func (ctx *EMFContextType) GetIntrospectionClaims() map[string]interface{} {
+	var claims, _ = ctx.Get(IntrospectionKey).(map[string]interface{})
+	return claims
+}
+
 // GetRequestID is a helper function to expose the RequestID Header
 func (ctx *EMFContextType) GetRequestID() string {
 	return ctx.Response().Header().Get(echo.HeaderXRequestID)
 }
 
+// GetClientCertificate returns the client certificate verified during the TLS handshake,
+// or nil when the request was not made over mutual TLS
+func (ctx *EMFContextType) GetClientCertificate() *x509.Certificate {
+	var state = ctx.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
 // GetRequestLimited is the method to set up a HTTP Get request with no body and a limit Query Parameter
 func (ctx *EMFContextType) GetRequestLimited(domain, path string) (req *http.Request, err error) {
 	var limit, offset int
@@ -0,0 +1,48 @@
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+// tracker records the goroutines it is asked to run, running them inline
+type tracker struct{ runs int }
+
+func (t *tracker) Go(f func()) {
+	t.runs++
+	f()
+}
+
+func TestContextGo(t *testing.T) {
+	var c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	var tr = &tracker{}
+
+	// A context handed to code expecting a RequestHandler still tracks its goroutines
+	var rh RequestHandler = NewEMFContext(c, viper.New(), WithRequestHandlerOptions(WithBackgroundTracker(tr)))
+	var bt, ok = rh.(BackgroundTracker)
+	if !ok {
+		t.Fatal("expected the context to be a BackgroundTracker")
+	}
+
+	var ran bool
+	bt.Go(func() { ran = true })
+	if !ran || tr.runs != 1 {
+		t.Errorf("expected the goroutine to be run by the tracker, got %d runs", tr.runs)
+	}
+}
+
+func TestDeprecatedContextOptions(t *testing.T) {
+	var c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	var tr = &tracker{}
+
+	// The options replaced by WithRequestHandlerOptions still configure the RequestHandler
+	var ctx = NewEMFContext(c, viper.New(), WithContextTracker(tr))
+	ctx.Go(func() {})
+	if tr.runs != 1 {
+		t.Errorf("expected the goroutine to be run by the tracker, got %d runs", tr.runs)
+	}
+}
@@ -2,9 +2,13 @@ package errors
 
 import "net/http"
 
-// nolint: lll
 func getBuiltin(code string) (cbe EMFErrorType, ok bool) {
-	cbe, ok = map[string]EMFErrorType{
+	return builtinCatalog.Lookup(code)
+}
+
+// nolint: lll
+func builtinDefinitions() map[string]EMFErrorType {
+	return map[string]EMFErrorType{
 		"emf.400.QueryParameterInvalid": {
 			ErrorCode:   "emf.400.QueryParameterInvalid",
 			StatusCode:  http.StatusBadRequest,
@@ -39,6 +43,29 @@ func getBuiltin(code string) (cbe EMFErrorType, ok bool) {
 				"en": "The request parameters are invalid. Error: '{{.Data.Error}}'",
 			},
 		},
+		"emf.400.RequestBodyInvalid": {
+			ErrorCode:   "emf.400.RequestBodyInvalid",
+			StatusCode:  http.StatusBadRequest,
+			Description: "The request body could not be decoded.",
+			Message: map[string]string{
+				"en": "The request body is malformed or uses an unsupported content type. Error: '{{.Data.Error}}'",
+			},
+			Data: map[string]interface{}{
+				"Error": "The error raised while decoding the request body.",
+			},
+		},
+		"emf.400.RequestValidationFailure": {
+			ErrorCode:   "emf.400.RequestValidationFailure",
+			StatusCode:  http.StatusBadRequest,
+			Description: "The request was decoded but failed validation.",
+			Message: map[string]string{
+				"en": "The request failed validation on the fields '{{.Data.Fields}}'. Error: '{{.Data.Error}}'",
+			},
+			Data: map[string]interface{}{
+				"Fields": "The fields that failed validation.",
+				"Error":  "The error raised by the validator.",
+			},
+		},
 		"emf.401.Unauthorized": {
 			ErrorCode:   "emf.401.Unauthorized",
 			StatusCode:  http.StatusUnauthorized,
@@ -101,6 +128,17 @@ please try again. Error: '{{.Data.error}}'`,
 				"Value": "Value associated with JWT token property.",
 			},
 		},
+		"emf.401.APIKeyInvalid": {
+			ErrorCode:   "emf.401.APIKeyInvalid",
+			StatusCode:  http.StatusUnauthorized,
+			Description: "The API key provided with the request is unknown or could not be verified, so the API Request could not be completed.",
+			Message: map[string]string{
+				"en": "The provided API key could not be verified. Error: '{{.Data.Error}}'",
+			},
+			Data: map[string]interface{}{
+				"Error": "Error found when verifying the API key.",
+			},
+		},
 		"emf.401.UnauthorizedCaller": {
 			ErrorCode:   "emf.401.UnauthorizedCaller",
 			StatusCode:  http.StatusUnauthorized,
@@ -169,6 +207,53 @@ please try again. Error: '{{.Data.error}}'`,
 				"Error": "HTTP client error",
 			},
 		},
-	}[code]
-	return
+		"emf.502.UpstreamErrorResponse": {
+			ErrorCode:   "emf.502.UpstreamErrorResponse",
+			StatusCode:  http.StatusBadGateway,
+			Description: "The HTTP Request to an external API authorized with an oauth2 profile failed with an error response that is not an EMFError. The status code of the response is kept.",
+			Message: map[string]string{
+				"en": "The request to '{{.Data.URL}}' failed with status '{{.Data.Status}}'. Response: '{{.Data.Response}}'",
+			},
+			Data: map[string]interface{}{
+				"Status":   "Status code of the error response.",
+				"URL":      "URL of the failed request.",
+				"Response": "Body of the error response.",
+			},
+		},
+		"emf.503.ComponentUnavailable": {
+			ErrorCode:   "emf.503.ComponentUnavailable",
+			StatusCode:  http.StatusServiceUnavailable,
+			Description: "The internal HTTP Request was not sent because the circuit breaker of the target component is open after too many failed requests.",
+			Message: map[string]string{
+				"en": "The component '{{.Data.Component}}' is unavailable, please retry in '{{.Data.RetryIn}}'",
+			},
+			Data: map[string]interface{}{
+				"Component": "Name of the component the request was sent to.",
+				"RetryIn":   "Time left before the circuit breaker lets requests through again.",
+			},
+		},
+		"emf.503.RevocationListUnavailable": {
+			ErrorCode:   "emf.503.RevocationListUnavailable",
+			StatusCode:  http.StatusServiceUnavailable,
+			Description: "The token revocation list could not be read or updated, so the API Request could not be completed.",
+			Message: map[string]string{
+				"en": "The token revocation list is unavailable, please retry later. Error: '{{.Data.Error}}'",
+			},
+			Data: map[string]interface{}{
+				"Error": "Error returned by the cache holding the revocation list.",
+			},
+		},
+		"emf.503.WorkerQueueFull": {
+			ErrorCode:   "emf.503.WorkerQueueFull",
+			StatusCode:  http.StatusServiceUnavailable,
+			Description: "The background job could not be accepted because the worker queue is full.",
+			Message: map[string]string{
+				"en": "The service is too busy to accept the '{{.Data.Job}}' job, please retry later. Queue size: '{{.Data.QueueSize}}'",
+			},
+			Data: map[string]interface{}{
+				"Job":       "The name of the rejected job.",
+				"QueueSize": "The capacity of the worker queue.",
+			},
+		},
+	}
 }
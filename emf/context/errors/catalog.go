@@ -0,0 +1,151 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+// Catalog is a set of EMFError definitions whose message templates have been parsed ahead of time.
+// The definitions are read-only once compiled, so a single Catalog can be shared by every EMFErrorHandlerType.
+// Replace swaps them as a whole and atomically, so a lookup sees either the previous or the next definitions.
+type Catalog struct {
+	// entries holds a map[string]catalogEntry that is never modified once stored
+	entries atomic.Value
+}
+
+type catalogEntry struct {
+	code       string
+	definition EMFErrorType
+	templates  map[string]*template.Template
+}
+
+// builtinCatalog holds the compiled builtin errors, which are consulted before any loaded Catalog
+var builtinCatalog = mustCompileCatalog(builtinDefinitions())
+
+// LoadCatalog reads the errors yaml file at path and compiles every error definition found in it.
+func LoadCatalog(path string) (c *Catalog, err error) {
+	var templates configurer.Config
+
+	if templates, err = configurer.ReadConfig(path, "errors"); err != nil {
+		return nil, fmt.Errorf("failed to read errors file '%s': %w", path, err)
+	}
+
+	var definitions = map[string]EMFErrorType{}
+	for _, code := range definitionCodes(templates.AllSettings(), "") {
+		var e EMFErrorType
+		if err = templates.UnmarshalKey(code, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode error '%s': %w", code, err)
+		}
+		definitions[code] = e
+	}
+
+	return compileCatalog(definitions)
+}
+
+// definitionCodes walks the nested settings map and returns the path of every node that defines a message
+func definitionCodes(settings map[string]interface{}, prefix string) (codes []string) {
+	if _, ok := settings["message"]; ok && prefix != "" {
+		return []string{prefix}
+	}
+
+	for key, val := range settings {
+		if child, ok := val.(map[string]interface{}); ok {
+			codes = append(codes, definitionCodes(child, strings.TrimPrefix(prefix+"."+key, "."))...)
+		}
+	}
+	return
+}
+
+func compileCatalog(definitions map[string]EMFErrorType) (c *Catalog, err error) {
+	var entries = make(map[string]catalogEntry, len(definitions))
+
+	for code, e := range definitions {
+		var entry = catalogEntry{
+			code:       code,
+			definition: e,
+			templates:  make(map[string]*template.Template, len(e.Message)),
+		}
+		for language, message := range e.Message {
+			if entry.templates[language], err = template.New(language).Option("missingkey=zero").Parse(message); err != nil {
+				return nil, fmt.Errorf("failed to parse '%s' message template for error '%s': %w", language, code, err)
+			}
+		}
+		entries[strings.ToLower(code)] = entry
+	}
+
+	c = &Catalog{}
+	c.entries.Store(entries)
+	return
+}
+
+// load returns the current definitions of the Catalog
+func (c *Catalog) load() map[string]catalogEntry {
+	var entries, _ = c.entries.Load().(map[string]catalogEntry)
+	return entries
+}
+
+func mustCompileCatalog(definitions map[string]EMFErrorType) *Catalog {
+	c, err := compileCatalog(definitions)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Lookup returns a copy of the error definition for code, ready to be populated and executed.
+// Codes are matched case-insensitively, in the same way as the errors yaml file is read.
+func (c *Catalog) Lookup(code string) (e EMFErrorType, ok bool) {
+	var entry catalogEntry
+
+	if c == nil {
+		return
+	}
+
+	if entry, ok = c.load()[strings.ToLower(code)]; !ok {
+		return
+	}
+
+	e = entry.definition
+	e.templates = entry.templates
+
+	// Execute overwrites the messages in place, so never hand out the shared map
+	e.Message = make(map[string]string, len(entry.definition.Message))
+	for language, message := range entry.definition.Message {
+		e.Message[language] = message
+	}
+	return
+}
+
+// Definition looks code up in the builtin errors first and then in the Catalog, in the same way as NewError
+func (c *Catalog) Definition(code string) (e EMFErrorType, ok bool) {
+	if e, ok = builtinCatalog.Lookup(code); ok {
+		return
+	}
+	return c.Lookup(code)
+}
+
+// Codes returns the sorted list of error codes defined in the Catalog
+func (c *Catalog) Codes() (codes []string) {
+	if c == nil {
+		return
+	}
+
+	for _, entry := range c.load() {
+		codes = append(codes, entry.code)
+	}
+
+	sort.Strings(codes)
+	return
+}
+
+// Replace atomically swaps the definitions of the Catalog for those of next, for example after the errors file
+// is reloaded. Neither set of definitions is modified. Errors already created keep the definitions they were
+// created with.
+func (c *Catalog) Replace(next *Catalog) {
+	c.entries.Store(next.load())
+}
@@ -8,10 +8,9 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
-
-	"github.com/cambridge-blockchain/emf/configurer"
 )
 
 // EMFErrorHandler is the Interface which EMFErrorHandlerType implements
@@ -36,7 +35,7 @@ type EMFError interface {
 // EMFErrorHandlerType is the EMF Context type that will be sent to each Handler on a request
 type EMFErrorHandlerType struct {
 	logger      echo.Logger
-	templates   configurer.ConfigReader
+	catalog     *Catalog
 	DebugMode   bool
 	Method      string
 	Path        string
@@ -56,6 +55,7 @@ type EMFErrorType struct {
 	// Not always Returned
 	stackTrace    string // TODO: Should this just wrap the error message itself?
 	internalError error
+	templates     map[string]*template.Template
 }
 
 // ToSimpleError is a method to convert a EMFErrorType into a SimpleErrorType
@@ -110,13 +110,28 @@ func WithLogger(l echo.Logger) HandlerOption {
 	return func(eh *EMFErrorHandlerType) { eh.logger = l }
 }
 
-// WithTemplate allows the caller to specify the Error Templates to use for an ErrorHandler
+// WithTemplate allows the caller to specify the Error Templates to use for an ErrorHandler.
+// The file is read and compiled on every call, so long-lived callers should load a Catalog once
+// with LoadCatalog and share it using WithCatalog instead. Load failures are logged with the Logger of the
+// ErrorHandler, so WithLogger must be applied first, or with the default logger when it has none.
 func WithTemplate(path string) HandlerOption {
 	return func(eh *EMFErrorHandlerType) {
-		eh.templates = configurer.LoadConfig(path, "errors")
+		var err error
+		if eh.catalog, err = LoadCatalog(path); err != nil {
+			if eh.logger == nil {
+				log.Errorf("error templates could not be loaded, using builtin errors only: %v", err)
+				return
+			}
+			eh.logger.Errorf("error templates could not be loaded, using builtin errors only: %v", err)
+		}
 	}
 }
 
+// WithCatalog allows the caller to specify a preloaded Catalog of Error Templates for an ErrorHandler
+func WithCatalog(c *Catalog) HandlerOption {
+	return func(eh *EMFErrorHandlerType) { eh.catalog = c }
+}
+
 // Is is a method for comparing errors. It leverages TypedErrors for loose comparisons between EMFErrors
 func (e EMFErrorType) Is(target error) bool {
 	switch err := target.(type) {
@@ -147,12 +162,16 @@ func (e *EMFErrorType) Error() string {
 	return e.Message["en"]
 }
 
-// Execute executes the Message Templates using the given ErrorHandler struct as input
+// Execute executes the Message Templates using the given ErrorHandler struct as input.
+// Templates precompiled by a Catalog are reused, any other message is parsed on the fly.
 func (e EMFErrorType) Execute(eh *EMFErrorHandlerType) (err error) {
 	for language, message := range e.Message {
-		t := template.Must(template.New(language).Parse(message))
+		t, ok := e.templates[language]
+		if !ok {
+			t = template.Must(template.New(language).Option("missingkey=zero").Parse(message))
+		}
 		buf := new(bytes.Buffer)
-		if err = t.Option("missingkey=zero").ExecuteTemplate(buf, language, eh); err != nil {
+		if err = t.Execute(buf, eh); err != nil {
 			return err
 		}
 		e.Message[language] = buf.String()
@@ -197,6 +216,11 @@ func (eh EMFErrorHandlerType) Logger() echo.Logger {
 	return eh.logger
 }
 
+// Catalog returns the Catalog of Error Templates used by the ErrorHandler, if any
+func (eh EMFErrorHandlerType) Catalog() *Catalog {
+	return eh.catalog
+}
+
 // NewError is a method used to generate and log an EMFError message using the configured template
 func (eh *EMFErrorHandlerType) NewError(code string, data map[string]interface{}, errors ...error) error {
 	var (
@@ -207,9 +231,7 @@ func (eh *EMFErrorHandlerType) NewError(code string, data map[string]interface{}
 	)
 
 	if e, ok = getBuiltin(code); !ok {
-		if err = eh.templates.UnmarshalKey(code, &e); err != nil {
-			return err
-		}
+		e, _ = eh.catalog.Lookup(code)
 	}
 
 	e.Timestamp = time.Now().Format(time.RFC3339)
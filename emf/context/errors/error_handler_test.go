@@ -1,8 +1,11 @@
 package errors
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/labstack/gommon/log"
@@ -18,6 +21,23 @@ func getLogger() (l *log.Logger) {
 	return
 }
 
+func TestWithTemplateLogsLoadFailure(t *testing.T) {
+	var out bytes.Buffer
+	var l = log.New("test")
+	l.SetOutput(&out)
+
+	eh := &EMFErrorHandlerType{}
+	WithLogger(l)(eh)
+	WithTemplate("testdata/missing.yaml")(eh)
+
+	if eh.Catalog() != nil {
+		t.Error("expected no Catalog when the templates cannot be loaded")
+	}
+	if !strings.Contains(out.String(), "error templates could not be loaded") {
+		t.Errorf("expected the load failure to be logged, got %q", out.String())
+	}
+}
+
 func TestErrorHandlerNewError(t *testing.T) {
 	eh := &EMFErrorHandlerType{
 		DebugMode: false,
@@ -137,3 +157,101 @@ func TestErrorHandlerNewError(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCatalogNewError(t *testing.T) {
+	cat, err := LoadCatalog(TemplatePath)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %s", err)
+	}
+
+	eh := &EMFErrorHandlerType{}
+	WithLogger(getLogger())(eh)
+	WithCatalog(cat)(eh)
+
+	err1 := eh.NewError("test.400.ValueInvalid", map[string]interface{}{
+		"Value": "first",
+	})
+	err2 := eh.NewError("test.400.ValueInvalid", map[string]interface{}{
+		"Value": "second",
+	})
+
+	if err1.Error() != "The value 'first' is invalid." {
+		t.Errorf("unexpected message for the first error: '%s'", err1.Error())
+	}
+	if err2.Error() != "The value 'second' is invalid." {
+		t.Errorf("unexpected message for the second error, the catalog was modified: '%s'", err2.Error())
+	}
+	if !errors.Is(err1, ErrorType("test.400.ValueInvalid")) {
+		t.Log("test.400.ValueInvalid Error is NOT equal to ErrorType(test.400.ValueInvalid)")
+		t.Fail()
+	}
+}
+
+func TestCatalogReplace(t *testing.T) {
+	var define = func(message string) *Catalog {
+		return mustCompileCatalog(map[string]EMFErrorType{
+			"test.400.ValueInvalid": {StatusCode: 400, Message: map[string]string{"en": message}},
+		})
+	}
+	var cat, next = define("before"), define("after")
+
+	// Lookups running while the definitions are swapped see either set as a whole
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if e, ok := cat.Lookup("test.400.ValueInvalid"); !ok || (e.Message["en"] != "before" && e.Message["en"] != "after") {
+				t.Errorf("unexpected definition %+v", e)
+				return
+			}
+		}
+	}()
+	cat.Replace(next)
+	<-done
+
+	if e, _ := cat.Lookup("test.400.ValueInvalid"); e.Message["en"] != "after" {
+		t.Errorf("expected the replaced definition, got %q", e.Message["en"])
+	}
+
+	// The definitions of the next Catalog are shared, not modified
+	cat.Replace(define("again"))
+	if e, _ := next.Lookup("test.400.ValueInvalid"); e.Message["en"] != "after" {
+		t.Errorf("expected the next Catalog to be unchanged, got %q", e.Message["en"])
+	}
+}
+
+func getQuietLogger() (l *log.Logger) {
+	l = log.New("bench")
+	l.SetOutput(ioutil.Discard)
+	l.SetLevel(log.OFF)
+	return
+}
+
+// BenchmarkNewErrorWithTemplate measures the per-request cost of reading the errors file for every handler
+func BenchmarkNewErrorWithTemplate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		eh := &EMFErrorHandlerType{}
+		WithLogger(getQuietLogger())(eh)
+		WithTemplate(TemplatePath)(eh)
+		_ = eh.NewError("test.400.ValueInvalid", map[string]interface{}{"Value": i}) // nolint: errcheck
+	}
+}
+
+// BenchmarkNewErrorWithCatalog measures the per-request cost when every handler shares one Catalog
+func BenchmarkNewErrorWithCatalog(b *testing.B) {
+	cat, err := LoadCatalog(TemplatePath)
+	if err != nil {
+		b.Fatalf("failed to load catalog: %s", err)
+	}
+	logger := getQuietLogger()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eh := &EMFErrorHandlerType{}
+		WithLogger(logger)(eh)
+		WithCatalog(cat)(eh)
+		_ = eh.NewError("test.400.ValueInvalid", map[string]interface{}{"Value": i}) // nolint: errcheck
+	}
+}
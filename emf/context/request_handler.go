@@ -2,26 +2,72 @@ package context
 
 import (
 	"bytes"
-	"encoding/gob"
+	gocontext "context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/balancer"
+	"github.com/cambridge-blockchain/emf/emf/breaker"
+	"github.com/cambridge-blockchain/emf/emf/codec"
 	"github.com/cambridge-blockchain/emf/emf/context/errors"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+	"github.com/cambridge-blockchain/emf/emf/retry"
 )
 
 // RequestHandlerType is the minimum struct for sending requests with Requester
 type RequestHandlerType struct {
-	cfg    configurer.ConfigReader
-	client Client
-	eh     *errors.EMFErrorHandlerType
-	header http.Header
+	cfg       configurer.ConfigReader
+	client    Client
+	eh        *errors.EMFErrorHandlerType
+	header    http.Header
+	tracker   BackgroundTracker
+	tokens    TokenSource
+	creds     CredentialsSource
+	retries   *retry.Policies
+	breakers  *breaker.Breakers
+	resolver  discovery.Resolver
+	balancers *balancer.Balancers
+}
+
+// componentKey is the request context key of the component a request is sent to by Requester
+type componentKey struct{}
+
+// externalKey is the request context key marking requests authorized with the credentials of an external API
+type externalKey struct{}
+
+// targetKey is the request context key of the endpoints a request can be sent to, when its component has several
+type targetKey struct{}
+
+// target holds the endpoints of a component, base being the URL of the endpoint the request was built for
+type target struct {
+	base      string
+	endpoints []discovery.Endpoint
+}
+
+// BackgroundTracker starts goroutines that must finish before the service shuts down. RequestHandlerType and
+// EMFContextType implement it, so callers holding a RequestHandler can type-assert for it.
+type BackgroundTracker interface {
+	Go(f func())
+}
+
+// TokenSource provides the service tokens sent to a component when a request carries no user token
+type TokenSource interface {
+	Token(audience string) (string, error)
+}
+
+// CredentialsSource provides the Authorization header of requests to external APIs, such as the access
+// tokens of oauth2.Profiles. ok is false for URLs it has no credentials for.
+type CredentialsSource interface {
+	Authorization(rawURL string) (header string, ok bool, err error)
 }
 
 // RequestHandler is the minimum method set for the Requester family of functions
@@ -49,6 +95,13 @@ type LoggerlessRequestHandler interface {
 	GetMaxLimit() (limit int)
 }
 
+// ResponseRequester is implemented by RequestHandlers returning the Response of a call rather than decoding it.
+// RequestHandlerType implements it, so callers holding a RequestHandler or an EMFContext can type-assert for it.
+type ResponseRequester interface {
+	Call(method, component, path string, input interface{}) (*Response, error)
+	Send(request *http.Request) (*Response, error)
+}
+
 // RHOption provides the client a callback that is used to dynamically specify attributes for a
 // RequestHandler.
 type RHOption func(*RequestHandlerType)
@@ -75,26 +128,93 @@ func WithHeaders(headers http.Header) RHOption {
 	}
 }
 
+// WithBackgroundTracker is used to track goroutines started with Go, so they are drained on shutdown
+func WithBackgroundTracker(tracker BackgroundTracker) RHOption {
+	return func(rh *RequestHandlerType) { rh.tracker = tracker }
+}
+
+// WithServiceTokens is used to authenticate the Requester with a service token for the target component
+// when no Authorization header is set, such as outside of a user request
+func WithServiceTokens(tokens TokenSource) RHOption {
+	return func(rh *RequestHandlerType) { rh.tokens = tokens }
+}
+
+// WithCredentials is used to authorize requests to external APIs with their own credentials instead of
+// the Authorization header of the RequestHandler
+func WithCredentials(creds CredentialsSource) RHOption {
+	return func(rh *RequestHandlerType) { rh.creds = creds }
+}
+
+// WithRetries is used to send failed requests again, as configured per component by the Policies
+func WithRetries(p *retry.Policies) RHOption {
+	return func(rh *RequestHandlerType) { rh.retries = p }
+}
+
+// WithBreakers is used to fail fast on requests to components whose circuit breaker is open
+func WithBreakers(b *breaker.Breakers) RHOption {
+	return func(rh *RequestHandlerType) { rh.breakers = b }
+}
+
+// WithResolver is used to resolve the components called by the Requester with r instead of the domains block
+func WithResolver(r discovery.Resolver) RHOption {
+	return func(rh *RequestHandlerType) { rh.resolver = r }
+}
+
+// WithBalancers is used to spread the requests to components with several endpoints across them
+func WithBalancers(b *balancer.Balancers) RHOption {
+	return func(rh *RequestHandlerType) { rh.balancers = b }
+}
+
+// WithRHErrorCatalog is used to share a preloaded Catalog of Error Templates with the RequestHandler
+func WithRHErrorCatalog(c *errors.Catalog) RHOption {
+	return func(rh *RequestHandlerType) { errors.WithCatalog(c)(rh.eh) }
+}
+
 // NewRequestHandler is a variadic constructor for a RequestHandler.
 func NewRequestHandler(cfg configurer.ConfigReader, logger echo.Logger, opts ...RHOption) (rh *RequestHandlerType) {
 	rh = &RequestHandlerType{
-		cfg,
-		&http.Client{},
-		&errors.EMFErrorHandlerType{
+		cfg:    cfg,
+		client: &http.Client{},
+		eh: &errors.EMFErrorHandlerType{
 			DebugMode: false,
 		},
-		http.Header{},
+		header: http.Header{},
 	}
 
 	errors.WithLogger(logger)(rh.eh)
-	errors.WithTemplate(cfg.GetString("errors.configPath"))(rh.eh)
 
 	for _, opt := range opts {
 		opt(rh)
 	}
+
+	if rh.eh.Catalog() == nil {
+		errors.WithTemplate(cfg.GetString("errors.configPath"))(rh.eh)
+	}
 	return
 }
 
+// Detach returns a RequestHandler built from the config, logger and options of rh, sending header instead of
+// the headers of rh, such as the Authorization header forwarded from a request. It is used for work that
+// outlives the request of rh.
+func (rh RequestHandlerType) Detach(header http.Header) *RequestHandlerType {
+	var opts = []RHOption{
+		WithHTTPClient(rh.client),
+		WithHeaders(header),
+		WithBackgroundTracker(rh.tracker),
+		WithServiceTokens(rh.tokens),
+		WithCredentials(rh.creds),
+		WithRetries(rh.retries),
+		WithBreakers(rh.breakers),
+		WithResolver(rh.resolver),
+		WithBalancers(rh.balancers),
+		WithRHErrorCatalog(rh.eh.Catalog()),
+	}
+	if rh.eh.DebugMode {
+		opts = append(opts, WithDebugMode())
+	}
+	return NewRequestHandler(rh.cfg, rh.Logger(), opts...)
+}
+
 // ErrorHandler is a helper function to make an ErrorHandler object from the Context and return it
 func (rh RequestHandlerType) ErrorHandler() errors.EMFErrorHandler {
 	return rh.eh
@@ -115,9 +235,37 @@ func (rh RequestHandlerType) IsDebug() bool {
 	return rh.cfg.GetBool("debug.mode") || (rh.eh != nil && rh.eh.DebugMode)
 }
 
-// GetDomain is a helper function to expose fetching domains from the config file
+// GetDomain is a helper function to expose the base URL of a component, from the configured Resolver or else
+// the domains block of the config file. It returns "" for unresolvable components.
 func (rh RequestHandlerType) GetDomain(component string) (domain string) {
-	return rh.cfg.GetString("domains." + component)
+	domain, _ = rh.resolve(component)
+	return
+}
+
+// resolve returns the base URL of component, the first endpoint returned by the Resolver
+func (rh RequestHandlerType) resolve(component string) (string, error) {
+	var endpoints, err = rh.endpoints(component)
+	if err != nil {
+		return "", err
+	}
+	return endpoints[0].URL, nil
+}
+
+// endpoints returns the endpoints of component, from the Resolver or else the domains block
+func (rh RequestHandlerType) endpoints(component string) ([]discovery.Endpoint, error) {
+	var r = rh.resolver
+	if r == nil {
+		r = discovery.NewStatic(rh.cfg)
+	}
+
+	var endpoints, err = r.Resolve(component)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoint was found for component '%s'", component)
+	}
+	return endpoints, nil
 }
 
 // GetMaxLimit is a helper function to expose fetching the maximum limit from the config file
@@ -138,6 +286,15 @@ func (rh RequestHandlerType) GetDefaultLimit() (limit int) {
 	return limit
 }
 
+// Go runs f in a new goroutine, tracked by the configured BackgroundTracker when there is one
+func (rh RequestHandlerType) Go(f func()) {
+	if rh.tracker != nil {
+		rh.tracker.Go(f)
+		return
+	}
+	go f()
+}
+
 // Header is a helper function to expose the http.Header object
 func (rh RequestHandlerType) Header() http.Header {
 	return rh.header
@@ -186,6 +343,176 @@ func DecodeEMFError(r io.Reader, eh errors.EMFErrorHandler) (e *errors.EMFErrorT
 	}).(*errors.EMFErrorType)
 }
 
+// do sends req, then sends it again while the retry policy allows it. Attempts to a component whose circuit
+// breaker is open are not sent and return a *breaker.OpenError.
+func (rh RequestHandlerType) do(req *http.Request) (res *http.Response, err error) {
+	var component, _ = req.Context().Value(componentKey{}).(string)
+	var b = rh.breakers.For(component)
+	if rh.retries == nil {
+		return rh.attempt(b, req)
+	}
+
+	var r = rh.retries.For(component)
+	r.Begin()
+
+	for attempt := 1; ; attempt++ {
+		if res, err = rh.attempt(b, req); err != nil {
+			if _, open := err.(*breaker.OpenError); open {
+				r.Done(attempt)
+				return nil, err
+			}
+		}
+
+		var delay, reason, ok = r.Retry(req, res, err, attempt)
+		if !ok {
+			r.Done(attempt)
+			if attempt > 1 {
+				rh.Logger().Infof("%s %s to '%s' finished after %d attempts", req.Method, req.URL.Path, component, attempt)
+			}
+			return res, err
+		}
+
+		rh.Logger().Warnf("%s %s to '%s' failed (%s), sending attempt %d in %s",
+			req.Method, req.URL.Path, component, reason, attempt+1, delay)
+		if res != nil {
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+			res.Body.Close()
+		}
+
+		var timer = time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			r.Done(attempt)
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req, err = rewind(req); err != nil {
+			r.Done(attempt)
+			return nil, err
+		}
+	}
+}
+
+// attempt sends req once through the circuit breaker b, to the endpoint picked by the balancer of its component.
+// Transport errors and 5xx responses count as failures, unless the request was canceled by the caller.
+func (rh RequestHandlerType) attempt(b *breaker.Breaker, req *http.Request) (*http.Response, error) {
+	var done, err = b.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	var release func(failed bool)
+	if req, release, err = rh.route(req); err != nil {
+		done(false)
+		return nil, err
+	}
+
+	var res *http.Response
+	var failed bool
+	if res, err = rh.client.Do(req); err != nil {
+		failed = req.Context().Err() == nil
+	} else {
+		failed = res.StatusCode >= http.StatusInternalServerError
+	}
+	done(failed)
+	release(failed)
+	return res, err
+}
+
+// route returns a copy of req sent to the endpoint picked by the balancer of its component, when the component
+// has several endpoints, and a callback reporting whether the request failed.
+func (rh RequestHandlerType) route(req *http.Request) (*http.Request, func(failed bool), error) {
+	var t, ok = req.Context().Value(targetKey{}).(target)
+	var component, _ = req.Context().Value(componentKey{}).(string)
+	var lb = rh.balancers.For(component)
+	if !ok || lb == nil {
+		return req, func(bool) {}, nil
+	}
+
+	var picked, release = lb.Pick(t.endpoints)
+	if picked.URL == t.base {
+		return req, release, nil
+	}
+
+	var base, endpoint *url.URL
+	var err error
+	if base, err = url.Parse(t.base); err == nil {
+		endpoint, err = url.Parse(picked.URL)
+	}
+	if err != nil {
+		release(true)
+		return nil, nil, fmt.Errorf("invalid endpoint of component '%s': %w", component, err)
+	}
+
+	// Keep the path under the base URL and the query of the request
+	var next = req.Clone(req.Context())
+	next.URL.Scheme, next.URL.Host, next.Host = endpoint.Scheme, endpoint.Host, ""
+	var path = strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(base.Path, "/"))
+	next.URL.Path = strings.TrimSuffix(endpoint.Path, "/") + path
+	next.URL.RawPath = ""
+	return next, release, nil
+}
+
+// rewind returns a copy of req with a fresh body, so it can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	var next = req.Clone(req.Context())
+	if req.GetBody != nil {
+		var body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// decodeErrorResponse reads an error response as an EMFError. When the request was authorized with the
+// credentials of an external API, a body that is not an EMFError is read as an emf.502.UpstreamErrorResponse
+// keeping the status code of the response. Other responses are decoded by DecodeEMFError.
+func decodeErrorResponse(req *http.Request, res *http.Response, eh errors.EMFErrorHandler) error {
+	if external, _ := req.Context().Value(externalKey{}).(bool); !external {
+		return DecodeEMFError(res.Body, eh)
+	}
+
+	var body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return eh.NewError("emf.500.RequesterErrorResponseFailure", map[string]interface{}{
+			"Response": body,
+			"Error":    err,
+		})
+	}
+
+	var e *errors.EMFErrorType
+	if err = json.Unmarshal(body, &e); err == nil && e != nil && e.ErrorCode != "" {
+		return e
+	}
+
+	var serr errors.SimpleErrorType
+	if err = json.Unmarshal(body, &serr); err == nil && serr.Error.ErrorCode != "" {
+		return serr.ToEMFError()
+	}
+
+	// Keep JSON bodies structured, and anything else as text
+	var response interface{} = string(body)
+	var decoded interface{}
+	if json.Unmarshal(body, &decoded) == nil {
+		response = decoded
+	}
+
+	var upstream = eh.NewError("emf.502.UpstreamErrorResponse", map[string]interface{}{
+		"Status":   res.StatusCode,
+		"URL":      req.URL.String(),
+		"Response": response,
+	})
+	if ue, ok := upstream.(*errors.EMFErrorType); ok {
+		ue.StatusCode = res.StatusCode
+	}
+	return upstream
+}
+
 // EncodingJSON is the JSON Input encoding string for c.Requester
 const EncodingJSON = "JSON"
 
@@ -198,6 +525,8 @@ const MethodPostGob = "POST-GOB"
 // MethodPutGob is the HTTP Method string to enable Gob encoding for a POST request
 const MethodPutGob = "PUT-GOB"
 
+// encodeInput encodes input with the codec of encodingType, which is EncodingJSON, EncodingGob or the media type
+// of a codec registered in codec.Default
 func encodeInput(encodingType string, input interface{}) (body io.Reader, contentType string, err error) {
 	var (
 		serializer bytes.Buffer
@@ -207,28 +536,29 @@ func encodeInput(encodingType string, input interface{}) (body io.Reader, conten
 
 	switch encodingType {
 	case EncodingJSON:
-		contentType = "application/json"
-		if input == nil {
-			return
-		}
-		if err = json.NewEncoder(&serializer).Encode(input); err != nil {
-			return
-		}
-
-		return
+		encodingType = codec.MIMEJSON
 	case EncodingGob:
-		contentType = "application/x-gob"
+		// The concrete input is encoded, so the binder can decode it into the struct of the receiving handler
+		contentType = codec.MIMEGob
 		if input == nil {
 			return
 		}
-		if err = gob.NewEncoder(&serializer).Encode(&input); err != nil {
-			return
-		}
+		err = codec.Gob{}.Encode(&serializer, input)
 		return
-	default:
+	}
+
+	var c, ok = codec.Default.Get(encodingType)
+	if !ok {
 		err = fmt.Errorf("invalid encoding type specified '%s'", encodingType)
 		return
 	}
+
+	contentType = c.ContentType()
+	if input == nil {
+		return
+	}
+	err = c.Encode(&serializer, input)
+	return
 }
 
 func streamCloser(body io.Closer) {
@@ -249,13 +579,26 @@ func (rh RequestHandlerType) Requester(
 	input interface{},
 	output interface{},
 ) (err error) {
+	var res *Response
+	if res, err = rh.Call(method, component, path, input); err != nil {
+		return
+	}
+	return res.Decode(output)
+}
+
+// Call sends a request to a component like Requester, but returns the Response instead of decoding it, so its
+// status code and headers can be read and its body streamed. GET and HEAD requests have no body, POST-GOB and
+// PUT-GOB requests a Gob body, and requests of any other method, DELETE included, a JSON body when input is set.
+// Responses with a status code of 400 or more are returned as EMFErrors, as by Requester.
+func (rh RequestHandlerType) Call(method, component, path string, input interface{}) (res *Response, err error) {
 	// Get and Check the Domain
-	var domain string
-	if domain = rh.GetDomain(component); domain == "" {
-		return rh.NewError("emf.500.RequesterCreateRequestFailure", map[string]interface{}{
-			"Error": fmt.Errorf("get Domain Error: component '%s' was not configured", component),
+	var endpoints []discovery.Endpoint
+	if endpoints, err = rh.endpoints(component); err != nil {
+		return nil, rh.NewError("emf.500.RequesterCreateRequestFailure", map[string]interface{}{
+			"Error": fmt.Errorf("get Domain Error: %w", err),
 		})
 	}
+	var domain = endpoints[0].URL
 
 	var req *http.Request
 
@@ -264,6 +607,10 @@ func (rh RequestHandlerType) Requester(
 		if req, err = rh.GetRequest(domain, path); err != nil {
 			return
 		}
+	case http.MethodHead:
+		if req, err = rh.InitRequest(EncodingJSON, method, domain, path, nil); err != nil {
+			return
+		}
 	case MethodPostGob:
 		if req, err = rh.GobRequest(http.MethodPost, domain, path, input); err != nil {
 			return
@@ -278,7 +625,31 @@ func (rh RequestHandlerType) Requester(
 		}
 	}
 
-	return rh.SendRequest(req, output)
+	if err = rh.authorize(req, component); err != nil {
+		return
+	}
+
+	var ctx = gocontext.WithValue(req.Context(), componentKey{}, component)
+	if len(endpoints) > 1 {
+		ctx = gocontext.WithValue(ctx, targetKey{}, target{base: domain, endpoints: endpoints})
+	}
+	return rh.Send(req.WithContext(ctx))
+}
+
+// authorize sets a service token for component on requests without an Authorization header
+func (rh RequestHandlerType) authorize(req *http.Request, component string) error {
+	if rh.tokens == nil || req.Header.Get(echo.HeaderAuthorization) != "" {
+		return nil
+	}
+
+	var token, err = rh.tokens.Token(component)
+	if err != nil {
+		return rh.NewError("emf.500.RequesterCreateRequestFailure", map[string]interface{}{
+			"Error": fmt.Errorf("failed to get a service token for component '%s': %w", component, err),
+		})
+	}
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	return nil
 }
 
 // JSONRequest is the method to set up a JSON-encoded HTTP Request
@@ -348,38 +719,74 @@ func (rh RequestHandlerType) InitRequest(
 		}
 	}
 
+	// External APIs get their own credentials rather than the token of the caller
+	if rh.creds != nil {
+		var auth, ok, cerr = rh.creds.Authorization(req.URL.String())
+		if cerr != nil {
+			return nil, rh.NewError("emf.500.RequesterCreateRequestFailure", map[string]interface{}{
+				"Error": cerr,
+			})
+		}
+		if ok {
+			req.Header.Set(echo.HeaderAuthorization, auth)
+			req = req.WithContext(gocontext.WithValue(req.Context(), externalKey{}, true))
+		}
+	}
+
 	// Add default request headers
 	req.Header.Add(echo.HeaderContentType, contentType)
+	if req.Header.Get(echo.HeaderAccept) == "" && contentType != codec.MIMEJSON && contentType != codec.MIMEGob {
+		// Components answer in the media type of the request when they support it, in JSON otherwise
+		req.Header.Set(echo.HeaderAccept, contentType+", "+codec.MIMEJSON+";q=0.9")
+	}
 	req.Header.Add(echo.HeaderContentEncoding, echo.MIMEApplicationJavaScriptCharsetUTF8)
 
 	return
 }
 
-// SendRequest is a method to send HTTP client requests to other components
+// SendRequest is a method to send HTTP client requests to other components.
+// Failed requests are sent again as allowed by the retry policy of the component they were sent to by Requester,
+// or by the default policy. Requests to a component whose circuit breaker is open fail fast with an
+// emf.503.ComponentUnavailable error.
 func (rh RequestHandlerType) SendRequest(
 	req *http.Request,
 	output interface{},
 ) (err error) {
+	var res *Response
+	if res, err = rh.Send(req); err != nil {
+		return
+	}
+	return res.Decode(output)
+}
+
+// Send sends req like SendRequest, but returns the Response instead of decoding it. Responses with a status
+// code of 400 or more are closed and returned as EMFErrors.
+func (rh RequestHandlerType) Send(req *http.Request) (*Response, error) {
 	// Send the Request
-	var res *http.Response
-	if res, err = rh.client.Do(req); err != nil {
-		return rh.NewError("emf.500.RequesterSendRequestFailure", map[string]interface{}{
+	var res, err = rh.do(req)
+	if err != nil {
+		if oe, open := err.(*breaker.OpenError); open {
+			return nil, rh.NewError("emf.503.ComponentUnavailable", map[string]interface{}{
+				"Component": oe.Component,
+				"RetryIn":   oe.RetryIn.Round(time.Millisecond).String(),
+			})
+		}
+		return nil, rh.NewError("emf.500.RequesterSendRequestFailure", map[string]interface{}{
 			"Error": err,
 		})
 	}
-	defer streamCloser(res.Body)
 
 	// Handle Errors
 	if res.StatusCode >= http.StatusBadRequest {
-		return DecodeEMFError(res.Body, rh.ErrorHandler())
+		defer streamCloser(res.Body)
+		return nil, decodeErrorResponse(req, res, rh.ErrorHandler())
 	}
 
-	// Decode Response
-	if err = json.NewDecoder(res.Body).Decode(output); err != nil {
-		return rh.NewError("emf.500.RequesterDecodingFailure", map[string]interface{}{
-			"Error": err,
-		})
-	}
-
-	return
+	return &Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       res.Body,
+		method:     req.Method,
+		eh:         rh.eh,
+	}, nil
 }
@@ -0,0 +1,58 @@
+package context
+
+import (
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// credentials authorizes every request under its prefix
+type credentials string
+
+func (c credentials) Authorization(rawURL string) (string, bool, error) {
+	return "Bearer external", strings.HasPrefix(rawURL, string(c)), nil
+}
+
+func TestErrorResponses(t *testing.T) {
+	var s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/emf" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"ErrorCode":"ledger.404.AccountNotFound","StatusCode":404}`)) //nolint:errcheck
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"rate_limited"}`)) //nolint:errcheck
+	}))
+	defer s.Close()
+
+	var v = viper.New()
+	v.Set("domains.ledger", s.URL)
+	v.Set("domains.kyc", s.URL+"/kyc")
+
+	var cases = []struct {
+		name   string
+		path   string
+		code   string
+		status int
+	}{
+		{"EMFError", "/emf", "ledger.404.AccountNotFound", http.StatusNotFound},
+		{"component error", "/other", "emf.500.RequesterErrorResponseFailure", http.StatusInternalServerError},
+		{"external API error", "/kyc/checks", "emf.502.UpstreamErrorResponse", http.StatusTooManyRequests},
+	}
+
+	var rh = NewRequestHandler(v, log.New("test"), WithCredentials(credentials(s.URL+"/kyc")))
+	for _, c := range cases {
+		var err = rh.Requester(http.MethodGet, "ledger", c.path, nil, &struct{}{})
+		var e *errors.EMFErrorType
+		if !stderrors.As(err, &e) || e.ErrorCode != c.code || e.StatusCode != c.status {
+			t.Errorf("%s: expected %s with status %d, got %#v", c.name, c.code, c.status, err)
+		}
+	}
+}
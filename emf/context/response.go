@@ -0,0 +1,70 @@
+package context
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/cambridge-blockchain/emf/emf/codec"
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// Response is a successful response to a request sent with Call or Send. Its Body streams the response and
+// must be closed, either by the caller or by Decode.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+
+	method string
+	eh     errors.EMFErrorHandler
+}
+
+// Decode reads the body into output with the codec of its Content-Type, JSON being the default, and closes it.
+// Responses without a body, such as HEAD, 204 No Content and 304 Not Modified responses, leave output
+// unchanged, as does a nil output. Decoding failures are returned as emf.500.RequesterDecodingFailure EMFErrors.
+func (r *Response) Decode(output interface{}) (err error) {
+	defer streamCloser(r.Body)
+
+	if output == nil || !r.HasBody() {
+		r.discard()
+		return nil
+	}
+
+	// Empty bodies are accepted whatever the status code
+	var body = bufio.NewReader(r.Body)
+	if _, err = body.Peek(1); err == io.EOF {
+		return nil
+	}
+
+	var dec, ok = codec.Default.Get(r.Header.Get(echo.HeaderContentType))
+	if !ok {
+		dec = codec.JSON{}
+	}
+	if err = dec.Decode(body, output); err != nil {
+		return r.eh.NewError("emf.500.RequesterDecodingFailure", map[string]interface{}{
+			"Error": err,
+		})
+	}
+	return nil
+}
+
+// HasBody reports whether the response can have a body, which HEAD, 204 No Content and 304 Not Modified
+// responses cannot
+func (r *Response) HasBody() bool {
+	return r.method != http.MethodHead && r.StatusCode != http.StatusNoContent &&
+		r.StatusCode != http.StatusNotModified
+}
+
+// Close closes the body of the response
+func (r *Response) Close() error {
+	return r.Body.Close()
+}
+
+// discard drains a bounded part of the body so the connection can be reused
+func (r *Response) discard() {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(r.Body, 1<<16))
+}
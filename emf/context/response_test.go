@@ -0,0 +1,138 @@
+package context
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// newEchoServer answers every request with its method and JSON body, and with the status of the status query.
+// A 404 status is answered with an EMFError.
+func newEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in interface{}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+
+		var status = http.StatusOK
+		switch r.URL.Query().Get("status") {
+		case "201":
+			status = http.StatusCreated
+			w.Header().Set("Location", "/accounts/1")
+		case "204":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "404":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"ErrorCode":"ledger.404.AccountNotFound","StatusCode":404}`)) //nolint:errcheck
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"method": r.Method, "input": in}) //nolint:errcheck
+	}))
+}
+
+func echoHandler(s *httptest.Server) *RequestHandlerType {
+	var v = viper.New()
+	v.Set("domains.ledger", s.URL)
+	return NewRequestHandler(v, log.New("test"))
+}
+
+func TestCallReturnsResponse(t *testing.T) {
+	var s = newEchoServer()
+	defer s.Close()
+	var rh = echoHandler(s)
+
+	var res, err = rh.Call(http.MethodPost, "ledger", "/accounts?status=201", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusCreated || res.Header.Get("Location") != "/accounts/1" {
+		t.Errorf("expected a 201 with a Location header, got %d '%s'", res.StatusCode, res.Header.Get("Location"))
+	}
+
+	// The body is streamed to the caller
+	var body []byte
+	if body, err = ioutil.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	res.Close()
+	if string(body) != `{"input":{"id":"1"},"method":"POST"}`+"\n" {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestCallMethods(t *testing.T) {
+	var s = newEchoServer()
+	defer s.Close()
+	var rh = echoHandler(s)
+
+	// DELETE and PATCH send their input
+	for _, method := range []string{http.MethodDelete, http.MethodPatch} {
+		var out struct {
+			Method string
+			Input  map[string]string
+		}
+		if err := rh.Requester(method, "ledger", "/accounts/1", map[string]string{"reason": "closed"}, &out); err != nil {
+			t.Fatalf("%s failed: %s", method, err)
+		}
+		if out.Method != method || out.Input["reason"] != "closed" {
+			t.Errorf("%s: expected the input to be sent, got %+v", method, out)
+		}
+	}
+
+	// Responses without a body leave the output unchanged
+	var empty = []struct{ method, path string }{{http.MethodHead, "/accounts/1"}, {http.MethodGet, "/?status=204"}}
+	for _, call := range empty {
+		var out = map[string]interface{}{"kept": true}
+		var res, err = rh.Call(call.method, "ledger", call.path, nil)
+		if err == nil {
+			err = res.Decode(&out)
+		}
+		if err != nil || out["kept"] != true {
+			t.Errorf("%s %s: expected the output to be unchanged, got %v, %v", call.method, call.path, out, err)
+		}
+	}
+}
+
+func TestCallErrorResponse(t *testing.T) {
+	var s = newEchoServer()
+	defer s.Close()
+
+	var _, err = echoHandler(s).Call(http.MethodGet, "ledger", "/?status=404", nil)
+	if e, ok := err.(*errors.EMFErrorType); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an EMFError with the status of the response, got %#v", err)
+	}
+}
+
+func TestContextCall(t *testing.T) {
+	var s = newEchoServer()
+	defer s.Close()
+
+	var v = viper.New()
+	v.Set("domains.ledger", s.URL)
+	var c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// A context handed to code expecting a RequestHandler still returns responses
+	var rh RequestHandler = NewEMFContext(c, v)
+	var rr, ok = rh.(ResponseRequester)
+	if !ok {
+		t.Fatal("expected the context to be a ResponseRequester")
+	}
+	var res, err = rr.Call(http.MethodGet, "ledger", "/?status=201", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("expected a 201, got %d", res.StatusCode)
+	}
+}
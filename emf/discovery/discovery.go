@@ -0,0 +1,215 @@
+// Package discovery resolves the components called with the Requester into the URLs of their endpoints, from
+// the domains config block, DNS SRV records or a watched registry file, so environments do not have to list
+// every URL in their config.
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+// Names of the resolvers of the discovery.resolver setting
+const (
+	ResolverStatic = "static"
+	ResolverSRV    = "srv"
+	ResolverFile   = "file"
+)
+
+const defaultCacheTTL = 30 * time.Second
+
+// ErrUnknownComponent is wrapped by the errors of Resolvers that have no endpoint for a component
+var ErrUnknownComponent = errors.New("unknown component")
+
+// Endpoint is an instance of a component
+type Endpoint struct {
+	// URL is the base URL the paths of the Requester are appended to
+	URL string `json:"url" mapstructure:"url"`
+	// Weight is the share of calls an endpoint gets relative to the others, 0 meaning the default of 1
+	Weight int `json:"weight,omitempty" mapstructure:"weight"`
+}
+
+// Resolver returns the endpoints of a component, in order of preference
+type Resolver interface {
+	Resolve(component string) ([]Endpoint, error)
+}
+
+// Notifier is implemented by Resolvers whose endpoints change over time. f is called with the name of
+// every component whose endpoints changed.
+type Notifier interface {
+	OnChange(f func(component string))
+}
+
+// Config is the discovery config block
+type Config struct {
+	// Resolver is static, srv or file
+	Resolver string `mapstructure:"resolver"`
+	// CacheTTL is how long the endpoints looked up in DNS are kept
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	SRV      struct {
+		Domain   string `mapstructure:"domain"`
+		Protocol string `mapstructure:"protocol"`
+		Scheme   string `mapstructure:"scheme"`
+	} `mapstructure:"srv"`
+	File struct {
+		Path string `mapstructure:"path"`
+	} `mapstructure:"file"`
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for the SRV and
+// File resolvers.
+type Option func(*settings)
+
+type settings struct {
+	fallback Resolver
+	ttl      time.Duration
+	protocol string
+	scheme   string
+	lookup   srvLookup
+	onError  func(error)
+}
+
+// WithFallback resolves the components unknown to the SRV records or registry file with r
+func WithFallback(r Resolver) Option {
+	return func(s *settings) { s.fallback = r }
+}
+
+// WithCacheTTL sets how long SRV records are cached, 30 seconds by default
+func WithCacheTTL(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.ttl = d
+		}
+	}
+}
+
+// WithProtocol sets the protocol of the SRV records looked up, tcp by default
+func WithProtocol(protocol string) Option {
+	return func(s *settings) {
+		if protocol != "" {
+			s.protocol = protocol
+		}
+	}
+}
+
+// WithScheme sets the scheme of the URLs built from SRV records, http by default
+func WithScheme(scheme string) Option {
+	return func(s *settings) {
+		if scheme != "" {
+			s.scheme = scheme
+		}
+	}
+}
+
+// WithErrorHandler sets the callback receiving the errors of background lookups and file reloads
+func WithErrorHandler(onError func(error)) Option {
+	return func(s *settings) { s.onError = onError }
+}
+
+func newSettings(opts []Option) *settings {
+	var s = &settings{ttl: defaultCacheTTL, protocol: "tcp", scheme: "http", onError: func(error) {}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Configure returns the Resolver selected by the discovery config block. The srv and file resolvers fall
+// back to the domains block for the components they do not know.
+func Configure(cfg configurer.ConfigReader, opts ...Option) (Resolver, error) {
+	var c Config
+	if err := cfg.UnmarshalKey("discovery", &c); err != nil {
+		return nil, fmt.Errorf("invalid discovery settings: %w", err)
+	}
+
+	var static = NewStatic(cfg)
+	opts = append([]Option{WithFallback(static), WithCacheTTL(c.CacheTTL)}, opts...)
+
+	switch c.Resolver {
+	case "", ResolverStatic:
+		return static, nil
+	case ResolverSRV:
+		if c.SRV.Domain == "" {
+			return nil, fmt.Errorf("the srv resolver requires discovery.srv.domain")
+		}
+		return NewSRV(c.SRV.Domain, append(opts, WithProtocol(c.SRV.Protocol), WithScheme(c.SRV.Scheme))...), nil
+	case ResolverFile:
+		if c.File.Path == "" {
+			return nil, fmt.Errorf("the file resolver requires discovery.file.path")
+		}
+		return NewFile(c.File.Path, opts...)
+	default:
+		return nil, fmt.Errorf("unknown discovery resolver '%s'", c.Resolver)
+	}
+}
+
+// Static resolves components from the domains config block, where a component maps to a URL, or to a list of
+// URLs or of endpoints with a url and a weight. The block is read on every call, so config reloads apply
+// immediately.
+type Static struct {
+	cfg configurer.ConfigReader
+}
+
+// NewStatic returns a Static resolver reading cfg
+func NewStatic(cfg configurer.ConfigReader) *Static {
+	return &Static{cfg: cfg}
+}
+
+// Resolve returns the domains entry of component
+func (s *Static) Resolve(component string) ([]Endpoint, error) {
+	if domain := s.cfg.GetString("domains." + component); domain != "" {
+		return []Endpoint{{URL: domain}}, nil
+	}
+
+	var list []interface{}
+	if err := s.cfg.UnmarshalKey("domains."+component, &list); err != nil || len(list) == 0 {
+		return nil, fmt.Errorf("%w '%s': it has no domains entry", ErrUnknownComponent, component)
+	}
+	return parseEndpoints(component, list)
+}
+
+// notifier keeps the callbacks of a Notifier
+type notifier struct {
+	mu        sync.Mutex
+	callbacks []func(component string)
+}
+
+// OnChange registers f to be called with the name of every component whose endpoints changed
+func (n *notifier) OnChange(f func(component string)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.callbacks = append(n.callbacks, f)
+}
+
+func (n *notifier) notify(component string) {
+	n.mu.Lock()
+	var callbacks = n.callbacks
+	n.mu.Unlock()
+
+	for _, f := range callbacks {
+		f(component)
+	}
+}
+
+// resolveFallback resolves component with the fallback Resolver, or returns err when there is none
+func (s *settings) resolveFallback(component string, err error) ([]Endpoint, error) {
+	if s.fallback == nil {
+		return nil, err
+	}
+	return s.fallback.Resolve(component)
+}
+
+// sameEndpoints reports whether two endpoint lists are the same
+func sameEndpoints(a, b []Endpoint) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// trimURL removes the trailing slash of a base URL, as Requester paths start with one
+func trimURL(url string) string {
+	return strings.TrimSuffix(url, "/")
+}
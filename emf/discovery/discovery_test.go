@@ -0,0 +1,94 @@
+package discovery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func domains() *viper.Viper {
+	var v = viper.New()
+	v.Set("domains", map[string]interface{}{
+		"ledger": "http://ledger:8080",
+		"users":  []interface{}{"http://users-1:8080/", "http://users-2:8080"},
+		"kyc": []interface{}{
+			map[string]interface{}{"url": "http://kyc-1:8080", "weight": 3},
+			map[string]interface{}{"url": "http://kyc-2:8080"},
+		},
+		"broken": []interface{}{map[string]interface{}{"weight": 1}},
+	})
+	return v
+}
+
+func TestStaticResolve(t *testing.T) {
+	var cases = []struct {
+		component string
+		expected  []Endpoint
+		unknown   bool
+	}{
+		{"ledger", []Endpoint{{URL: "http://ledger:8080"}}, false},
+		{"users", []Endpoint{{URL: "http://users-1:8080"}, {URL: "http://users-2:8080"}}, false},
+		{"kyc", []Endpoint{{URL: "http://kyc-1:8080", Weight: 3}, {URL: "http://kyc-2:8080"}}, false},
+		{"vault", nil, true},
+	}
+
+	var s = NewStatic(domains())
+	for _, c := range cases {
+		var endpoints, err = s.Resolve(c.component)
+		if c.unknown {
+			if !errors.Is(err, ErrUnknownComponent) {
+				t.Errorf("%s: expected ErrUnknownComponent, got %v", c.component, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(endpoints, c.expected) {
+			t.Errorf("%s: expected %v, got %v %v", c.component, c.expected, endpoints, err)
+		}
+	}
+
+	if _, err := s.Resolve("broken"); err == nil || errors.Is(err, ErrUnknownComponent) {
+		t.Errorf("expected an endpoint without url to be invalid, got %v", err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	var cases = []struct {
+		name     string
+		settings map[string]interface{}
+		expected interface{}
+		ok       bool
+	}{
+		{"default", nil, &Static{}, true},
+		{"static", map[string]interface{}{"resolver": "static"}, &Static{}, true},
+		{"srv", map[string]interface{}{"resolver": "srv", "srv": map[string]interface{}{"domain": "svc.local"}},
+			&SRV{}, true},
+		{"srv without domain", map[string]interface{}{"resolver": "srv"}, nil, false},
+		{"file without path", map[string]interface{}{"resolver": "file"}, nil, false},
+		{"missing file", map[string]interface{}{"resolver": "file", "file": map[string]interface{}{
+			"path": "testdata/missing.yaml"}}, nil, false},
+		{"unknown resolver", map[string]interface{}{"resolver": "consul"}, nil, false},
+		{"invalid cache_ttl", map[string]interface{}{"cache_ttl": "often"}, nil, false},
+	}
+
+	for _, c := range cases {
+		var v = domains()
+		if c.settings != nil {
+			v.Set("discovery", c.settings)
+		}
+
+		var r, err = Configure(v)
+		if !c.ok {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if reflect.TypeOf(r) != reflect.TypeOf(c.expected) {
+			t.Errorf("%s: expected a %T, got a %T", c.name, c.expected, r)
+		}
+	}
+}
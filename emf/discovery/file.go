@@ -0,0 +1,201 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v2"
+)
+
+// reloadDelay debounces the bursts of events of a single write of the registry file
+const reloadDelay = 100 * time.Millisecond
+
+// File resolves components from a JSON or YAML registry file mapping each component to a URL, or to a list
+// of URLs or of endpoints with a url and a weight:
+//
+//	ledger: "http://ledger:8080"
+//	users:
+//	  - url: "http://users-1:8080"
+//	    weight: 2
+//	  - "http://users-2:8080"
+//
+// The file is read again whenever it changes while started, keeping the current endpoints if it is invalid.
+type File struct {
+	notifier
+	*settings
+	path string
+
+	mu        sync.RWMutex
+	endpoints map[string][]Endpoint
+	watcher   *fsnotify.Watcher
+}
+
+// NewFile is a variadic constructor for a File resolver, reading the registry file at path
+func NewFile(path string, opts ...Option) (*File, error) {
+	var f = &File{settings: newSettings(opts), path: path}
+	if err := f.Load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resolve returns the endpoints of component listed in the registry file
+func (f *File) Resolve(component string) ([]Endpoint, error) {
+	f.mu.RLock()
+	var endpoints, ok = f.endpoints[component]
+	f.mu.RUnlock()
+
+	if !ok || len(endpoints) == 0 {
+		return f.resolveFallback(component,
+			fmt.Errorf("%w '%s': it is not listed in '%s'", ErrUnknownComponent, component, f.path))
+	}
+	return endpoints, nil
+}
+
+// Load reads the registry file again, and notifies the components whose endpoints changed
+func (f *File) Load() error {
+	var data, err = ioutil.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read the registry file '%s': %w", f.path, err)
+	}
+
+	var next map[string][]Endpoint
+	if next, err = parseRegistry(data); err != nil {
+		return fmt.Errorf("invalid registry file '%s': %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	var prev = f.endpoints
+	f.endpoints = next
+	f.mu.Unlock()
+
+	if prev == nil {
+		return nil
+	}
+	for component, endpoints := range next {
+		if !sameEndpoints(prev[component], endpoints) {
+			f.notify(component)
+		}
+	}
+	for component := range prev {
+		if _, ok := next[component]; !ok {
+			f.notify(component)
+		}
+	}
+	return nil
+}
+
+// Start reloads the registry file whenever it changes, until Stop is called. The parent directory is watched
+// rather than the file, so atomic renames and Kubernetes ConfigMap updates are picked up too.
+func (f *File) Start(context.Context) (err error) {
+	var w *fsnotify.Watcher
+	if w, err = fsnotify.NewWatcher(); err != nil {
+		return fmt.Errorf("failed to watch the registry file: %w", err)
+	}
+	if err = w.Add(filepath.Dir(f.path)); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to watch the directory of the registry file '%s': %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.watcher = w
+	f.mu.Unlock()
+
+	var reload = func() {
+		if loadErr := f.Load(); loadErr != nil {
+			f.onError(fmt.Errorf("registry reload failed, keeping the current endpoints: %w", loadErr))
+		}
+	}
+
+	go func() {
+		var debounce *time.Timer
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					if debounce != nil {
+						debounce.Stop()
+					}
+					return
+				}
+				// Any change of the directory may be a rename onto the file or a symlink swap
+				if debounce == nil {
+					debounce = time.AfterFunc(reloadDelay, reload)
+				} else {
+					debounce.Reset(reloadDelay)
+				}
+			case watchErr, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				f.onError(watchErr)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the watching of the registry file
+func (f *File) Stop(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.watcher == nil {
+		return nil
+	}
+	var err = f.watcher.Close()
+	f.watcher = nil
+	return err
+}
+
+// parseRegistry decodes a registry file, JSON being a subset of YAML
+func parseRegistry(data []byte) (map[string][]Endpoint, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	var registry = map[string][]Endpoint{}
+	for component, value := range raw {
+		var endpoints, err = parseEndpoints(component, value)
+		if err != nil {
+			return nil, err
+		}
+		registry[component] = endpoints
+	}
+	return registry, nil
+}
+
+// parseEndpoints decodes the endpoints of component from a URL, or a list of URLs or of endpoints
+func parseEndpoints(component string, value interface{}) (endpoints []Endpoint, err error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case string:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	default:
+		return nil, fmt.Errorf("component '%s' must map to a URL or a list of endpoints", component)
+	}
+
+	for _, item := range items {
+		var e Endpoint
+		if url, ok := item.(string); ok {
+			e.URL = url
+		} else if err = mapstructure.Decode(item, &e); err != nil {
+			return nil, fmt.Errorf("invalid endpoint of component '%s': %w", component, err)
+		}
+		if e.URL == "" {
+			return nil, fmt.Errorf("an endpoint of component '%s' has no url", component)
+		}
+		e.URL = trimURL(e.URL)
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRegistry writes a registry file in a new temporary directory, removed by the returned function
+func newRegistry(t *testing.T, content string) (path string, remove func()) {
+	var dir, err = ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "registry.yaml")
+	writeRegistry(t, path, content)
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func writeRegistry(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recorder collects the components notified by a Notifier
+type recorder struct {
+	mu         sync.Mutex
+	components []string
+	changed    chan struct{}
+}
+
+func newRecorder(n Notifier) *recorder {
+	var r = &recorder{changed: make(chan struct{}, 10)}
+	n.OnChange(func(component string) {
+		r.mu.Lock()
+		r.components = append(r.components, component)
+		r.mu.Unlock()
+		r.changed <- struct{}{}
+	})
+	return r
+}
+
+func (r *recorder) notified() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var components = append([]string{}, r.components...)
+	sort.Strings(components)
+	return components
+}
+
+const registry = `
+ledger: "http://ledger:8080/"
+users:
+  - url: "http://users-1:8080"
+    weight: 2
+  - "http://users-2:8080"
+`
+
+func TestFileResolve(t *testing.T) {
+	var path, remove = newRegistry(t, registry)
+	defer remove()
+
+	var f, err = NewFile(path, WithFallback(NewStatic(domains())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		component string
+		expected  []Endpoint
+	}{
+		{"ledger", []Endpoint{{URL: "http://ledger:8080"}}},
+		{"users", []Endpoint{{URL: "http://users-1:8080", Weight: 2}, {URL: "http://users-2:8080"}}},
+		// Components missing from the registry are resolved by the fallback
+		{"kyc", []Endpoint{{URL: "http://kyc-1:8080", Weight: 3}, {URL: "http://kyc-2:8080"}}},
+	}
+	for _, c := range cases {
+		var endpoints, err = f.Resolve(c.component)
+		if err != nil || !reflect.DeepEqual(endpoints, c.expected) {
+			t.Errorf("%s: expected %v, got %v %v", c.component, c.expected, endpoints, err)
+		}
+	}
+
+	if f, err = NewFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Resolve("kyc"); !errors.Is(err, ErrUnknownComponent) {
+		t.Errorf("expected ErrUnknownComponent without a fallback, got %v", err)
+	}
+}
+
+func TestFileInvalid(t *testing.T) {
+	var cases = map[string]string{
+		"not a map":      "- http://ledger:8080",
+		"not a URL":      "ledger: 8080",
+		"no url":         "ledger:\n  - weight: 2",
+		"invalid weight": "ledger:\n  - url: http://ledger:8080\n    weight: heavy",
+	}
+
+	for name, content := range cases {
+		var path, remove = newRegistry(t, content)
+		if _, err := NewFile(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		remove()
+	}
+}
+
+func TestFileLoad(t *testing.T) {
+	var path, remove = newRegistry(t, registry)
+	defer remove()
+
+	var f, err = NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r = newRecorder(f)
+
+	// ledger changes, users is removed and kyc is added
+	writeRegistry(t, path, "ledger: http://ledger-2:8080\nkyc: http://kyc:8080")
+	if err = f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.notified(); !reflect.DeepEqual(got, []string{"kyc", "ledger", "users"}) {
+		t.Errorf("expected kyc, ledger and users to be notified, got %v", got)
+	}
+
+	// Invalid files keep the current endpoints
+	writeRegistry(t, path, "ledger: [")
+	if err = f.Load(); err == nil {
+		t.Error("expected an invalid registry to be reported")
+	}
+	var endpoints, _ = f.Resolve("ledger")
+	if !reflect.DeepEqual(endpoints, []Endpoint{{URL: "http://ledger-2:8080"}}) {
+		t.Errorf("expected the endpoints to be kept, got %v", endpoints)
+	}
+}
+
+func TestFileWatch(t *testing.T) {
+	var path, remove = newRegistry(t, registry)
+	defer remove()
+
+	var reloadErrors = make(chan error, 10)
+	var f, err = NewFile(path, WithErrorHandler(func(err error) { reloadErrors <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r = newRecorder(f)
+
+	if err = f.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Stop(context.Background())
+
+	writeRegistry(t, path, "ledger: http://ledger-2:8080\nusers: http://users:8080")
+	select {
+	case <-r.changed:
+	case err = <-reloadErrors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the registry to be reloaded when it changes")
+	}
+
+	var endpoints, _ = f.Resolve("ledger")
+	if !reflect.DeepEqual(endpoints, []Endpoint{{URL: "http://ledger-2:8080"}}) {
+		t.Errorf("expected the reloaded endpoints, got %v", endpoints)
+	}
+
+	if err = f.Stop(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
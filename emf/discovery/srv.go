@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const lookupTimeout = 5 * time.Second
+
+type srvLookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// SRV resolves a component from the _<component>._<protocol>.<domain> DNS SRV records, such as the records
+// of Consul or of Kubernetes headless services. Records are cached, and kept when a lookup fails so DNS
+// outages do not break calls. Only the records of the lowest priority are used. Components without records
+// are resolved by the fallback Resolver, and looked up again once the cache expires.
+type SRV struct {
+	notifier
+	*settings
+	domain string
+
+	mu    sync.Mutex
+	cache map[string]srvEntry
+}
+
+type srvEntry struct {
+	endpoints []Endpoint
+	expires   time.Time
+}
+
+// NewSRV is a variadic constructor for an SRV resolver of the records under domain
+func NewSRV(domain string, opts ...Option) *SRV {
+	var s = &SRV{settings: newSettings(opts), domain: domain, cache: map[string]srvEntry{}}
+	if s.lookup == nil {
+		s.lookup = net.DefaultResolver.LookupSRV
+	}
+	return s
+}
+
+// Resolve returns the endpoints of the SRV records of component
+func (s *SRV) Resolve(component string) ([]Endpoint, error) {
+	s.mu.Lock()
+	var entry, cached = s.cache[component]
+	s.mu.Unlock()
+	if cached && time.Now().Before(entry.expires) {
+		if entry.endpoints == nil {
+			return s.notFound(component)
+		}
+		return entry.endpoints, nil
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	var _, records, err = s.lookup(ctx, component, s.protocol, s.domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			s.store(component, nil)
+			return s.notFound(component)
+		}
+		if cached && entry.endpoints != nil {
+			s.onError(fmt.Errorf("SRV lookup of component '%s' failed, keeping its endpoints: %w", component, err))
+			return entry.endpoints, nil
+		}
+		return nil, fmt.Errorf("SRV lookup of component '%s' failed: %w", component, err)
+	}
+	if len(records) == 0 {
+		s.store(component, nil)
+		return s.notFound(component)
+	}
+
+	// Records are sorted by priority, then randomized by weight
+	var endpoints []Endpoint
+	for _, r := range records {
+		if r.Priority != records[0].Priority {
+			break
+		}
+		endpoints = append(endpoints, Endpoint{
+			URL:    s.scheme + "://" + net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))),
+			Weight: int(r.Weight),
+		})
+	}
+
+	s.store(component, endpoints)
+	if cached && !sameEndpoints(entry.endpoints, endpoints) {
+		s.notify(component)
+	}
+	return endpoints, nil
+}
+
+// store caches the endpoints of component, nil meaning it has no SRV record
+func (s *SRV) store(component string, endpoints []Endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[component] = srvEntry{endpoints: endpoints, expires: time.Now().Add(s.ttl)}
+}
+
+// notFound resolves a component without SRV records with the fallback Resolver
+func (s *SRV) notFound(component string) ([]Endpoint, error) {
+	return s.resolveFallback(component,
+		fmt.Errorf("%w '%s': it has no SRV record under '%s'", ErrUnknownComponent, component, s.domain))
+}
@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDNS answers SRV lookups with records or an error, counting the lookups
+type fakeDNS struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+	lookups int
+}
+
+func (d *fakeDNS) set(records []*net.SRV, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.records, d.err = records, err
+}
+
+func (d *fakeDNS) lookup(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lookups++
+	return "_" + service + "._" + proto + "." + name, d.records, d.err
+}
+
+func (d *fakeDNS) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lookups
+}
+
+func newSRV(dns *fakeDNS, opts ...Option) *SRV {
+	var s = NewSRV("svc.local", opts...)
+	s.lookup = dns.lookup
+	return s
+}
+
+var notFound = &net.DNSError{Err: "no such host", Name: "_ledger._tcp.svc.local", IsNotFound: true}
+
+func TestSRVResolve(t *testing.T) {
+	var cases = []struct {
+		name     string
+		records  []*net.SRV
+		err      error
+		opts     []Option
+		expected []Endpoint
+		ok       bool
+	}{
+		{"records", []*net.SRV{
+			{Target: "ledger-1.svc.local.", Port: 8080, Priority: 1, Weight: 2},
+			{Target: "ledger-2.svc.local.", Port: 8080, Priority: 1, Weight: 1},
+			{Target: "ledger-3.svc.local.", Port: 8080, Priority: 2, Weight: 1},
+		}, nil, nil, []Endpoint{
+			{URL: "http://ledger-1.svc.local:8080", Weight: 2},
+			{URL: "http://ledger-2.svc.local:8080", Weight: 1},
+		}, true},
+		{"scheme", []*net.SRV{{Target: "ledger.svc.local.", Port: 8443}}, nil, []Option{WithScheme("https")},
+			[]Endpoint{{URL: "https://ledger.svc.local:8443"}}, true},
+		{"no record", nil, notFound, []Option{WithFallback(NewStatic(domains()))},
+			[]Endpoint{{URL: "http://ledger:8080"}}, true},
+		{"empty answer", []*net.SRV{}, nil, []Option{WithFallback(NewStatic(domains()))},
+			[]Endpoint{{URL: "http://ledger:8080"}}, true},
+		{"no record without fallback", nil, notFound, nil, nil, false},
+		{"lookup failure", nil, errors.New("server misbehaving"), []Option{WithFallback(NewStatic(domains()))},
+			nil, false},
+	}
+
+	for _, c := range cases {
+		var dns = &fakeDNS{records: c.records, err: c.err}
+		var endpoints, err = newSRV(dns, c.opts...).Resolve("ledger")
+		if c.ok && (err != nil || !reflect.DeepEqual(endpoints, c.expected)) {
+			t.Errorf("%s: expected %v, got %v %v", c.name, c.expected, endpoints, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: expected an error, got %v", c.name, endpoints)
+		}
+	}
+}
+
+func TestSRVCache(t *testing.T) {
+	var dns = &fakeDNS{records: []*net.SRV{{Target: "ledger.svc.local.", Port: 8080}}}
+	var s = newSRV(dns, WithCacheTTL(50*time.Millisecond), WithFallback(NewStatic(domains())))
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Resolve("ledger"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dns.count() != 1 {
+		t.Errorf("expected the records to be cached, got %d lookups", dns.count())
+	}
+
+	// Components without records are cached too, and resolved by the fallback
+	dns.set(nil, notFound)
+	for i := 0; i < 3; i++ {
+		if _, err := s.Resolve("users"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dns.count() != 2 {
+		t.Errorf("expected missing records to be cached, got %d lookups", dns.count())
+	}
+}
+
+func TestSRVKeepsEndpointsOnFailure(t *testing.T) {
+	var dns = &fakeDNS{records: []*net.SRV{{Target: "ledger.svc.local.", Port: 8080}}}
+	var lookupErrors = make(chan error, 10)
+	var s = newSRV(dns, WithCacheTTL(time.Millisecond), WithErrorHandler(func(err error) { lookupErrors <- err }))
+
+	var expected, err = s.Resolve("ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	dns.set(nil, errors.New("server misbehaving"))
+
+	var endpoints []Endpoint
+	if endpoints, err = s.Resolve("ledger"); err != nil || !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("expected the cached endpoints to be kept, got %v %v", endpoints, err)
+	}
+	select {
+	case <-lookupErrors:
+	default:
+		t.Error("expected the failed lookup to be reported")
+	}
+}
+
+func TestSRVNotifies(t *testing.T) {
+	var dns = &fakeDNS{records: []*net.SRV{{Target: "ledger-1.svc.local.", Port: 8080}}}
+	var s = newSRV(dns, WithCacheTTL(time.Millisecond))
+	var r = newRecorder(s)
+
+	var records = [][]*net.SRV{
+		{{Target: "ledger-1.svc.local.", Port: 8080}},
+		{{Target: "ledger-2.svc.local.", Port: 8080}},
+	}
+	for _, rr := range records {
+		dns.set(rr, nil)
+		time.Sleep(5 * time.Millisecond)
+		if _, err := s.Resolve("ledger"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Resolve("ledger"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first lookup and the unchanged records are not notified
+	if got := r.notified(); !reflect.DeepEqual(got, []string{"ledger"}) {
+		t.Errorf("expected a single change of ledger, got %v", got)
+	}
+}
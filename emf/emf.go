@@ -1,6 +1,8 @@
 package emf
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"os"
 	"time"
@@ -11,12 +13,26 @@ import (
 	validator "gopkg.in/go-playground/validator.v9"
 
 	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/apikey"
+	"github.com/cambridge-blockchain/emf/emf/balancer"
 	"github.com/cambridge-blockchain/emf/emf/bind"
+	"github.com/cambridge-blockchain/emf/emf/breaker"
+	emfcontext "github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
 	"github.com/cambridge-blockchain/emf/emf/endpoint"
+	"github.com/cambridge-blockchain/emf/emf/health"
+	"github.com/cambridge-blockchain/emf/emf/lifecycle"
 	"github.com/cambridge-blockchain/emf/emf/logger"
 	"github.com/cambridge-blockchain/emf/emf/middleware"
+	"github.com/cambridge-blockchain/emf/emf/oauth2"
+	"github.com/cambridge-blockchain/emf/emf/openapi"
+	"github.com/cambridge-blockchain/emf/emf/retry"
+	"github.com/cambridge-blockchain/emf/emf/revocation"
 	"github.com/cambridge-blockchain/emf/emf/router"
 	"github.com/cambridge-blockchain/emf/emf/server"
+	"github.com/cambridge-blockchain/emf/emf/servicetoken"
+	"github.com/cambridge-blockchain/emf/emf/workers"
 )
 
 // Constants for configuring the server
@@ -35,9 +51,19 @@ const (
 type Controller struct {
 	server      *server.Server
 	router      *router.Router
-	config      configurer.ConfigReader
+	adminRouter *router.Router
+	config      *configurer.Store
 	build       configurer.BuildConfig
 	middlewares *middleware.AllMiddlewares
+	catalog     *errors.Catalog
+	lifecycle   *lifecycle.Lifecycle
+	health      *health.Health
+	workers     *workers.Pool
+	revocations *revocation.List
+	oauth2      *oauth2.Profiles
+	breakers    *breaker.Breakers
+	resolver    discovery.Resolver
+	rhOptions   []emfcontext.RHOption
 }
 
 // GetBuild is a method to expose the config
@@ -50,6 +76,21 @@ func (c *Controller) GetConfig() configurer.ConfigReader {
 	return c.config
 }
 
+// GetConfigStore is a method to expose the config store, which swaps the config when config.watch reloads it
+func (c *Controller) GetConfigStore() *configurer.Store {
+	return c.config
+}
+
+// OnConfigChange registers a callback run whenever config.watch reloads a valid config
+func (c *Controller) OnConfigChange(name string, f func(next configurer.Config) error) {
+	c.config.Subscribe(name, f)
+}
+
+// GetErrorCatalog is a method to expose the error templates loaded at startup
+func (c *Controller) GetErrorCatalog() *errors.Catalog {
+	return c.catalog
+}
+
 // GetServer is a method to expose the logger
 func (c *Controller) GetServer() *server.Server {
 	return c.server
@@ -65,11 +106,100 @@ func (c *Controller) GetRouter() *router.Router {
 	return c.router
 }
 
+// GetAdminRouter is a method to expose the router for operational endpoints. It is served on admin.port
+// when one is configured, otherwise it is the same router as GetRouter.
+func (c *Controller) GetAdminRouter() *router.Router {
+	if c.adminRouter != nil {
+		return c.adminRouter
+	}
+	return c.router
+}
+
 // GetMiddlewares is a method to expose the middlewares struct
 func (c *Controller) GetMiddlewares() *middleware.AllMiddlewares {
 	return c.middlewares
 }
 
+// GetLifecycle is a method to expose the lifecycle manager
+func (c *Controller) GetLifecycle() *lifecycle.Lifecycle {
+	return c.lifecycle
+}
+
+// OnStart registers a hook that runs before the server starts. Start hooks run in registration order.
+func (c *Controller) OnStart(name string, f func(ctx context.Context) error) {
+	c.lifecycle.Append(lifecycle.Hook{Name: name, OnStart: f})
+}
+
+// OnStop registers a hook that runs after the server has drained. Stop hooks run in reverse registration order.
+func (c *Controller) OnStop(name string, f func(ctx context.Context) error) {
+	c.lifecycle.Append(lifecycle.Hook{Name: name, OnStop: f})
+}
+
+// Go runs f in a goroutine that is waited for before the service exits
+func (c *Controller) Go(f func()) {
+	c.lifecycle.Go(f)
+}
+
+// GetOAuth2Profiles returns the client credentials profiles of the external APIs in the oauth2 config block
+func (c *Controller) GetOAuth2Profiles() *oauth2.Profiles {
+	return c.oauth2
+}
+
+// GetBreakers returns the circuit breakers of the components called with Requester
+func (c *Controller) GetBreakers() *breaker.Breakers {
+	return c.breakers
+}
+
+// GetResolver returns the Resolver of the components called with the Requester
+func (c *Controller) GetResolver() discovery.Resolver {
+	return c.resolver
+}
+
+// GetRevocationList returns the token revocation list, or nil when the controller was created
+// without WithRevocationStore. Event consumers can push revocations to it directly.
+func (c *Controller) GetRevocationList() *revocation.List {
+	return c.revocations
+}
+
+// NewRequestHandler returns a RequestHandler for Requester calls made outside of an API request, such as
+// from background jobs. It has the options of the RequestHandlers of requests: it shares the HTTP client, error
+// catalog and background tracker of the controller, authenticates with service tokens when
+// api.service_token.key_file is configured, and with the access tokens of the oauth2 profiles for external APIs.
+func (c *Controller) NewRequestHandler(opts ...emfcontext.RHOption) *emfcontext.RequestHandlerType {
+	var all = append(append([]emfcontext.RHOption{}, c.rhOptions...), opts...)
+	return emfcontext.NewRequestHandler(c.config, c.GetLogger(), all...)
+}
+
+// IsReady reports whether the service is serving and not shutting down
+func (c *Controller) IsReady() bool {
+	return c.lifecycle.Ready()
+}
+
+// GetHealth is a method to expose the health checks reported by /health/ready
+func (c *Controller) GetHealth() *health.Health {
+	return c.health
+}
+
+// AddHealthCheck registers a check that must pass for the service to be reported as ready
+func (c *Controller) AddHealthCheck(chk health.Checker, opts ...health.CheckOption) {
+	c.health.Register(chk, opts...)
+}
+
+// GetWorkers is a method to expose the background worker pool, which runs while the service is running
+func (c *Controller) GetWorkers() *workers.Pool {
+	return c.workers
+}
+
+// Run starts the service and blocks until SIGINT or SIGTERM is received, then drains it gracefully
+func (c *Controller) Run() error {
+	return c.lifecycle.Run(c.server)
+}
+
+// Stop triggers a graceful shutdown of a service started with Run
+func (c *Controller) Stop() {
+	c.lifecycle.Stop()
+}
+
 type customValidator struct {
 	validator *validator.Validate
 }
@@ -78,31 +208,163 @@ func (cv *customValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
 
-// New : Creates a default EMF server object, properly configured
+// configureEcho applies the EMF server settings, error handling, validation and binding to an echo instance
+func configureEcho(e *echo.Echo, l echo.Logger, port string, debug bool) {
+	e.Logger = l
+	e.Debug = debug
+
+	e.Server.Addr = fmt.Sprintf(":%v", port)
+	e.Server.ReadTimeout = readTimeout
+	e.Server.WriteTimeout = writeTimeout
+	e.Server.IdleTimeout = idleTimeout
+	e.Server.MaxHeaderBytes = headerBytes
+
+	// Register Custom HTTP Error Handler for EMFErrors
+	e.HTTPErrorHandler = middleware.CustomHTTPErrorHandler
+
+	// Register Custom Validator based on go-playground validator
+	e.Validator = &customValidator{validator: validator.New()}
+
+	// ***********************************************
+	// * FIX BIND
+	// ***********************************************
+
+	e.Binder = &bind.DefaultBinder{}
+}
+
+// configureTLS loads the api.tls.* files and builds the TLS config of the API listener
+func configureTLS(conf configurer.ConfigReader) (certs *server.CertReloader, tlsConf *tls.Config, err error) {
+	if certs, err = server.NewCertReloader(server.TLSConfig{
+		CertFile:     os.ExpandEnv(conf.GetString("api.tls.cert_file")),
+		KeyFile:      os.ExpandEnv(conf.GetString("api.tls.key_file")),
+		ClientCAFile: os.ExpandEnv(conf.GetString("api.tls.client_ca_file")),
+		ClientAuth:   conf.GetString("api.tls.client_auth"),
+	}); err != nil {
+		return nil, nil, fmt.Errorf("api.tls could not be configured: %w", err)
+	}
+	if tlsConf, err = certs.TLSConfig(); err != nil {
+		return nil, nil, fmt.Errorf("api.tls could not be configured: %w", err)
+	}
+	return certs, tlsConf, nil
+}
+
+// watchConfig validates reloaded configs and applies them to the logger, the rate limit and the error catalog.
+// Other components read the store directly, such as GetDomain and IsDebug.
+func watchConfig(store *configurer.Store, l echo.Logger, m *middleware.AllMiddlewares, cat *errors.Catalog) {
+	var pending *errors.Catalog
+
+	store.Validate("api", func(next configurer.Config) error {
+		if port := next.GetString("api.port"); port != store.GetString("api.port") {
+			return fmt.Errorf("api.port cannot change from '%s' to '%s' without a restart", store.GetString("api.port"), port)
+		}
+		if next.GetInt("api.rate_limit") < 0 {
+			return fmt.Errorf("api.rate_limit cannot be negative")
+		}
+		return nil
+	})
+	store.Validate("logging", func(next configurer.Config) (err error) {
+		_, err = logger.ParseLevel(next.GetString("logging.level"))
+		return
+	})
+	store.Validate("errors", func(next configurer.Config) (err error) {
+		pending, err = errors.LoadCatalog(next.GetString("errors.configPath"))
+		return
+	})
+
+	store.Subscribe("logging", func(next configurer.Config) error {
+		var level, _ = logger.ParseLevel(next.GetString("logging.level"))
+		l.SetLevel(level)
+		return nil
+	})
+	store.Subscribe("rate_limit", func(next configurer.Config) error {
+		m.RateLimit.SetRate(float64(next.GetInt("api.rate_limit")))
+		return nil
+	})
+	store.Subscribe("errors", func(next configurer.Config) error {
+		cat.Replace(pending)
+		return nil
+	})
+}
+
+// New : Creates a default EMF server object, properly configured.
+// It exits when the config file cannot be read and panics on any other startup problem,
+// use NewController to handle those errors instead.
 func New(configFile string, buildConfig configurer.BuildConfig, notificationCodes []notifications.NotificationType) (
 	c *Controller) {
+	var err error
+
+	if c, err = NewController(
+		WithConfig(configurer.LoadConfig(configFile, "emf")),
+		WithBuildConfig(buildConfig),
+		WithNotificationCodes(notificationCodes),
+	); err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+// NewController : Creates a default EMF server object, properly configured.
+// Every startup problem is collected and returned together as a *StartupError.
+func NewController(opts ...Option) (c *Controller, err error) {
 	var (
-		s    *server.Server
-		r    *router.Router
-		e    *echo.Echo
-		m    *middleware.AllMiddlewares
-		conf configurer.Config
+		s     *server.Server
+		r     *router.Router
+		ar    *router.Router
+		ops   *router.Router
+		e     *echo.Echo
+		admin *echo.Echo
+		m     *middleware.AllMiddlewares
+		conf  configurer.Config
+		store *configurer.Store
+		cat   *errors.Catalog
+		lc    *lifecycle.Lifecycle
+		hc    *health.Health
+		wp    *workers.Pool
+		rl    *revocation.List
+		st    *servicetoken.Minter
+		op    *oauth2.Profiles
+		rp    *retry.Policies
+		cb    *breaker.Breakers
+		dr    discovery.Resolver
+		lb    *balancer.Balancers
+		o     options
+		se    StartupError
 	)
 
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// ***********************************************
 	// * Load up the config
 	// ***********************************************
 
-	conf = configurer.LoadConfig(configFile, "emf")
+	if conf = o.config; conf == nil {
+		if conf, err = configurer.ReadConfig(o.configFile, "emf"); err != nil {
+			se.add(fmt.Errorf("configuration file could not be read: %w", err))
+			return nil, &se
+		}
+	}
+
+	// Every component reads through the store, so a reloaded config is seen on their next read
+	store = configurer.NewStore(conf)
+
+	buildConfig := o.build
 	buildConfig.Component = conf.GetString("name")
 	buildConfig.EMFVersion = Version
 	buildConfig.EchoVersion = echo.Version
 
-	if _, err := os.Stat(os.ExpandEnv(conf.GetString("errors.configPath"))); os.IsNotExist(err) {
-		panic(fmt.Errorf(
-			"failed to start server, the configured errors.configPath file '%s' does not exist",
-			err.(*os.PathError).Path,
-		))
+	if conf.GetString("api.port") == "" {
+		se.add(fmt.Errorf("api.port is not configured"))
+	}
+
+	// Compile the error templates once, every request shares the same Catalog, whose definitions are read-only
+	// and only swapped as a whole when the errors file is reloaded
+	if _, err = os.Stat(os.ExpandEnv(conf.GetString("errors.configPath"))); os.IsNotExist(err) {
+		se.add(fmt.Errorf("the configured errors.configPath file '%s' does not exist", err.(*os.PathError).Path))
+	} else if cat, err = errors.LoadCatalog(conf.GetString("errors.configPath")); err != nil {
+		se.add(fmt.Errorf("could not load the configured errors.configPath: %w", err))
 	}
 
 	// ***********************************************
@@ -115,65 +377,272 @@ func New(configFile string, buildConfig configurer.BuildConfig, notificationCode
 	// * Set up Logger
 	// ***********************************************
 
-	e.Logger = logger.New(
-		conf,
-		buildConfig,
-	)
+	if e.Logger = o.logger; e.Logger == nil {
+		if e.Logger, err = logger.Configure(conf, buildConfig); err != nil {
+			se.add(fmt.Errorf("logger could not be configured: %w", err))
+			e.Logger = echo.New().Logger
+		}
+	}
 
 	// ***********************************************
 	// * Configure Debug Mode
 	// ***********************************************
 	if conf.GetBool("debug.mode") {
 		e.Logger.Info("Using debug mode for more verbose output...")
-		e.Debug = true
 	}
 
 	// ***********************************************
 	// * Set up Server
 	// ***********************************************
 
-	e.Server.Addr = fmt.Sprintf(":%v", conf.GetString("api.port"))
-	e.Server.ReadTimeout = readTimeout
-	e.Server.WriteTimeout = writeTimeout
-	e.Server.IdleTimeout = idleTimeout
-	e.Server.MaxHeaderBytes = headerBytes
+	configureEcho(e, e.Logger, conf.GetString("api.port"), conf.GetBool("debug.mode"))
+
+	// Serve over TLS when a certificate is configured, reloading the files when they change
+	var certs *server.CertReloader
+	if conf.GetString("api.tls.cert_file") != "" || conf.GetString("api.tls.key_file") != "" {
+		if certs, e.Server.TLSConfig, err = configureTLS(conf); err != nil {
+			se.add(err)
+		}
+	}
+
+	// Serve operational endpoints on their own listener when an admin port is configured
+	var serverOpts = []server.Option{server.WithServer(e.Server)}
+	if adminPort := conf.GetString("admin.port"); adminPort != "" {
+		if adminPort == conf.GetString("api.port") {
+			se.add(fmt.Errorf("admin.port must differ from api.port '%s'", adminPort))
+		}
+		admin = echo.New()
+		configureEcho(admin, e.Logger, adminPort, conf.GetBool("debug.mode"))
+		serverOpts = append(serverOpts, server.WithAdminServer(admin.Server))
+	}
 
 	// Use our configered echo Server
-	s = server.New(server.WithServer(e.Server))
+	s = server.New(serverOpts...)
 
-	// Register Custom HTTP Error Handler for EMFErrors
-	e.HTTPErrorHandler = middleware.CustomHTTPErrorHandler
+	// ***********************************************
+	// * Set up the Lifecycle
+	// ***********************************************
 
-	// Register Custom Validator based on go-playground validator
-	e.Validator = &customValidator{validator: validator.New()}
+	lc = lifecycle.New(
+		lifecycle.WithLogger(e.Logger),
+		lifecycle.WithPreStopDelay(time.Duration(conf.GetInt("lifecycle.pre_stop_seconds"))*time.Second),
+		lifecycle.WithShutdownTimeout(time.Duration(conf.GetInt("lifecycle.shutdown_seconds"))*time.Second),
+	)
+
+	if certs != nil {
+		lc.Append(lifecycle.Hook{
+			Name: "tls",
+			OnStart: func(context.Context) error {
+				return certs.Watch(func(err error) { e.Logger.Errorf("TLS certificate reload failed: %s", err) })
+			},
+			OnStop: func(context.Context) error { return certs.Close() },
+		})
+	}
 
 	// ***********************************************
-	// * FIX BIND
+	// * Set up the Worker Pool
 	// ***********************************************
 
-	e.Binder = &bind.DefaultBinder{}
+	wp = workers.New(
+		workers.WithLogger(e.Logger),
+		workers.WithWorkers(conf.GetInt("workers.number")),
+		workers.WithQueueSize(conf.GetInt("workers.total_queue_size")),
+		workers.WithHeartbeat(time.Duration(conf.GetInt("workers.heartbeat_seconds"))*time.Second),
+	)
+	// Jobs are drained before the goroutines they start with the RequestHandler are waited for
+	lc.Append(lifecycle.Hook{Name: "workers", OnStart: wp.Start, OnDrain: wp.Stop})
+
+	// ***********************************************
+	// * Set up Service Discovery
+	// ***********************************************
+
+	if dr = o.resolver; dr == nil {
+		if dr, err = discovery.Configure(store, discovery.WithErrorHandler(func(err error) {
+			e.Logger.Errorf("Service discovery failed: %s", err)
+		})); err != nil {
+			se.add(err)
+		}
+	}
+	if n, ok := dr.(discovery.Notifier); ok {
+		n.OnChange(func(component string) { e.Logger.Infof("Endpoints of component '%s' changed", component) })
+	}
+	// Watch the registry file of the file resolver
+	if watcher, ok := dr.(interface {
+		Start(context.Context) error
+		Stop(context.Context) error
+	}); ok {
+		lc.Append(lifecycle.Hook{Name: "discovery", OnStart: watcher.Start, OnStop: watcher.Stop})
+	}
+
+	// ***********************************************
+	// * Set up Health Checks
+	// ***********************************************
+
+	hc = health.New(
+		health.WithReadiness(lc.Ready),
+		health.WithTimeout(time.Duration(conf.GetInt("health.timeout_seconds"))*time.Second),
+		health.WithCacheTTL(time.Duration(conf.GetInt("health.cache_seconds"))*time.Second),
+	)
+	if conf.GetBool("health.domains") {
+		for _, chk := range health.DomainChecks(store, dr, o.httpClient) {
+			hc.Register(chk)
+		}
+	}
+	if conf.GetBool("health.logging") {
+		hc.Register(health.LogSinkCheck(conf, o.httpClient))
+	}
 
 	// ***********************************************
 	// * Expose Middlewares
 	// ***********************************************
 
-	m = middleware.InitMiddlewares(conf)
+	if path := conf.GetString("api.service_token.key_file"); path != "" {
+		if st, err = servicetoken.NewFromFile(buildConfig.Component, os.ExpandEnv(path),
+			servicetoken.WithKeyID(conf.GetString("api.service_token.key_id")),
+			servicetoken.WithAlgorithm(conf.GetString("api.service_token.algorithm")),
+			servicetoken.WithTTL(time.Duration(conf.GetInt("api.service_token.ttl_seconds"))*time.Second),
+		); err != nil {
+			se.add(fmt.Errorf("service tokens could not be configured: %w", err))
+		}
+	}
+
+	if op, err = oauth2.LoadProfiles(conf, oauth2.WithHTTPClient(o.httpClient)); err != nil {
+		se.add(err)
+	} else {
+		op.SetResolver(dr)
+	}
+
+	rp = retry.NewPolicies(store)
+	if err = rp.Validate(); err != nil {
+		se.add(fmt.Errorf("invalid requester.retry policies: %w", err))
+	}
+
+	cb = breaker.NewBreakers(store)
+	if err = cb.Validate(); err != nil {
+		se.add(fmt.Errorf("invalid requester.breaker settings: %w", err))
+	}
+
+	lb = balancer.NewBalancers(store)
+	if err = lb.Validate(); err != nil {
+		se.add(fmt.Errorf("invalid requester.balancer settings: %w", err))
+	}
+
+	// The RequestHandlers of requests and of Controller.NewRequestHandler share these options
+	var rhOpts = []emfcontext.RHOption{
+		emfcontext.WithRHErrorCatalog(cat),
+		emfcontext.WithBackgroundTracker(lc),
+		emfcontext.WithRetries(rp),
+		emfcontext.WithBreakers(cb),
+		emfcontext.WithResolver(dr),
+		emfcontext.WithBalancers(lb),
+	}
+	if o.httpClient != nil {
+		rhOpts = append(rhOpts, emfcontext.WithHTTPClient(o.httpClient))
+	}
+	if st != nil {
+		rhOpts = append(rhOpts, emfcontext.WithServiceTokens(st))
+	}
+	if op != nil && op.Len() > 0 {
+		rhOpts = append(rhOpts, emfcontext.WithCredentials(op))
+	}
+
+	if m, err = middleware.ConfigureMiddlewares(store); err != nil {
+		se.add(fmt.Errorf("middlewares could not be configured: %w", err))
+	} else {
+		for _, override := range o.middlewares {
+			override(m)
+		}
+		middleware.WithRequestHandlerOptions(rhOpts...)(m.Context)
+		middleware.WithServiceAudience(buildConfig.Component)(m.Auth)
+		if o.apiKeys != nil {
+			var keys *apikey.Authenticator
+			if keys, err = apikey.Configure(conf, o.apiKeys); err != nil {
+				se.add(err)
+			}
+			middleware.WithAPIKeys(keys)(m.Auth)
+		}
+	}
+
+	// An empty scope would deny every revocation pushed to POST /revocations
+	if o.revocations != nil && conf.GetString("api.revocation.scope") == "" {
+		se.add(fmt.Errorf("api.revocation.scope is not configured"))
+	}
+
+	if len(se.Problems) > 0 {
+		return nil, &se
+	}
+
+	if o.revocations != nil {
+		rl = revocation.New(o.revocations,
+			revocation.WithKeyPrefix(conf.GetString("api.revocation.key_prefix")),
+			revocation.WithDefaultTTL(time.Duration(conf.GetInt("api.revocation.default_ttl_seconds"))*time.Second),
+			revocation.WithSweepInterval(time.Duration(conf.GetInt("api.revocation.sweep_seconds"))*time.Second),
+		)
+		middleware.WithRevocationList(rl)(m.Auth)
+		lc.Append(lifecycle.Hook{Name: "revocation", OnStart: rl.Start, OnStop: rl.Stop})
+	}
+
+	// Refresh the keys of a JWKS URL or key files in the background
+	if refresher, ok := m.Auth.KeyProvider().(interface {
+		Start(context.Context) error
+		Stop(context.Context) error
+	}); ok {
+		lc.Append(lifecycle.Hook{Name: "keys", OnStart: refresher.Start, OnStop: refresher.Stop})
+	}
+
+	// ***********************************************
+	// * Propagate config reloads
+	// ***********************************************
+
+	watchConfig(store, e.Logger, m, cat)
+	if conf.GetBool("config.watch") {
+		lc.Append(lifecycle.Hook{
+			Name:    "config",
+			OnStart: func(context.Context) error { return store.Watch("emf", e.Logger.Infof) },
+			OnStop:  func(context.Context) error { store.Stop(); return nil },
+		})
+	}
 
 	// ***********************************************
 	// * Set up router and register Routes
 	// ***********************************************
 
 	r = router.New(router.WithRouter(e))
-
-	endpoint.RegisterInfo(r, buildConfig)
-	endpoint.RegisterNotification(r, notificationCodes)
+	ops = r
+
+	if admin != nil {
+		ar = router.New(router.WithRouter(admin))
+		ops = ar
+		m.UseAdminMiddlewares(ar)
+		endpoint.RegisterAdminInfo(ar, buildConfig, e.Server.Addr)
+	} else {
+		endpoint.RegisterInfo(r, buildConfig)
+	}
+	endpoint.RegisterNotification(r, o.notificationCodes)
+	if rl != nil {
+		endpoint.RegisterRevocation(r, rl, middleware.NewPolicyMiddleware("revocation",
+			middleware.RequireScope(conf.GetString("api.revocation.scope")),
+		))
+	}
+	endpoint.RegisterHealth(ops, hc)
+	endpoint.RegisterRoutes(ops, r.Registry())
+	endpoint.RegisterBreakers(ops, cb)
+	endpoint.RegisterOpenAPI(ops, r.Registry(), openapi.NewBuilder(
+		openapi.WithInfo(openapi.Info{Title: buildConfig.Component, Version: buildConfig.Version}),
+		openapi.WithServers(conf.GetString("domains.self")),
+		openapi.WithErrorCatalog(cat),
+	))
 
 	// ***********************************************
 	// * Configure performance monitoring
 	// ***********************************************
 
 	if conf.GetBool("monitoring.prometheus") {
-		endpoint.RegisterMonitoring(r)
+		endpoint.RegisterMetrics(ops)
+		// Profiles expose the internals of the service, so they are only served on the admin listener
+		if ar != nil {
+			endpoint.RegisterProfiling(ar)
+		}
 	}
 
 	// ***********************************************
@@ -183,10 +652,20 @@ func New(configFile string, buildConfig configurer.BuildConfig, notificationCode
 	c = &Controller{
 		server:      s,
 		router:      r,
-		config:      conf,
+		adminRouter: ar,
+		config:      store,
 		build:       buildConfig,
 		middlewares: m,
+		catalog:     cat,
+		lifecycle:   lc,
+		health:      hc,
+		workers:     wp,
+		revocations: rl,
+		oauth2:      op,
+		breakers:    cb,
+		resolver:    dr,
+		rhOptions:   rhOpts,
 	}
 
-	return
+	return c, nil
 }
@@ -0,0 +1,449 @@
+package emf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/gommon/log"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/apikey"
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+	"github.com/cambridge-blockchain/emf/emf/middleware"
+	"github.com/cambridge-blockchain/emf/notifications"
+)
+
+const errorsFile = "context/errors/testdata/errors.yaml"
+
+// signingKey returns a new ES256 key and the PEM of its public key for the api.public_key setting
+func signingKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var der []byte
+	if der, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// publicKey returns the PEM of a new public key for the api.public_key setting
+func publicKey(t *testing.T) string {
+	var _, pub = signingKey(t)
+	return pub
+}
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return fmt.Sprint(l.Addr().(*net.TCPAddr).Port)
+}
+
+// testConfig returns a valid config, with settings overriding it
+func testConfig(t *testing.T, settings map[string]interface{}) *viper.Viper {
+	var v = viper.New()
+	v.Set("name", "ledger")
+	v.Set("api.port", freePort(t))
+	v.Set("api.public_key", publicKey(t))
+	v.Set("errors.configPath", errorsFile)
+	for key, val := range settings {
+		v.Set(key, val)
+	}
+	return v
+}
+
+// start runs c until the returned function is called
+func start(t *testing.T, c *Controller) (stop func()) {
+	var done = make(chan error, 1)
+	go func() { done <- c.Run() }()
+
+	for deadline := time.Now().Add(2 * time.Second); !c.IsReady(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the controller did not start")
+		}
+	}
+	return func() {
+		c.Stop()
+		if err := <-done; err != nil {
+			t.Errorf("the controller did not stop gracefully: %v", err)
+		}
+	}
+}
+
+// get sends a GET request to path on port and returns the status and body of the response
+func get(t *testing.T, port, path string, header map[string]string) (int, string) {
+	return send(t, http.MethodGet, port, path, header, "")
+}
+
+// send sends a request with a JSON body to path on port and returns the status and body of the response
+func send(t *testing.T, method, port, path string, header map[string]string, body string) (int, string) {
+	var req, _ = http.NewRequest(method, "http://127.0.0.1:"+port+path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	var res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var data, _ = ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(data)
+}
+
+// memory is a revocation.Store
+type memory struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (m *memory) Set(path string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = map[string][]byte{}
+	}
+	m.entries[path] = data
+	return nil
+}
+
+func (m *memory) Get(path string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var data, ok = m.entries[path]
+	return data, ok, nil
+}
+
+func (m *memory) Delete(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, path)
+	return nil
+}
+
+// resolver sends every component to its URL
+type resolver string
+
+func (r resolver) Resolve(string) ([]discovery.Endpoint, error) {
+	return []discovery.Endpoint{{URL: string(r)}}, nil
+}
+
+// transport answers every request with an empty JSON object, recording their URLs
+type transport struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (tr *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.urls = append(tr.urls, req.URL.String())
+	tr.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+func TestNewControllerStartupError(t *testing.T) {
+	var cases = []struct {
+		name     string
+		opts     []Option
+		settings map[string]interface{}
+		problems []string
+	}{
+		{
+			name: "every problem",
+			settings: map[string]interface{}{
+				"api.port":          "",
+				"errors.configPath": "testdata/missing.yaml",
+				"api.tls.cert_file": "testdata/missing.pem",
+				"api.public_key":    "invalid",
+			},
+			problems: []string{
+				"api.port is not configured",
+				"errors.configPath file 'testdata/missing.yaml' does not exist",
+				"api.tls could not be configured",
+				"middlewares could not be configured",
+			},
+		},
+		{
+			name:     "admin port",
+			settings: map[string]interface{}{"api.port": "8080", "admin.port": "8080"},
+			problems: []string{"admin.port must differ from api.port"},
+		},
+		{
+			name:     "config file",
+			opts:     []Option{WithConfigFile("testdata/missing.yaml")},
+			problems: []string{"configuration file could not be read"},
+		},
+		{
+			name:     "revocation scope",
+			opts:     []Option{WithRevocationStore(&memory{})},
+			settings: map[string]interface{}{"api.revocation.scope": ""},
+			problems: []string{"api.revocation.scope is not configured"},
+		},
+	}
+
+	for _, c := range cases {
+		var opts = append([]Option{WithLogger(log.New("test"))}, c.opts...)
+		if c.settings != nil {
+			opts = append(opts, WithConfig(testConfig(t, c.settings)))
+		}
+		var ctrl, err = NewController(opts...)
+
+		var se *StartupError
+		if ctrl != nil || !errors.As(err, &se) {
+			t.Errorf("%s: expected a StartupError, got %v %v", c.name, ctrl, err)
+			continue
+		}
+		if len(se.Problems) != len(c.problems) {
+			t.Errorf("%s: expected %d problems, got %v", c.name, len(c.problems), se.Problems)
+			continue
+		}
+		for i, p := range c.problems {
+			if !strings.Contains(se.Problems[i].Error(), p) {
+				t.Errorf("%s: expected problem %d to contain %q, got %q", c.name, i, p, se.Problems[i])
+			}
+		}
+	}
+}
+
+func TestNewControllerOptions(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "emf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var errorsPath, _ = filepath.Abs(errorsFile)
+	var configFile = filepath.Join(dir, "config.yaml")
+	var content = fmt.Sprintf("name: kyc\napi:\n  port: %q\n  public_key: %q\nerrors:\n  configPath: %s\n",
+		freePort(t), publicKey(t), errorsPath)
+	if err = ioutil.WriteFile(configFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var logger = log.New("options")
+	var tr = &transport{}
+	var reconfigured bool
+
+	var cases = []struct {
+		name     string
+		opt      Option
+		settings map[string]interface{}
+		check    func(t *testing.T, c *Controller)
+	}{
+		// WithConfig takes precedence over WithConfigFile, so the test config is dropped
+		{"WithConfigFile", func(o *options) { WithConfigFile(configFile)(o); WithConfig(nil)(o) }, nil,
+			func(t *testing.T, c *Controller) {
+				if c.GetBuild().Component != "kyc" {
+					t.Errorf("expected the config file to be read, got the component %q", c.GetBuild().Component)
+				}
+			}},
+		{"WithBuildConfig", WithBuildConfig(configurer.BuildConfig{Version: "1.2.3"}), nil,
+			func(t *testing.T, c *Controller) {
+				if b := c.GetBuild(); b.Version != "1.2.3" || b.Component != "ledger" || b.EMFVersion != Version {
+					t.Errorf("expected the build config to be completed from the config, got %+v", b)
+				}
+			}},
+		{"WithLogger", WithLogger(logger), nil, func(t *testing.T, c *Controller) {
+			if c.GetLogger() != logger {
+				t.Error("expected the Logger to be used")
+			}
+		}},
+		{"WithMiddlewares", WithMiddlewares(func(m *middleware.AllMiddlewares) { reconfigured = m != nil }), nil,
+			func(t *testing.T, c *Controller) {
+				if !reconfigured {
+					t.Error("expected the middlewares to be passed to the callback")
+				}
+			}},
+		{"WithHTTPClient and WithResolver", func(o *options) {
+			WithHTTPClient(&http.Client{Transport: tr})(o)
+			WithResolver(resolver("http://kyc.internal"))(o)
+		}, nil, func(t *testing.T, c *Controller) {
+			if _, ok := c.GetResolver().(resolver); !ok {
+				t.Errorf("expected the Resolver to be used, got %T", c.GetResolver())
+			}
+			if err := c.NewRequestHandler().Requester(http.MethodGet, "kyc", "/checks", nil, &struct{}{}); err != nil {
+				t.Fatal(err)
+			}
+			if len(tr.urls) != 1 || tr.urls[0] != "http://kyc.internal/checks" {
+				t.Errorf("expected the call to be sent by the HTTP client to the resolved endpoint, got %v", tr.urls)
+			}
+		}},
+		{"WithRevocationStore", WithRevocationStore(&memory{}), map[string]interface{}{"api.revocation.scope": "revoke"},
+			func(t *testing.T, c *Controller) {
+				if c.GetRevocationList() == nil {
+					t.Error("expected a revocation list")
+				}
+			}},
+		{"WithNotificationCodes", WithNotificationCodes([]notifications.NotificationType{{Code: "ACCOUNT_OPENED"}}),
+			nil, func(t *testing.T, c *Controller) {
+				c.GetMiddlewares().UseMiddlewares(c.GetRouter())
+				defer start(t, c)()
+
+				var status, body = get(t, c.GetConfig().GetString("api.port"), "/noauth/notifications/list", nil)
+				if status != http.StatusOK || !strings.Contains(body, "ACCOUNT_OPENED") {
+					t.Errorf("expected the notification codes to be listed, got %d %s", status, body)
+				}
+			}},
+		{"WithAPIKeyStore", WithAPIKeyStore(apikey.StoreFunc(func(hash string) (*apikey.Principal, error) {
+			if hash == apikey.Hash("partner-key") {
+				return &apikey.Principal{ID: "partner"}, nil
+			}
+			return nil, nil
+		})), nil, func(t *testing.T, c *Controller) {
+			c.GetMiddlewares().UseMiddlewares(c.GetRouter())
+			c.GetRouter().NewGroup("/accounts").GET("", func(ctx context.EMFContext) error {
+				var id, _ = ctx.GetClaim(apikey.Claim)
+				return ctx.String(http.StatusOK, id)
+			})
+			defer start(t, c)()
+
+			var status, body = get(t, c.GetConfig().GetString("api.port"), "/accounts",
+				map[string]string{apikey.DefaultHeader: "partner-key"})
+			if status != http.StatusOK || body != "partner" {
+				t.Errorf("expected the API key to be authenticated by the store, got %d %s", status, body)
+			}
+		}},
+	}
+
+	for _, c := range cases {
+		var ctrl, err = NewController(WithConfig(testConfig(t, c.settings)), WithLogger(log.New("test")), c.opt)
+		// The options are applied in order, so c.opt may override the test config
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		t.Run(c.name, func(t *testing.T) { c.check(t, ctrl) })
+	}
+}
+
+func TestProfilingOnlyOnAdminListener(t *testing.T) {
+	var adminPort = freePort(t)
+
+	var cases = []struct {
+		name     string
+		settings map[string]interface{}
+		admin    int
+	}{
+		{"without an admin listener", map[string]interface{}{"monitoring.prometheus": true}, 0},
+		{"with an admin listener", map[string]interface{}{"monitoring.prometheus": true, "admin.port": adminPort},
+			http.StatusOK},
+	}
+
+	for _, c := range cases {
+		var ctrl, err = NewController(WithConfig(testConfig(t, c.settings)), WithLogger(log.New("test")))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		ctrl.GetMiddlewares().UseMiddlewares(ctrl.GetRouter())
+		var stop = start(t, ctrl)
+
+		var port = ctrl.GetConfig().GetString("api.port")
+		for _, path := range []string{"/debug/pprof", "/debug/pprof/cmdline", "/debug/pprof/heap"} {
+			if status, _ := get(t, port, path, nil); status == http.StatusOK {
+				t.Errorf("%s: expected %s not to be served on the API port", c.name, path)
+			}
+			if c.admin == 0 {
+				continue
+			}
+			if status, _ := get(t, adminPort, path, nil); status != c.admin {
+				t.Errorf("%s: expected %s to answer %d on the admin port, got %d", c.name, path, c.admin, status)
+			}
+		}
+		if status, _ := get(t, port, "/metrics", nil); c.admin == 0 && status != http.StatusOK {
+			t.Errorf("%s: expected the metrics to be served on the API port, got %d", c.name, status)
+		}
+		stop()
+	}
+}
+
+func TestRevocationEndpoint(t *testing.T) {
+	var key, pub = signingKey(t)
+	var sign = func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		var raw, err = jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	var ctrl, err = NewController(WithLogger(log.New("test")), WithRevocationStore(&memory{}),
+		WithConfig(testConfig(t, map[string]interface{}{
+			"api.public_key":       pub,
+			"api.jwt.algorithms":   []string{jwt.SigningMethodES256.Alg()},
+			"api.revocation.scope": "tokens:revoke",
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.GetMiddlewares().UseMiddlewares(ctrl.GetRouter())
+	ctrl.GetRouter().NewGroup("/accounts").GET("", func(ctx context.EMFContext) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	defer start(t, ctrl)()
+
+	var port = ctrl.GetConfig().GetString("api.port")
+	var bearer = func(raw string) map[string]string { return map[string]string{"Authorization": "Bearer " + raw} }
+	var auth = sign(jwt.MapClaims{"sub": "auth", "scope": "tokens:revoke"})
+	var user = sign(jwt.MapClaims{"sub": "alice"})
+	var byJTI, byToken = sign(jwt.MapClaims{"sub": "bob", "jti": "t-1"}), sign(jwt.MapClaims{"sub": "carol"})
+
+	var cases = []struct {
+		name    string
+		token   string
+		body    string
+		status  int
+		revoked string
+	}{
+		{"without the scope", user, `{"jti":"t-1"}`, http.StatusUnauthorized, ""},
+		{"missing token", auth, `{}`, http.StatusBadRequest, ""},
+		{"by jti", auth, `{"jti":"t-1"}`, http.StatusNoContent, byJTI},
+		{"by token", auth, fmt.Sprintf(`{"token":%q}`, byToken), http.StatusNoContent, byToken},
+	}
+
+	for _, c := range cases {
+		if status, body := send(t, http.MethodPost, port, "/revocations", bearer(c.token), c.body); status != c.status {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.status, status, body)
+		}
+		if c.revoked == "" {
+			continue
+		}
+		if status, body := get(t, port, "/accounts", bearer(c.revoked)); status != http.StatusUnauthorized ||
+			!strings.Contains(body, "emf.401.TokenInactive") {
+			t.Errorf("%s: expected the revoked token to be rejected, got %d %s", c.name, status, body)
+		}
+	}
+
+	if status, body := get(t, port, "/accounts", bearer(user)); status != http.StatusOK {
+		t.Errorf("expected a token that was not revoked to be accepted, got %d %s", status, body)
+	}
+}
@@ -0,0 +1,26 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/cambridge-blockchain/emf/emf/breaker"
+	"github.com/cambridge-blockchain/emf/emf/router"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// RegisterBreakers registers the endpoint listing the circuit breaker state of every component called so far
+// to the provided group
+func RegisterBreakers(r *models.Router, breakers *breaker.Breakers, mids ...models.Middleware) {
+	var g = r.NewGroup("/noauth/breakers", mids...)
+	g.GET("", wrapGetBreakers(breakers), router.Describe(router.Metadata{
+		Summary: "List the circuit breaker state of the components called by the service",
+		Auth:    "none",
+		Tags:    []string{"operations"},
+	}))
+}
+
+func wrapGetBreakers(breakers *breaker.Breakers) models.HandlerFunc {
+	return func(c models.Context) error {
+		return c.JSON(http.StatusOK, breakers.Statuses())
+	}
+}
@@ -0,0 +1,34 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/cambridge-blockchain/emf/emf/health"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// RegisterHealth registers the liveness and readiness endpoints to the provided group
+func RegisterHealth(r *models.Router, h *health.Health, mids ...models.Middleware) {
+	var g = r.NewGroup("/health", mids...)
+	g.GET("/live", getLive)
+	g.GET("/ready", wrapGetReady(h))
+}
+
+// getLive reports that the process is up and serving requests, without checking any dependency
+func getLive(c models.Context) error {
+	return c.JSON(http.StatusOK, health.Report{
+		Status: health.StatusUp,
+		Checks: []health.Result{},
+	})
+}
+
+func wrapGetReady(h *health.Health) models.HandlerFunc {
+	return func(c models.Context) error {
+		var report = h.Check(c.Request().Context())
+
+		if report.Status != health.StatusUp {
+			return c.JSON(http.StatusServiceUnavailable, report)
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+}
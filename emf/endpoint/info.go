@@ -30,6 +30,8 @@ type Information struct {
 	CurrentTime           time.Time          `json:"current_time"`
 	ComponentName         string             `json:"component_name"`
 	APIPort               string             `json:"api_port"`
+	AdminPort             string             `json:"admin_port,omitempty"`
+	OpenAPI               string             `json:"openapi,omitempty"`
 	Build                 Build              `json:"build"`
 	Caller                Caller             `json:"caller"`
 	AdditionalInformation []models.DataPoint `json:"additional_information"`
@@ -38,10 +40,17 @@ type Information struct {
 // RegisterInfo registers the identity endpoints to the provided group
 func RegisterInfo(r *models.Router, bc models.BuildConfig, mids ...models.Middleware) {
 	var g = r.NewGroup("/info", mids...)
-	g.GET("", wrapGetInfo(bc))
+	g.GET("", wrapGetInfo(r, bc, ""))
 }
 
-func wrapGetInfo(bc models.BuildConfig) models.HandlerFunc {
+// RegisterAdminInfo registers the identity endpoints to a router served on the admin listener,
+// reporting apiAddr as the API port and the admin listener's address as the admin port
+func RegisterAdminInfo(r *models.Router, bc models.BuildConfig, apiAddr string, mids ...models.Middleware) {
+	var g = r.NewGroup("/info", mids...)
+	g.GET("", wrapGetInfo(r, bc, apiAddr))
+}
+
+func wrapGetInfo(r *models.Router, bc models.BuildConfig, apiAddr string) models.HandlerFunc {
 	return func(c models.Context) (err error) {
 		var (
 			info Information
@@ -49,7 +58,14 @@ func wrapGetInfo(bc models.BuildConfig) models.HandlerFunc {
 
 		info.CurrentTime = time.Now().UTC()
 		info.ComponentName = bc.Component
-		info.APIPort = c.Echo().Server.Addr
+		if info.APIPort = apiAddr; apiAddr == "" {
+			info.APIPort = c.Echo().Server.Addr
+		} else {
+			info.AdminPort = c.Echo().Server.Addr
+		}
+		if _, ok := r.Registry().Lookup(http.MethodGet, OpenAPIJSONPath); ok {
+			info.OpenAPI = OpenAPIJSONPath
+		}
 		info.Caller = getCallerInfo(c)
 		info.Build = getBuildInfo(bc)
 
@@ -1,6 +1,7 @@
 package endpoint
 
 import (
+	"net/http"
 	"net/http/pprof"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -9,15 +10,27 @@ import (
 	"github.com/cambridge-blockchain/emf/models"
 )
 
-// RegisterMonitoring registers the prometheus monitoring endpoints
+// RegisterMonitoring registers the prometheus monitoring endpoints and the pprof profiling endpoints
 func RegisterMonitoring(r *models.Router, mids ...models.Middleware) {
+	RegisterProfiling(r, mids...)
+	RegisterMetrics(r, mids...)
+}
+
+// RegisterProfiling registers the pprof profiling endpoints. They expose the internals of the service, so they
+// should only be registered on a router that is not publicly reachable, such as the admin router.
+func RegisterProfiling(r *models.Router, mids ...models.Middleware) {
 	var g = r.NewGroup("/debug/pprof", mids...)
-	g.Any("", router.GoHandlerToEMFHandler(pprof.Handler("index")))
-	g.Any("/cmdline", router.GoHandlerToEMFHandler(pprof.Handler("cmdline")))
-	g.Any("/profile", router.GoHandlerToEMFHandler(pprof.Handler("profile")))
-	g.Any("/symbol", router.GoHandlerToEMFHandler(pprof.Handler("symbol")))
-	g.Any("/trace", router.GoHandlerToEMFHandler(pprof.Handler("trace")))
+	g.Any("", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Index)))
+	g.Any("/cmdline", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.Any("/profile", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Profile)))
+	g.Any("/symbol", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Symbol)))
+	g.Any("/trace", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Trace)))
+	// The index serves the named profiles, such as heap and goroutine
+	g.Any("/*", router.GoHandlerToEMFHandler(http.HandlerFunc(pprof.Index)))
+}
 
-	var g2 = r.NewGroup("/metrics", mids...)
-	g2.GET("", router.GoHandlerToEMFHandler(promhttp.Handler()))
+// RegisterMetrics registers the prometheus metrics endpoint
+func RegisterMetrics(r *models.Router, mids ...models.Middleware) {
+	var g = r.NewGroup("/metrics", mids...)
+	g.GET("", router.GoHandlerToEMFHandler(promhttp.Handler()))
 }
@@ -0,0 +1,36 @@
+package endpoint
+
+import (
+	"net/http"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/cambridge-blockchain/emf/emf/openapi"
+	"github.com/cambridge-blockchain/emf/emf/router"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// Paths of the OpenAPI document, linked from the info endpoint when they are registered
+const (
+	OpenAPIJSONPath = "/noauth/openapi.json"
+	OpenAPIYAMLPath = "/noauth/openapi.yaml"
+)
+
+// RegisterOpenAPI registers the endpoints serving the OpenAPI document of the routes in registry.
+// The document is built on every request, so it includes routes registered after this call.
+func RegisterOpenAPI(r *models.Router, registry *router.Registry, b *openapi.Builder, mids ...models.Middleware) {
+	var g = r.NewGroup("/noauth", mids...)
+	var meta = router.Describe(router.Metadata{Auth: "none", Tags: []string{"operations"}})
+
+	g.GET("/openapi.json", func(c models.Context) error {
+		return c.JSON(http.StatusOK, b.Build(registry.Routes()))
+	}, meta)
+
+	g.GET("/openapi.yaml", func(c models.Context) error {
+		var body, err = yaml.Marshal(b.Build(registry.Routes()))
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "application/yaml", body)
+	}, meta)
+}
@@ -0,0 +1,57 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/cambridge-blockchain/emf/emf/openapi"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+func TestRegisterOpenAPI(t *testing.T) {
+	var r, e = newTestRouter()
+	RegisterOpenAPI(r, r.Registry(), openapi.NewBuilder(openapi.WithInfo(openapi.Info{Title: "ledger"})))
+	// The document is built on every request, so routes registered later are described
+	r.NewGroup("/accounts").GET("", func(c models.Context) error { return nil })
+
+	var cases = []struct {
+		path        string
+		contentType string
+		decode      func([]byte, interface{}) error
+	}{
+		{OpenAPIJSONPath, "application/json", json.Unmarshal},
+		{OpenAPIYAMLPath, "application/yaml", yaml.Unmarshal},
+	}
+
+	for _, c := range cases {
+		var rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), c.contentType) {
+			t.Errorf("%s: expected a 200 %s response, got %d %s", c.path, c.contentType, rec.Code,
+				rec.Header().Get("Content-Type"))
+			continue
+		}
+
+		var doc struct {
+			OpenAPI string                 `json:"openapi" yaml:"openapi"`
+			Paths   map[string]interface{} `json:"paths" yaml:"paths"`
+		}
+		if err := c.decode(rec.Body.Bytes(), &doc); err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if doc.OpenAPI != openapi.Version {
+			t.Errorf("%s: expected OpenAPI %s, got %q", c.path, openapi.Version, doc.OpenAPI)
+		}
+		for _, p := range []string{"/accounts", OpenAPIJSONPath, OpenAPIYAMLPath} {
+			if _, ok := doc.Paths[p]; !ok {
+				t.Errorf("%s: expected %s to be described, got %v", c.path, p, doc.Paths)
+			}
+		}
+	}
+}
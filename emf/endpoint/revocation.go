@@ -0,0 +1,53 @@
+package endpoint
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cambridge-blockchain/emf/emf/revocation"
+	"github.com/cambridge-blockchain/emf/emf/router"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// RevocationRequest revokes a token by its jti claim, or the token itself.
+// ExpiresAt is the exp of the token, read from the token when it is omitted.
+type RevocationRequest struct {
+	JTI       string `json:"jti" validate:"required_without=Token"`
+	Token     string `json:"token" validate:"required_without=JTI"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// RegisterRevocation registers the endpoint used by the auth component to push token revocations.
+// It is authenticated like any other route, mids should restrict it to the auth component.
+func RegisterRevocation(r *models.Router, list *revocation.List, mids ...models.Middleware) {
+	var g = r.NewGroup("/revocations", mids...)
+	g.Handle(http.MethodPost, "", wrapPostRevocation(list), router.Describe(router.Metadata{
+		Summary: "Revoke a token until it expires",
+		Tags:    []string{"operations"},
+		Status:  http.StatusNoContent,
+	}))
+}
+
+func wrapPostRevocation(list *revocation.List) func(models.Context, *RevocationRequest) (*struct{}, error) {
+	return func(c models.Context, in *RevocationRequest) (*struct{}, error) {
+		var id, exp = in.JTI, time.Time{}
+		if in.ExpiresAt > 0 {
+			exp = time.Unix(in.ExpiresAt, 0)
+		}
+		if in.Token != "" {
+			if id == "" {
+				id = revocation.ID(in.Token)
+			}
+			if exp.IsZero() {
+				exp = revocation.Expiry(in.Token)
+			}
+		}
+
+		if err := list.Revoke(id, exp); err != nil {
+			return nil, c.NewError("emf.503.RevocationListUnavailable", map[string]interface{}{
+				"Error": err,
+			})
+		}
+		return nil, nil
+	}
+}
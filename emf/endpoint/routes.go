@@ -0,0 +1,24 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/cambridge-blockchain/emf/emf/router"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// RegisterRoutes registers the endpoint listing every route recorded in the registry to the provided group
+func RegisterRoutes(r *models.Router, registry *router.Registry, mids ...models.Middleware) {
+	var g = r.NewGroup("/noauth/routes", mids...)
+	g.GET("", wrapGetRoutes(registry), router.Describe(router.Metadata{
+		Summary: "List the routes exposed by the service",
+		Auth:    "none",
+		Tags:    []string{"operations"},
+	}))
+}
+
+func wrapGetRoutes(registry *router.Registry) models.HandlerFunc {
+	return func(c models.Context) error {
+		return c.JSON(http.StatusOK, registry.Routes())
+	}
+}
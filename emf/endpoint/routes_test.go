@@ -0,0 +1,62 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/router"
+	"github.com/cambridge-blockchain/emf/models"
+)
+
+// newTestRouter returns a Router whose handlers are called with an EMFContext, as the Context middleware does
+func newTestRouter() (*models.Router, *echo.Echo) {
+	var e = echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return next(context.NewEMFContext(c, viper.New()))
+		}
+	})
+	return router.New(router.WithRouter(e)), e
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	var r, e = newTestRouter()
+	RegisterRoutes(r, r.Registry())
+	// Routes registered later are listed too
+	r.NewGroup("/accounts").GET("", func(c models.Context) error { return nil },
+		router.Describe(router.Metadata{Summary: "List the accounts", Auth: "jwt"}))
+
+	var rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/noauth/routes", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+
+	var routes []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &routes); err != nil {
+		t.Fatal(err)
+	}
+	var expected = []map[string]interface{}{
+		{"method": "GET", "path": "/accounts", "summary": "List the accounts", "auth": "jwt"},
+		{"method": "GET", "path": "/noauth/routes", "summary": "List the routes exposed by the service", "auth": "none"},
+	}
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %d routes, got %v", len(expected), routes)
+	}
+	for i, fields := range expected {
+		for k, v := range fields {
+			if routes[i][k] != v {
+				t.Errorf("route %d: expected %s to be %v, got %v", i, k, v, routes[i][k])
+			}
+		}
+		if _, ok := routes[i]["handler"].(string); !ok {
+			t.Errorf("route %d: expected the handler to be named, got %v", i, routes[i]["handler"])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cambridge-blockchain/emf/cache"
+	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+)
+
+const cacheCheckKey = "emf/health/check"
+
+// DomainCheck creates a Checker calling the /info endpoint of a component, which must answer with a 2xx
+func DomainCheck(component, domain string, client *http.Client) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return NewChecker("domain:"+component, func(ctx context.Context) error {
+		return httpCheck(ctx, client, strings.TrimSuffix(domain, "/")+"/info")
+	})
+}
+
+// EndpointsCheck creates a Checker calling the /info endpoint of every endpoint of a component resolved by r.
+// The component is up when any of its endpoints answers with a 2xx, as the Requester fails over to it.
+func EndpointsCheck(component string, r discovery.Resolver, client *http.Client) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return NewChecker("domain:"+component, func(ctx context.Context) error {
+		var endpoints, err = r.Resolve(component)
+		if errors.Is(err, discovery.ErrUnknownComponent) {
+			// The component was removed from the config on reload, so it is no longer a dependency
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var failures []string
+		for _, e := range endpoints {
+			if err = httpCheck(ctx, client, strings.TrimSuffix(e.URL, "/")+"/info"); err == nil {
+				return nil
+			}
+			failures = append(failures, err.Error())
+		}
+		if len(failures) == 0 {
+			return fmt.Errorf("component '%s' has no endpoint", component)
+		}
+		return fmt.Errorf("no endpoint is up: %s", strings.Join(failures, "; "))
+	})
+}
+
+// DomainChecks creates an EndpointsCheck for every configured domains.* entry, except the service itself and
+// empty entries. Endpoints are resolved with r, or from the domains block of conf when r is nil, on every check,
+// so a configurer.Store passed as conf makes the checks follow reloaded domains. Components added on reload are
+// only checked after a restart, and components removed on reload pass their check.
+func DomainChecks(conf configurer.ConfigReader, r discovery.Resolver, client *http.Client) (checks []Checker) {
+	if r == nil {
+		r = discovery.NewStatic(conf)
+	}
+
+	var domains map[string]interface{}
+	_ = conf.UnmarshalKey("domains", &domains)
+
+	var components = make([]string, 0, len(domains))
+	for component := range domains {
+		if component == "self" {
+			continue
+		}
+		if _, err := r.Resolve(component); errors.Is(err, discovery.ErrUnknownComponent) {
+			continue
+		}
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		checks = append(checks, EndpointsCheck(component, r, client))
+	}
+	return
+}
+
+// CacheCheck creates a Checker that writes, reads back and deletes a key in the cache
+func CacheCheck(c cache.Client) Checker {
+	return NewChecker("cache", func(ctx context.Context) (err error) {
+		var (
+			data  []byte
+			found bool
+			value = []byte("ok")
+		)
+
+		if err = c.Set(cacheCheckKey, value); err != nil {
+			return fmt.Errorf("failed to write to the cache: %w", err)
+		}
+		if data, found, err = c.Get(cacheCheckKey); err != nil {
+			return fmt.Errorf("failed to read from the cache: %w", err)
+		}
+		if !found || !bytes.Equal(data, value) {
+			return fmt.Errorf("the cache did not return the value written to it")
+		}
+		if err = c.Delete(cacheCheckKey); err != nil {
+			return fmt.Errorf("failed to delete from the cache: %w", err)
+		}
+		return nil
+	})
+}
+
+// LogSinkCheck creates a Checker for the configured log sink: elasticsearch is called over HTTP
+// and a TCP syslog endpoint is dialed. It returns nil when no checkable sink is configured.
+func LogSinkCheck(conf configurer.ConfigReader, client *http.Client) Checker {
+	var (
+		protocol        = conf.GetString("logging.syslog_protocol")
+		isElasticSearch = conf.GetBool("logging.elasticsearch")
+		isTCPSyslog     = conf.GetBool("logging.syslog") && strings.HasPrefix(protocol, "tcp")
+		url             = conf.GetString("logging.endpoint")
+		syslogURL       = conf.GetString("logging.syslog_endpoint")
+	)
+
+	if !isElasticSearch && !isTCPSyslog {
+		return nil
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return NewChecker("logging", func(ctx context.Context) (err error) {
+		if isElasticSearch {
+			if err = httpCheck(ctx, client, url); err != nil {
+				return fmt.Errorf("elasticsearch: %w", err)
+			}
+		}
+		if isTCPSyslog {
+			var conn net.Conn
+			var d net.Dialer
+			if conn, err = d.DialContext(ctx, protocol, syslogURL); err != nil {
+				return fmt.Errorf("syslog: %w", err)
+			}
+			conn.Close()
+		}
+		return nil
+	})
+}
+
+func httpCheck(ctx context.Context, client *http.Client, url string) (err error) {
+	var (
+		req  *http.Request
+		resp *http.Response
+	)
+
+	if req, err = http.NewRequest(http.MethodGet, url, nil); err != nil {
+		return err
+	}
+	if resp, err = client.Do(req.WithContext(ctx)); err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+func TestDomainChecksEndpointLists(t *testing.T) {
+	var up = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	var down = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	var v = viper.New()
+	v.Set("domains", map[string]interface{}{
+		"self":   "http://localhost",
+		"empty":  "",
+		"ledger": []interface{}{down.URL, up.URL},
+		"vault":  []interface{}{map[string]interface{}{"url": down.URL, "weight": 2}},
+		"kyc":    up.URL,
+	})
+
+	var checks = DomainChecks(v, nil, nil)
+	var want = []struct {
+		name string
+		up   bool
+	}{{"domain:kyc", true}, {"domain:ledger", true}, {"domain:vault", false}}
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %d", len(want), len(checks))
+	}
+	for i, w := range want {
+		var err = checks[i].Check(context.Background())
+		if checks[i].Name() != w.name || (err == nil) != w.up {
+			t.Errorf("expected %s to be up: %v, got %s: %v", w.name, w.up, checks[i].Name(), err)
+		}
+	}
+}
+
+func TestDomainChecksFollowReloads(t *testing.T) {
+	var up = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	var v = viper.New()
+	v.Set("domains", map[string]interface{}{"kyc": "http://127.0.0.1:1", "ledger": "http://127.0.0.1:1"})
+	var store = configurer.NewStore(v)
+
+	var checks = DomainChecks(store, nil, nil)
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(checks))
+	}
+	for _, chk := range checks {
+		if chk.Check(context.Background()) == nil {
+			t.Errorf("expected %s to be down", chk.Name())
+		}
+	}
+
+	// kyc moves to a running endpoint and ledger is no longer a dependency
+	var next = viper.New()
+	next.Set("domains", map[string]interface{}{"kyc": up.URL})
+	if err := store.Reload(next); err != nil {
+		t.Fatal(err)
+	}
+	for _, chk := range checks {
+		if err := chk.Check(context.Background()); err != nil {
+			t.Errorf("expected %s to be up after the reload, got %v", chk.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package health runs the health checks of an EMF service and reports its liveness and readiness.
+// Check results are cached for a short time so that frequent probes do not hammer downstream components.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status values reported for a check and for the service as a whole
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Defaults for the timeout and cache duration of every check
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultCacheTTL = 10 * time.Second
+)
+
+// Checker is implemented by anything that can report on its own health.
+// Check must return promptly once ctx is done.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// NewChecker creates a Checker from a function
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, check: check}
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// Report is the outcome of every registered check
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Health.
+type Option func(*Health)
+
+// WithTimeout sets the default time a check may take before it is reported as down
+func WithTimeout(d time.Duration) Option {
+	return func(h *Health) {
+		if d > 0 {
+			h.timeout = d
+		}
+	}
+}
+
+// WithCacheTTL sets how long a check result is reused before the check is run again.
+// A negative duration disables caching.
+func WithCacheTTL(d time.Duration) Option {
+	return func(h *Health) {
+		if d != 0 {
+			h.cacheTTL = d
+		}
+	}
+}
+
+// WithReadiness sets the function reporting whether the service accepts traffic, such as Lifecycle.Ready.
+// The service is never ready while it returns false, whatever the checks report.
+func WithReadiness(ready func() bool) Option {
+	return func(h *Health) { h.ready = ready }
+}
+
+// CheckOption provides the client a callback that is used to dynamically specify attributes for one check.
+type CheckOption func(*check)
+
+// WithCheckTimeout overrides the default timeout for one check
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return func(c *check) { c.timeout = d }
+}
+
+type check struct {
+	Checker
+	timeout time.Duration
+
+	mu   sync.Mutex
+	last Result
+}
+
+// Health holds the registered checks and their cached results
+type Health struct {
+	mu     sync.RWMutex
+	checks []*check
+
+	timeout  time.Duration
+	cacheTTL time.Duration
+	ready    func() bool
+}
+
+// New is a variadic constructor for a Health
+func New(opts ...Option) *Health {
+	var h = &Health{
+		timeout:  DefaultTimeout,
+		cacheTTL: DefaultCacheTTL,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Register adds a check to the readiness report. A nil Checker is ignored.
+func (h *Health) Register(c Checker, opts ...CheckOption) {
+	if c == nil {
+		return
+	}
+
+	var chk = &check{Checker: c, timeout: h.timeout}
+	for _, opt := range opts {
+		opt(chk)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, chk)
+}
+
+// Ready reports whether the service accepts traffic, without running any check
+func (h *Health) Ready() bool {
+	return h.ready == nil || h.ready()
+}
+
+// Check runs every registered check concurrently, reusing results younger than the cache duration.
+// The report is down if any check fails or if the service is not ready.
+func (h *Health) Check(ctx context.Context) (r Report) {
+	h.mu.RLock()
+	var checks = append([]*check(nil), h.checks...)
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	r.Checks = make([]Result, len(checks))
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			r.Checks[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.SliceStable(r.Checks, func(i, j int) bool { return r.Checks[i].Name < r.Checks[j].Name })
+
+	r.Status = StatusUp
+	if !h.Ready() {
+		r.Status = StatusDown
+	}
+	for _, res := range r.Checks {
+		if res.Status != StatusUp {
+			r.Status = StatusDown
+		}
+	}
+	return
+}
+
+// run executes a single check, unless a fresh enough result is cached.
+// The lock is held while the check runs so that concurrent probes share a single call.
+func (h *Health) run(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if h.cacheTTL > 0 && !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < h.cacheTTL {
+		var res = c.last
+		res.Cached = true
+		return res
+	}
+
+	var cctx, cancel = context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var start = time.Now()
+	var done = make(chan error, 1)
+	go func() { done <- c.Check(cctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-cctx.Done():
+		err = fmt.Errorf("check timed out after %v", c.timeout)
+	}
+
+	c.last = Result{
+		Name:      c.Name(),
+		Status:    StatusUp,
+		Latency:   time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		c.last.Status = StatusDown
+		c.last.Error = err.Error()
+	}
+	return c.last
+}
@@ -0,0 +1,236 @@
+// Package introspection checks whether tokens are still active with the auth component, in the shape of
+// RFC 7662 token introspection. Results are cached per token hash until the token expires or a TTL elapses,
+// and concurrent lookups of the same token share a single request.
+package introspection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// Result is an introspection response. Claims holds every member of the response, including the
+// standard ones, so service specific claims such as rol or tgt can be read.
+type Result struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+
+	Claims map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON decodes the standard members of a response and keeps every member in Claims
+func (r *Result) UnmarshalJSON(data []byte) (err error) {
+	type result Result
+	var res result
+	if err = json.Unmarshal(data, &res); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &res.Claims); err != nil {
+		return
+	}
+	*r = Result(res)
+	return nil
+}
+
+// Scopes returns the space separated scopes of the token
+func (r *Result) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// Request is the body sent to the auth component when no payload is configured
+type Request struct {
+	Token         string `json:"token"`
+	TokenTypeHint string `json:"token_type_hint,omitempty"`
+}
+
+// Client sends introspection requests to a component with the Requester of the calling request,
+// so its headers, such as the Authorization header, are forwarded.
+type Client struct {
+	method    string
+	component string
+	path      string
+	payload   interface{}
+
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	mu      sync.Mutex
+	entries map[string]entry
+	calls   map[string]*call
+}
+
+type entry struct {
+	result  *Result
+	expires time.Time
+}
+
+// call is an introspection request in flight, shared by concurrent lookups of the same token
+type call struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Client.
+type Option func(*Client)
+
+// WithCacheTTL sets how long an active result is reused, at most until the token expires.
+// Results are not cached by default.
+func WithCacheTTL(d time.Duration) Option {
+	return func(c *Client) { c.ttl = d }
+}
+
+// WithNegativeCacheTTL sets how long an inactive result is reused. Results are not cached by default.
+func WithNegativeCacheTTL(d time.Duration) Option {
+	return func(c *Client) { c.negativeTTL = d }
+}
+
+// WithMaxEntries bounds the number of cached results, 10000 by default
+func WithMaxEntries(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxEntries = n
+		}
+	}
+}
+
+// New is a variadic constructor for a Client sending method requests to path on component.
+// payload is sent as the body of every request, or a Request holding the token when it is nil.
+func New(method, component, path string, payload interface{}, opts ...Option) *Client {
+	var c = &Client{
+		method:     method,
+		component:  component,
+		path:       path,
+		payload:    payload,
+		maxEntries: 10000,
+		entries:    map[string]entry{},
+		calls:      map[string]*call{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Introspect returns the introspection result of token, from the cache or from the component.
+// Errors of the request are returned as they are and never cached.
+func (c *Client) Introspect(rh context.LoggerlessRequestHandler, token string) (*Result, error) {
+	var key = hash(token)
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		if time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			return e.result, nil
+		}
+		delete(c.entries, key)
+	}
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.result, cl.err
+	}
+	var cl = &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	cl.result, cl.err = c.request(rh, token)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if cl.err == nil {
+		c.store(key, token, cl.result)
+	}
+	c.mu.Unlock()
+	close(cl.done)
+
+	return cl.result, cl.err
+}
+
+func (c *Client) request(rh context.LoggerlessRequestHandler, token string) (res *Result, err error) {
+	var payload = c.payload
+	if payload == nil {
+		payload = Request{Token: token, TokenTypeHint: "access_token"}
+	}
+
+	res = &Result{}
+	if err = rh.Requester(c.method, c.component, c.path, payload, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// store caches a result, until the token expires for active results. It must be called with mu held.
+func (c *Client) store(key, token string, res *Result) {
+	var ttl = c.negativeTTL
+	if res.Active {
+		ttl = c.ttl
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	var expires = time.Now().Add(ttl)
+	if exp := expiry(token, res); !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = entry{result: res, expires: expires}
+}
+
+// evict removes the expired entries, or an arbitrary tenth of them when none has expired
+func (c *Client) evict() {
+	var now = time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	for k := range c.entries {
+		if len(c.entries) < c.maxEntries-c.maxEntries/10 {
+			return
+		}
+		delete(c.entries, k)
+	}
+}
+
+// expiry returns the exp of the result, or else the exp claim of the token when it is a JWT
+func expiry(token string, res *Result) time.Time {
+	if res.ExpiresAt > 0 {
+		return time.Unix(res.ExpiresAt, 0)
+	}
+
+	var claims = jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err == nil {
+		if exp, ok := claims["exp"].(float64); ok {
+			return time.Unix(int64(exp), 0)
+		}
+	}
+	return time.Time{}
+}
+
+func hash(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
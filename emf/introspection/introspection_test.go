@@ -0,0 +1,234 @@
+package introspection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// requester answers introspection requests with the response registered for the token
+type requester struct {
+	context.LoggerlessRequestHandler
+
+	responses map[string]map[string]interface{}
+	calls     int32
+	// entered and release, when set, block each request until release is closed
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *requester) Requester(method, component, path string, input, output interface{}) error {
+	atomic.AddInt32(&r.calls, 1)
+	if r.release != nil {
+		r.entered <- struct{}{}
+		<-r.release
+	}
+
+	var token = input.(Request).Token
+	var res, ok = r.responses[token]
+	if !ok {
+		return errors.New("auth component unavailable")
+	}
+	var data, _ = json.Marshal(res)
+	return json.Unmarshal(data, output)
+}
+
+func (r *requester) count() int {
+	return int(atomic.LoadInt32(&r.calls))
+}
+
+func jwtWithExpiry(t *testing.T, exp time.Time) string {
+	var raw, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp.Unix()}).
+		SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestIntrospectCache(t *testing.T) {
+	var rh = &requester{responses: map[string]map[string]interface{}{
+		"active":   {"active": true, "scope": "read write", "rol": "admin"},
+		"inactive": {"active": false},
+	}}
+
+	var cases = []struct {
+		name  string
+		opts  []Option
+		token string
+		ok    bool
+		calls int
+	}{
+		{"not cached by default", nil, "active", true, 2},
+		{"active result cached", []Option{WithCacheTTL(time.Minute)}, "active", true, 1},
+		{"inactive result with the active TTL", []Option{WithCacheTTL(time.Minute)}, "inactive", true, 2},
+		{"inactive result cached", []Option{WithNegativeCacheTTL(time.Minute)}, "inactive", true, 1},
+		{"active result with the negative TTL", []Option{WithNegativeCacheTTL(time.Minute)}, "active", true, 2},
+		{"errors not cached", []Option{WithCacheTTL(time.Minute), WithNegativeCacheTTL(time.Minute)}, "unknown",
+			false, 2},
+	}
+
+	for _, c := range cases {
+		rh.calls = 0
+		var client = New("POST", "auth", "/introspect", nil, c.opts...)
+		for i := 0; i < 2; i++ {
+			var res, err = client.Introspect(rh, c.token)
+			if c.ok != (err == nil) {
+				t.Errorf("%s: expected success: %v, got %v", c.name, c.ok, err)
+			}
+			if err == nil && res.Active != (c.token == "active") {
+				t.Errorf("%s: expected active: %v, got %+v", c.name, c.token == "active", res)
+			}
+		}
+		if got := rh.count(); got != c.calls {
+			t.Errorf("%s: expected %d requests, got %d", c.name, c.calls, got)
+		}
+	}
+
+	var client = New("POST", "auth", "/introspect", nil)
+	var res, err = client.Introspect(rh, "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.Scopes(); len(s) != 2 || s[0] != "read" || s[1] != "write" {
+		t.Errorf("expected the scopes of the result, got %v", s)
+	}
+	if res.Claims["rol"] != "admin" {
+		t.Errorf("expected every member of the response in the claims, got %v", res.Claims)
+	}
+}
+
+func TestIntrospectTTL(t *testing.T) {
+	var soon, later = time.Now().Add(10 * time.Second), time.Now().Add(time.Hour)
+	var tokenSoon, tokenLater = jwtWithExpiry(t, soon), jwtWithExpiry(t, later)
+
+	var rh = &requester{responses: map[string]map[string]interface{}{
+		"opaque":             {"active": true},
+		"opaque-exp":         {"active": true, "exp": soon.Unix()},
+		tokenSoon:            {"active": true},
+		tokenLater:           {"active": true},
+		"opaque-exp-expired": {"active": true, "exp": time.Now().Add(-time.Minute).Unix()},
+	}}
+
+	var cases = []struct {
+		name    string
+		token   string
+		expires time.Time
+	}{
+		{"TTL without an expiry", "opaque", time.Now().Add(time.Minute)},
+		{"exp of the result before the TTL", "opaque-exp", time.Unix(soon.Unix(), 0)},
+		{"exp of the JWT before the TTL", tokenSoon, time.Unix(soon.Unix(), 0)},
+		{"TTL before the exp of the JWT", tokenLater, time.Now().Add(time.Minute)},
+	}
+
+	var client = New("POST", "auth", "/introspect", nil, WithCacheTTL(time.Minute))
+	for _, c := range cases {
+		if _, err := client.Introspect(rh, c.token); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var e, ok = client.entries[hash(c.token)]
+		if !ok {
+			t.Errorf("%s: expected the result to be cached", c.name)
+			continue
+		}
+		if d := e.expires.Sub(c.expires); d < -time.Second || d > time.Second {
+			t.Errorf("%s: expected the entry to expire at %s, got %s", c.name, c.expires, e.expires)
+		}
+	}
+
+	// A result cached until a past exp is requested again
+	rh.calls = 0
+	for i := 0; i < 2; i++ {
+		if _, err := client.Introspect(rh, "opaque-exp-expired"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rh.count(); got != 2 {
+		t.Errorf("expected a result past its exp not to be reused, got %d requests", got)
+	}
+}
+
+func TestIntrospectSharesRequests(t *testing.T) {
+	const lookups = 10
+	var rh = &requester{
+		responses: map[string]map[string]interface{}{"active": {"active": true}},
+		entered:   make(chan struct{}, lookups),
+		release:   make(chan struct{}),
+	}
+	// Results are not cached, so only the request in flight can be shared
+	var client = New("POST", "auth", "/introspect", nil)
+
+	var wg sync.WaitGroup
+	var failures = make(chan error, lookups)
+	var lookup = func() {
+		defer wg.Done()
+		if res, err := client.Introspect(rh, "active"); err != nil || !res.Active {
+			failures <- fmt.Errorf("expected the shared active result, got %+v %v", res, err)
+		}
+	}
+
+	wg.Add(lookups)
+	go lookup()
+	<-rh.entered
+	for i := 1; i < lookups; i++ {
+		go lookup()
+	}
+	// Let the other lookups find the request in flight before it completes
+	time.Sleep(100 * time.Millisecond)
+	close(rh.release)
+	wg.Wait()
+	close(failures)
+
+	for err := range failures {
+		t.Error(err)
+	}
+	if got := rh.count(); got != 1 {
+		t.Errorf("expected concurrent lookups to share a single request, got %d", got)
+	}
+}
+
+func TestIntrospectEviction(t *testing.T) {
+	var rh = &requester{responses: map[string]map[string]interface{}{}}
+	for i := 0; i < 25; i++ {
+		rh.responses[fmt.Sprint("token-", i)] = map[string]interface{}{"active": true}
+	}
+
+	// Expired entries are evicted first
+	var client = New("POST", "auth", "/introspect", nil, WithCacheTTL(time.Minute), WithMaxEntries(3))
+	for _, token := range []string{"token-0", "token-1", "token-2"} {
+		if _, err := client.Introspect(rh, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var expired = client.entries[hash("token-0")]
+	expired.expires = time.Now().Add(-time.Second)
+	client.entries[hash("token-0")] = expired
+
+	if _, err := client.Introspect(rh, "token-3"); err != nil {
+		t.Fatal(err)
+	}
+	for i, cached := range []bool{false, true, true, true} {
+		if _, ok := client.entries[hash(fmt.Sprint("token-", i))]; ok != cached {
+			t.Errorf("token-%d: expected cached: %v", i, cached)
+		}
+	}
+
+	// The cache never grows past its bound
+	client = New("POST", "auth", "/introspect", nil, WithCacheTTL(time.Minute), WithMaxEntries(10))
+	for token := range rh.responses {
+		if _, err := client.Introspect(rh, token); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(client.entries); n > 10 {
+			t.Fatalf("expected at most 10 cached results, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// FileProvider reads PEM keys from files on disk, by kid, and reads them again every refresh interval,
+// so keys rotated by replacing the files are picked up without a restart.
+type FileProvider struct {
+	*refresher
+	files map[string]string
+}
+
+// NewFileProvider returns a FileProvider reading the PEM file of every kid in files.
+// Environment variables in the paths are expanded.
+func NewFileProvider(files map[string]string, opts ...Option) *FileProvider {
+	var fp = &FileProvider{refresher: newRefresher(opts), files: files}
+	fp.load = fp.read
+	return fp
+}
+
+func (fp *FileProvider) read(context.Context) (keys Set, err error) {
+	for kid, path := range fp.files {
+		var data []byte
+		if data, err = ioutil.ReadFile(os.ExpandEnv(path)); err != nil {
+			return nil, fmt.Errorf("failed to read key '%s': %w", kid, err)
+		}
+
+		var k = Key{ID: kid}
+		if k.Public, err = ParsePEM(data); err != nil {
+			return nil, fmt.Errorf("failed to parse key '%s' from '%s': %w", kid, path, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
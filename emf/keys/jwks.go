@@ -0,0 +1,158 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+)
+
+// maxJWKSSize is the largest JWKS document read from a URL
+const maxJWKSSize = 1 << 20
+
+// JWK is a JSON Web Key of RFC 7517, restricted to the RSA, EC and OKP public key parameters
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKSProvider fetches the keys published on a JWKS URL, such as the one of the auth service,
+// and fetches them again every refresh interval or when a token is signed with an unknown kid.
+type JWKSProvider struct {
+	*refresher
+	url string
+}
+
+// NewJWKSProvider returns a JWKSProvider fetching url
+func NewJWKSProvider(url string, opts ...Option) *JWKSProvider {
+	var jp = &JWKSProvider{refresher: newRefresher(opts), url: url}
+	jp.load = jp.fetch
+	return jp
+}
+
+func (jp *JWKSProvider) fetch(ctx context.Context) (keys Set, err error) {
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodGet, jp.url, nil); err != nil {
+		return nil, fmt.Errorf("invalid JWKS URL '%s': %w", jp.url, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var res *http.Response
+	if res, err = jp.client.Do(req.WithContext(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS from '%s': %w", jp.url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the JWKS from '%s': status %d", jp.url, res.StatusCode)
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, maxJWKSSize)); err != nil {
+		return nil, fmt.Errorf("failed to read the JWKS from '%s': %w", jp.url, err)
+	}
+
+	var set JWKS
+	if err = json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode the JWKS from '%s': %w", jp.url, err)
+	}
+	if keys, err = set.Set(); err != nil {
+		return nil, fmt.Errorf("invalid JWKS from '%s': %w", jp.url, err)
+	}
+	return keys, nil
+}
+
+// Set converts the signature keys of the JWKS, skipping encryption keys and unsupported key types.
+// It returns an error if no key could be used.
+func (s JWKS) Set() (keys Set, err error) {
+	var skipped []error
+	for _, jwk := range s.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var k = Key{ID: jwk.KeyID, Algorithm: jwk.Algorithm}
+		if k.Public, err = jwk.PublicKey(); err != nil {
+			skipped = append(skipped, fmt.Errorf("key '%s': %w", jwk.KeyID, err))
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("no usable keys: %v", skipped)
+		}
+		return nil, fmt.Errorf("no signature keys")
+	}
+	return keys, nil
+}
+
+// PublicKey decodes the RSA, ECDSA or Ed25519 public key of a JWK
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		var n, e = decodeInt(jwk.N), decodeInt(jwk.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA modulus or exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Curve)
+		}
+		var x, y = decodeInt(jwk.X), decodeInt(jwk.Y)
+		if x == nil || y == nil || !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Curve)
+		}
+		var x, err = base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", jwk.KeyType)
+	}
+}
+
+// decodeInt decodes a base64url encoded big-endian integer, or returns nil
+func decodeInt(s string) *big.Int {
+	var b, err = base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
@@ -0,0 +1,199 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// jwksServer serves a JWKS that tests can replace, or fail with a 500
+type jwksServer struct {
+	mu      sync.Mutex
+	jwks    JWKS
+	failing bool
+	hits    int
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits++
+	if s.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(s.jwks) //nolint:errcheck
+}
+
+func (s *jwksServer) set(failing bool, keys ...JWK) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+	if keys != nil {
+		s.jwks = JWKS{Keys: keys}
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(t *testing.T, kid string) (*rsa.PrivateKey, JWK) {
+	var priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, JWK{
+		KeyType: "RSA", KeyID: kid, Use: "sig", Algorithm: "RS256",
+		N: b64(priv.N.Bytes()), E: b64(big.NewInt(int64(priv.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string) (*ecdsa.PrivateKey, JWK) {
+	var priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, JWK{
+		KeyType: "EC", KeyID: kid, Curve: "P-256",
+		X: b64(priv.X.Bytes()), Y: b64(priv.Y.Bytes()),
+	}
+}
+
+func edJWK(t *testing.T, kid string) (ed25519.PrivateKey, JWK) {
+	var pub, priv, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, JWK{KeyType: "OKP", KeyID: kid, Curve: "Ed25519", X: b64(pub)}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey) string {
+	var token = jwt.NewWithClaims(method, jwt.MapClaims{"sub": "test"})
+	token.Header["kid"] = kid
+	var raw, err = token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func verify(p Provider, raw string) error {
+	var _, err = jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		var kid, _ = t.Header["kid"].(string)
+		return p.Key(context.Background(), kid, t.Method.Alg())
+	})
+	return err
+}
+
+func TestJWKSProviderAlgorithms(t *testing.T) {
+	var rsaKey, rsaPub = rsaJWK(t, "rsa-1")
+	var ecKey, ecPub = ecJWK(t, "ec-1")
+	var edKey, edPub = edJWK(t, "ed-1")
+
+	var srv = &jwksServer{}
+	srv.set(false, rsaPub, ecPub, edPub, JWK{KeyType: "RSA", KeyID: "enc-1", Use: "enc"})
+	var ts = httptest.NewServer(srv)
+	defer ts.Close()
+
+	var p = NewJWKSProvider(ts.URL)
+
+	for _, c := range []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    crypto.PrivateKey
+	}{
+		{"RS256", jwt.SigningMethodRS256, "rsa-1", rsaKey},
+		{"ES256", jwt.SigningMethodES256, "ec-1", ecKey},
+		{"EdDSA", SigningMethodEdDSA, "ed-1", edKey},
+	} {
+		if err := verify(p, sign(t, c.method, c.kid, c.key)); err != nil {
+			t.Errorf("%s token was not verified: %s", c.name, err)
+		}
+	}
+
+	// A key must not verify tokens of another algorithm, nor be used under another kid
+	if err := verify(p, sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey)); err == nil {
+		t.Error("RS256 token was verified with an EC key")
+	}
+	if err := verify(p, sign(t, jwt.SigningMethodES256, "ec-1", ecKey)); err != nil {
+		t.Errorf("ES256 token was not verified after a rejected token: %s", err)
+	}
+	if len(p.Keys()) != 3 {
+		t.Errorf("expected the encryption key to be skipped, got %d keys", len(p.Keys()))
+	}
+}
+
+func TestJWKSProviderRotation(t *testing.T) {
+	var oldKey, oldPub = rsaJWK(t, "old")
+	var newKey, newPub = rsaJWK(t, "new")
+
+	var srv = &jwksServer{}
+	srv.set(false, oldPub)
+	var ts = httptest.NewServer(srv)
+	defer ts.Close()
+
+	var p = NewJWKSProvider(ts.URL, WithMinRefreshInterval(time.Nanosecond))
+	if err := verify(p, sign(t, jwt.SigningMethodRS256, "old", oldKey)); err != nil {
+		t.Fatalf("token was not verified: %s", err)
+	}
+
+	// A token signed with an unknown kid triggers a refresh
+	srv.set(false, oldPub, newPub)
+	if err := verify(p, sign(t, jwt.SigningMethodRS256, "new", newKey)); err != nil {
+		t.Errorf("token signed with a rotated key was not verified: %s", err)
+	}
+
+	// The last known good keys are kept while the JWKS cannot be fetched
+	var reported error
+	p.onError = func(err error) { reported = err }
+	srv.set(true)
+	if err := verify(p, sign(t, jwt.SigningMethodRS256, "new", newKey)); err != nil {
+		t.Errorf("token was not verified with the last known good keys: %s", err)
+	}
+	if err := verify(p, sign(t, jwt.SigningMethodRS256, "unknown", newKey)); err == nil {
+		t.Error("token signed with an unknown kid was verified")
+	}
+	if reported == nil {
+		t.Error("the failed refresh was not reported")
+	}
+}
+
+func TestJWKSProviderMinRefreshInterval(t *testing.T) {
+	var key, pub = rsaJWK(t, "known")
+
+	var srv = &jwksServer{}
+	srv.set(false, pub)
+	var ts = httptest.NewServer(srv)
+	defer ts.Close()
+
+	var p = NewJWKSProvider(ts.URL, WithMinRefreshInterval(time.Hour))
+	for i := 0; i < 5; i++ {
+		var err = verify(p, sign(t, jwt.SigningMethodRS256, "forged", key))
+		if !errors.Is(err.(*jwt.ValidationError).Inner, ErrUnknownKey) {
+			t.Fatalf("expected ErrUnknownKey, got %v", err)
+		}
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.hits != 1 {
+		t.Errorf("expected unknown kids not to refetch the JWKS within the minimum interval, got %d fetches", srv.hits)
+	}
+}
@@ -0,0 +1,124 @@
+// Package keys provides the public keys used to verify the signature of JWT tokens.
+// Keys can be given as PEM, read from files on disk or fetched from a JWKS URL, and are selected
+// by the kid header and signing algorithm of a token. RS256, ES256 and EdDSA are supported,
+// as well as the other RSA and ECDSA algorithms of jwt-go.
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Provider supplies the public keys used to verify JWT signatures
+type Provider interface {
+	// Key returns the key identified by kid for the signing algorithm alg. kid is empty for tokens without
+	// a kid header, in which case any key usable with alg may be returned.
+	Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error)
+}
+
+// Key is a public key and the id it is published with. Algorithm optionally restricts the key to one
+// signing algorithm, otherwise it is usable with every algorithm matching its type.
+type Key struct {
+	ID        string
+	Algorithm string
+	Public    crypto.PublicKey
+}
+
+// usableWith reports whether the key can verify signatures made with alg
+func (k Key) usableWith(alg string) bool {
+	if k.Algorithm != "" && k.Algorithm != alg {
+		return false
+	}
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch alg {
+		case jwt.SigningMethodES256.Alg():
+			return pub.Curve == elliptic.P256()
+		case jwt.SigningMethodES384.Alg():
+			return pub.Curve == elliptic.P384()
+		case jwt.SigningMethodES512.Alg():
+			return pub.Curve == elliptic.P521()
+		}
+	case ed25519.PublicKey:
+		return alg == SigningMethodEdDSA.Alg()
+	}
+	return false
+}
+
+// Set is a list of keys that implements Provider
+type Set []Key
+
+// Key returns the key of the set with id kid, or the first key usable with alg when kid is empty
+func (s Set) Key(_ context.Context, kid, alg string) (crypto.PublicKey, error) {
+	for _, k := range s {
+		if (kid == "" || k.ID == kid) && k.usableWith(alg) {
+			return k.Public, nil
+		}
+	}
+
+	switch {
+	case kid == "":
+		return nil, fmt.Errorf("%w for algorithm %s", ErrUnknownKey, alg)
+	case s.has(kid):
+		return nil, fmt.Errorf("key '%s' cannot verify %s signatures", kid, alg)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownKey, kid)
+	}
+}
+
+func (s Set) has(kid string) bool {
+	for _, k := range s {
+		if k.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// NewStaticProvider returns a Provider of fixed keys
+func NewStaticProvider(keys ...Key) Provider {
+	return Set(keys)
+}
+
+// ParsePEM parses an RSA, ECDSA or Ed25519 public key from a PKIX or PKCS #1 public key, or from a certificate
+func ParsePEM(data []byte) (pub crypto.PublicKey, err error) {
+	var block, _ = pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, err
+		}
+		pub = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
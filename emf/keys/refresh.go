@@ -0,0 +1,198 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshInterval    = 5 * time.Minute
+	defaultMinRefreshInterval = 30 * time.Second
+	defaultFetchTimeout       = 10 * time.Second
+)
+
+// ErrUnknownKey is returned when no key matches the kid or algorithm of a token
+var ErrUnknownKey = errors.New("no matching key")
+
+// Option provides the client a callback that is used to dynamically specify attributes for the
+// providers which load their keys from files or a JWKS URL.
+type Option func(*refresher)
+
+// WithRefreshInterval sets how often the keys are loaded again, 5 minutes by default
+func WithRefreshInterval(d time.Duration) Option {
+	return func(r *refresher) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// WithMinRefreshInterval sets the minimum time between two loads triggered by a token signed with an
+// unknown kid, 30 seconds by default. It bounds the load put on the key source by forged tokens.
+func WithMinRefreshInterval(d time.Duration) Option {
+	return func(r *refresher) {
+		if d > 0 {
+			r.minInterval = d
+		}
+	}
+}
+
+// WithHTTPClient sets the client used to fetch a JWKS URL
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *refresher) {
+		if client != nil {
+			r.client = client
+		}
+	}
+}
+
+// WithErrorHandler is called with the errors of failed loads, while the last known good keys keep being used
+func WithErrorHandler(onError func(error)) Option {
+	return func(r *refresher) { r.onError = onError }
+}
+
+// refresher caches the keys returned by load, loading them again periodically and whenever a token uses an
+// unknown kid. Failed loads keep the last known good keys.
+type refresher struct {
+	load        func(ctx context.Context) (Set, error)
+	interval    time.Duration
+	minInterval time.Duration
+	client      *http.Client
+	onError     func(error)
+
+	mu      sync.RWMutex
+	keys    Set
+	loaded  time.Time
+	tried   time.Time
+	loading sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newRefresher(opts []Option) *refresher {
+	var r = &refresher{
+		interval:    defaultRefreshInterval,
+		minInterval: defaultMinRefreshInterval,
+		client:      &http.Client{Timeout: defaultFetchTimeout},
+		onError:     func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Key returns a key of the cached set, loading the set first when it is stale or does not have kid
+func (r *refresher) Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	var keys, loaded, tried = r.snapshot()
+	if time.Since(loaded) > r.interval && time.Since(tried) > r.minInterval {
+		keys = r.refresh(ctx, tried)
+	}
+
+	var pub, err = keys.Key(ctx, kid, alg)
+	if errors.Is(err, ErrUnknownKey) && kid != "" && time.Since(tried) > r.minInterval {
+		// The key may have been rotated since the last load
+		pub, err = r.refresh(ctx, tried).Key(ctx, kid, alg)
+	}
+	return pub, err
+}
+
+// Load loads the keys now, and returns the error of a failed load
+func (r *refresher) Load() error {
+	r.loading.Lock()
+	defer r.loading.Unlock()
+
+	var keys, err = r.load(context.Background())
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys, r.loaded, r.tried = keys, time.Now(), time.Now()
+	return nil
+}
+
+// Keys returns the cached set
+func (r *refresher) Keys() Set {
+	var keys, _, _ = r.snapshot()
+	return keys
+}
+
+func (r *refresher) snapshot() (Set, time.Time, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys, r.loaded, r.tried
+}
+
+// refresh loads the keys, unless another load was attempted since tried, and returns the current set
+func (r *refresher) refresh(ctx context.Context, tried time.Time) Set {
+	r.loading.Lock()
+	defer r.loading.Unlock()
+
+	if keys, _, last := r.snapshot(); last.After(tried) {
+		return keys
+	}
+
+	var keys, err = r.load(ctx)
+
+	r.mu.Lock()
+	r.tried = time.Now()
+	if err == nil {
+		r.keys, r.loaded = keys, r.tried
+	}
+	keys = r.keys
+	r.mu.Unlock()
+
+	if err != nil {
+		r.onError(err)
+	}
+	return keys
+}
+
+// Start loads the keys, then loads them again every refresh interval until Stop is called.
+// A failed first load is reported to the error handler and retried on the next token.
+func (r *refresher) Start(ctx context.Context) error {
+	var _, _, tried = r.snapshot()
+	r.refresh(ctx, tried)
+
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(r.done)
+		var ticker = time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				var _, _, tried = r.snapshot()
+				r.refresh(context.Background(), tried)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the periodic refresh
+func (r *refresher) Stop(ctx context.Context) error {
+	if r.stop == nil {
+		return nil
+	}
+	close(r.stop)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	r.stop = nil
+	return nil
+}
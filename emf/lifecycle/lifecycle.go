@@ -0,0 +1,295 @@
+// Package lifecycle runs an EMF service from startup to a graceful shutdown: ordered start and stop hooks,
+// signal handling, a pre-stop delay while the service reports itself as not ready, and a drain of both
+// in-flight requests and tracked background goroutines.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultShutdownTimeout is the default duration to wait for requests, goroutines and stop hooks to finish.
+const DefaultShutdownTimeout = 5 * time.Minute
+
+// Hook is a named set of callbacks, OnStart run in registration order on startup, OnDrain and OnStop in reverse
+// order on shutdown. OnDrain runs once the server is drained and before the tracked goroutines are waited for,
+// so work it finishes may still start tracked goroutines, such as the jobs of a worker pool. Any callback may
+// be nil.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnDrain func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Server is the method set the Lifecycle needs to run and drain a server.
+// Serve blocks until the server stops, and returns nil after a graceful Stop.
+type Server interface {
+	Serve() error
+	Stop(ctx context.Context) error
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Lifecycle.
+type Option func(*Lifecycle)
+
+// WithPreStopDelay sets how long the service reports itself as not ready before it starts draining,
+// giving load balancers time to stop routing new traffic to it.
+func WithPreStopDelay(d time.Duration) Option {
+	return func(l *Lifecycle) { l.preStopDelay = d }
+}
+
+// WithShutdownTimeout sets the maximum duration of the drain and the stop hooks.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(l *Lifecycle) {
+		if d > 0 {
+			l.shutdownTimeout = d
+		}
+	}
+}
+
+// WithSignals overrides the signals that trigger a graceful shutdown.
+func WithSignals(sigs ...os.Signal) Option {
+	return func(l *Lifecycle) { l.signals = sigs }
+}
+
+// WithLogger is used to specify the Logger for the Lifecycle.
+func WithLogger(logger echo.Logger) Option {
+	return func(l *Lifecycle) { l.logger = logger }
+}
+
+// Lifecycle tracks the hooks and background work of a service and coordinates its shutdown.
+type Lifecycle struct {
+	mu       sync.Mutex
+	hooks    []Hook
+	tasks    sync.WaitGroup
+	stopping bool
+	ready    int32
+
+	preStopDelay    time.Duration
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	logger          echo.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New is a variadic constructor for a Lifecycle.
+// By default SIGINT and SIGTERM trigger a shutdown, with no pre-stop delay.
+func New(opts ...Option) *Lifecycle {
+	var l = &Lifecycle{
+		shutdownTimeout: DefaultShutdownTimeout,
+		signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+		stop:            make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Append registers a Hook. Hooks must be registered before Run is called.
+func (l *Lifecycle) Append(h Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, h)
+}
+
+// Go runs f in a new goroutine that the Lifecycle waits for before running the stop hooks. Once the Lifecycle
+// drains its goroutines, new tasks are refused and logged, so none is left running on exit.
+func (l *Lifecycle) Go(f func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stopping {
+		l.warnf("Refused a background goroutine started while draining")
+		return
+	}
+
+	l.tasks.Add(1)
+	go func() {
+		defer l.tasks.Done()
+		f()
+	}()
+}
+
+// Ready reports whether the service is started and not shutting down.
+func (l *Lifecycle) Ready() bool {
+	return atomic.LoadInt32(&l.ready) == 1
+}
+
+// Stop triggers a graceful shutdown of a running Lifecycle, as if a signal had been received.
+func (l *Lifecycle) Stop() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+// Run starts the hooks and the server, then blocks until a signal, a call to Stop, or a server failure.
+// It then marks the service as not ready, waits for the pre-stop delay, drains the server, runs the drain hooks
+// in reverse order, waits for the tracked goroutines, and finally runs the stop hooks in reverse order.
+func (l *Lifecycle) Run(srv Server) (err error) {
+	var (
+		started  []Hook
+		sigs     = make(chan os.Signal, 1)
+		serveErr = make(chan error, 1)
+	)
+
+	signal.Notify(sigs, l.signals...)
+	defer signal.Stop(sigs)
+
+	if started, err = l.start(); err != nil {
+		// Failures are logged, the start error is returned instead
+		l.runDrainHooks(started) //nolint:errcheck
+		l.runStopHooks(started)  //nolint:errcheck
+		return err
+	}
+
+	go func() { serveErr <- srv.Serve() }()
+	atomic.StoreInt32(&l.ready, 1)
+
+	var serving = true
+	select {
+	case sig := <-sigs:
+		l.logf("Shutting down due to signal %v...", sig)
+	case <-l.stop:
+		l.logf("Shutting down...")
+	case err = <-serveErr:
+		serving = false
+		if err != nil {
+			err = fmt.Errorf("server failed: %w", err)
+		}
+	}
+
+	atomic.StoreInt32(&l.ready, 0)
+
+	if serving && l.preStopDelay > 0 {
+		l.logf("Marked as not ready, waiting %v before draining...", l.preStopDelay)
+		select {
+		case <-time.After(l.preStopDelay):
+		case <-sigs:
+			l.logf("Second signal received, skipping the pre-stop delay")
+		}
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+
+	if serving {
+		if stopErr := srv.Stop(ctx); stopErr != nil {
+			err = fmt.Errorf("failed to drain server: %w", stopErr)
+		}
+		if srvErr := <-serveErr; srvErr != nil && err == nil {
+			err = fmt.Errorf("server failed: %w", srvErr)
+		}
+	}
+
+	if drainErr := l.runDrainHooks(started); drainErr != nil && err == nil {
+		err = drainErr
+	}
+
+	if waitErr := l.waitTasks(ctx); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	if stopErr := l.runStopHooks(started); stopErr != nil && err == nil {
+		err = stopErr
+	}
+
+	return err
+}
+
+func (l *Lifecycle) start() (started []Hook, err error) {
+	l.mu.Lock()
+	var hooks = append([]Hook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	for _, h := range hooks {
+		if h.OnStart != nil {
+			if err = h.OnStart(context.Background()); err != nil {
+				return started, fmt.Errorf("start hook '%s' failed: %w", h.Name, err)
+			}
+		}
+		started = append(started, h)
+	}
+	return started, nil
+}
+
+// waitTasks waits for the goroutines started with Go. Go refuses new goroutines afterwards, as WaitGroup.Add
+// must not be called concurrently with Wait.
+func (l *Lifecycle) waitTasks(ctx context.Context) error {
+	l.mu.Lock()
+	l.stopping = true
+	l.mu.Unlock()
+
+	var done = make(chan struct{})
+	go func() {
+		l.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background goroutines did not finish before the shutdown timeout: %w", ctx.Err())
+	}
+}
+
+// runDrainHooks runs the drain hooks of every started Hook in reverse order, each with its own timeout
+func (l *Lifecycle) runDrainHooks(started []Hook) error {
+	return l.runHooks(started, "drain", func(h Hook) func(context.Context) error { return h.OnDrain })
+}
+
+// runStopHooks runs the stop hooks of every started Hook in reverse order, each with its own timeout.
+// All hooks are run even if one fails, and the first failure is returned.
+func (l *Lifecycle) runStopHooks(started []Hook) error {
+	return l.runHooks(started, "stop", func(h Hook) func(context.Context) error { return h.OnStop })
+}
+
+// runHooks runs the callback of every started Hook selected by callback in reverse order, each with its own
+// timeout. All callbacks are run even if one fails, and the first failure is returned.
+func (l *Lifecycle) runHooks(
+	started []Hook, phase string, callback func(Hook) func(context.Context) error,
+) (err error) {
+	for i := len(started) - 1; i >= 0; i-- {
+		var h = started[i]
+		var f = callback(h)
+		if f == nil {
+			continue
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), l.shutdownTimeout)
+		hookErr := f(ctx)
+		cancel()
+
+		if hookErr != nil {
+			l.logf("%s hook '%s' failed: %s", strings.Title(phase), h.Name, hookErr)
+			if err == nil {
+				err = fmt.Errorf("%s hook '%s' failed: %w", phase, h.Name, hookErr)
+			}
+		}
+	}
+	return
+}
+
+func (l *Lifecycle) logf(format string, args ...interface{}) {
+	if l.logger != nil {
+		l.logger.Infof(format, args...)
+	}
+}
+
+func (l *Lifecycle) warnf(format string, args ...interface{}) {
+	if l.logger != nil {
+		l.logger.Warnf(format, args...)
+	}
+}
@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the events of a Lifecycle run, in order
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) hook(name string) func(context.Context) error {
+	return func(context.Context) error { r.add(name); return nil }
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.events, ",")
+}
+
+// server is a Server that serves until it is stopped, or fails with err
+type server struct {
+	r       *recorder
+	stopped chan struct{}
+	err     error
+}
+
+func newServer(r *recorder) *server {
+	return &server{r: r, stopped: make(chan struct{})}
+}
+
+func (s *server) Serve() error {
+	if s.err != nil {
+		return s.err
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *server) Stop(context.Context) error {
+	s.r.add("server")
+	close(s.stopped)
+	return nil
+}
+
+func TestRunShutdownOrder(t *testing.T) {
+	var r = &recorder{}
+	var l = New(WithSignals())
+
+	l.Append(Hook{Name: "a", OnStart: r.hook("start a"), OnDrain: r.hook("drain a"), OnStop: r.hook("stop a")})
+	l.Append(Hook{Name: "workers", OnStart: r.hook("start workers"), OnDrain: func(context.Context) error {
+		// Work finished while draining may start tracked goroutines, which are waited for
+		l.Go(func() {
+			time.Sleep(20 * time.Millisecond)
+			r.add("task")
+		})
+		r.add("drain workers")
+		return nil
+	}, OnStop: r.hook("stop workers")})
+
+	time.AfterFunc(20*time.Millisecond, l.Stop)
+	if err := l.Run(newServer(r)); err != nil {
+		t.Fatal(err)
+	}
+
+	var want = "start a,start workers,server,drain workers,drain a,task,stop workers,stop a"
+	if r.String() != want {
+		t.Errorf("expected %s, got %s", want, r)
+	}
+	if l.Ready() {
+		t.Error("expected the lifecycle to be not ready after the shutdown")
+	}
+}
+
+func TestGoAfterDrainIsRefused(t *testing.T) {
+	var l = New(WithSignals())
+	time.AfterFunc(10*time.Millisecond, l.Stop)
+	if err := l.Run(newServer(&recorder{})); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran = make(chan struct{})
+	l.Go(func() { close(ran) })
+	select {
+	case <-ran:
+		t.Error("expected Go to refuse new goroutines once the lifecycle drained them")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	var failure = errors.New("failure")
+
+	var cases = []struct {
+		name   string
+		hooks  []Hook
+		server func(*recorder) *server
+		tasks  []func()
+		err    string
+	}{
+		{
+			name: "start hook failure",
+			hooks: []Hook{
+				{Name: "a"},
+				{Name: "b", OnStart: func(context.Context) error { return failure }},
+			},
+			err: "start hook 'b' failed: failure",
+		},
+		{
+			name: "server failure",
+			server: func(r *recorder) *server {
+				var s = newServer(r)
+				s.err = failure
+				return s
+			},
+			err: "server failed: failure",
+		},
+		{
+			name:  "stop hook failure",
+			hooks: []Hook{{Name: "a", OnStop: func(context.Context) error { return failure }}},
+			err:   "stop hook 'a' failed: failure",
+		},
+		{
+			name:  "drain hook failure",
+			hooks: []Hook{{Name: "a", OnDrain: func(context.Context) error { return failure }}},
+			err:   "drain hook 'a' failed: failure",
+		},
+		{
+			name:  "goroutines outliving the timeout",
+			tasks: []func(){func() { time.Sleep(200 * time.Millisecond) }},
+			err:   "background goroutines did not finish",
+		},
+	}
+
+	for _, c := range cases {
+		var r = &recorder{}
+		var l = New(WithSignals(), WithShutdownTimeout(50*time.Millisecond))
+		for _, h := range c.hooks {
+			l.Append(h)
+		}
+		for _, task := range c.tasks {
+			l.Go(task)
+		}
+		var srv = newServer(r)
+		if c.server != nil {
+			srv = c.server(r)
+		}
+
+		time.AfterFunc(10*time.Millisecond, l.Stop)
+		if err := l.Run(srv); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected an error containing '%s', got %v", c.name, c.err, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/syslog"
 	"os"
+	"strings"
 	"time"
 
 	elastic_logrus "github.com/interactive-solutions/go-logrus-elasticsearch"
@@ -41,7 +42,20 @@ func indexNameFunc(bc configurer.BuildConfig) elastic_logrus.IndexNameFunc {
 
 // New : Creates a default echo logger object, properly configured
 func New(conf configurer.Config, bc configurer.BuildConfig) echo.Logger {
-	var err error
+	var (
+		l   echo.Logger
+		err error
+	)
+
+	if l, err = Configure(conf, bc); err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Configure creates the same logger as New, but returns an error instead of panicking when
+// Elasticsearch or syslog cannot be reached.
+func Configure(conf configurer.ConfigReader, bc configurer.BuildConfig) (l echo.Logger, err error) {
 
 	// ***********************************************
 	// * Set up Logger
@@ -50,12 +64,17 @@ func New(conf configurer.Config, bc configurer.BuildConfig) echo.Logger {
 	var isElasticSearch = conf.GetBool("logging.elasticsearch")
 	var isSyslog = conf.GetBool("logging.syslog")
 
+	var level elog.Lvl
+	if level, err = ParseLevel(conf.GetString("logging.level")); err != nil {
+		return nil, err
+	}
+
 	if !isElasticSearch && !isSyslog {
-		var l = elog.New(bc.Component)
-		l.EnableColor()
-		l.SetHeader(`[${time_rfc3339}] ${level} ${prefix} @ ${short_file}:${line} |`)
-		l.SetLevel(elog.DEBUG)
-		return l
+		var el = elog.New(bc.Component)
+		el.EnableColor()
+		el.SetHeader(`[${time_rfc3339}] ${level} ${prefix} @ ${short_file}:${line} |`)
+		el.SetLevel(level)
+		return el, nil
 	}
 
 	var url = conf.GetString("logging.endpoint")
@@ -71,7 +90,7 @@ func New(conf configurer.Config, bc configurer.BuildConfig) echo.Logger {
 
 		var client *elastic.Client
 		if client, err = elastic.NewClient(elastic.SetURL(url)); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("failed to connect to elasticsearch at '%s': %w", url, err)
 		}
 
 		var elkHook *elastic_logrus.ElasticSearchHook
@@ -85,7 +104,7 @@ func New(conf configurer.Config, bc configurer.BuildConfig) echo.Logger {
 			indexNameFunc(bc),
 			time.Second*base10,
 		); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("failed to create the elasticsearch logging hook: %w", err)
 		}
 
 		logrus.AddHook(elkHook)
@@ -100,11 +119,29 @@ func New(conf configurer.Config, bc configurer.BuildConfig) echo.Logger {
 		}
 
 		if syslogHook, err = lSyslog.NewSyslogHook(sysProto, syslogURL, syslog.LOG_DEBUG, bc.Component); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("failed to connect to syslog at '%s': %w", syslogURL, err)
 		}
 
 		logrus.AddHook(syslogHook)
 	}
 
-	return Logger{logrus.StandardLogger()}
+	l = Logger{logrus.StandardLogger()}
+	l.SetLevel(level)
+	return l, nil
+}
+
+// ParseLevel converts a logging.level config value to a log level. An empty value is the debug level.
+func ParseLevel(level string) (elog.Lvl, error) {
+	switch strings.ToLower(level) {
+	case "", "debug":
+		return elog.DEBUG, nil
+	case "info":
+		return elog.INFO, nil
+	case "warn", "warning":
+		return elog.WARN, nil
+	case "error":
+		return elog.ERROR, nil
+	default:
+		return elog.DEBUG, fmt.Errorf("unknown logging.level '%s'", level)
+	}
 }
@@ -1,27 +1,41 @@
 package middleware
 
 import (
-	"crypto/rsa"
+	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
-	emiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
 
 	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/apikey"
 	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/introspection"
+	"github.com/cambridge-blockchain/emf/emf/keys"
+	"github.com/cambridge-blockchain/emf/emf/revocation"
+	"github.com/cambridge-blockchain/emf/emf/servicetoken"
 )
 
+const bearerScheme = "Bearer "
+
 // AuthMiddleware provides a middleware that verifies required authentication for endpoints.
 type AuthMiddleware struct {
-	signingKey *rsa.PublicKey
-	claims     jwt.Claims
-	method     string
-	component  string
-	path       string
-	payload    interface{}
-	skipper    func(c echo.Context) bool
+	keys         keys.Provider
+	serviceKeys  keys.Provider
+	algorithms   map[string]bool
+	claims       jwt.Claims
+	method       string
+	component    string
+	path         string
+	payload      interface{}
+	introspector *introspection.Client
+	revocations  *revocation.List
+	audience     string
+	apiKeys      *apikey.Authenticator
+	skipper      func(c echo.Context) bool
 }
 
 // AuthOption provides the client a callback that is used to dynamically specify attributes for an
@@ -33,7 +47,9 @@ func WithAuthClaims(claims jwt.Claims) AuthOption {
 	return func(am *AuthMiddleware) { am.claims = claims }
 }
 
-// WithActiveTokenRequest configures the request to an external component for token validation
+// WithActiveTokenRequest configures the request to an external component for token validation.
+// Results are cached as configured by api.introspection.cache_seconds and negative_cache_seconds.
+// A nil payload sends an RFC 7662 style introspection request holding the token.
 func WithActiveTokenRequest(method string, component string, path string, payload interface{}) AuthOption {
 	return func(am *AuthMiddleware) {
 		am.method = method
@@ -43,6 +59,51 @@ func WithActiveTokenRequest(method string, component string, path string, payloa
 	}
 }
 
+// WithIntrospection sets the Client checking that tokens are still active, instead of WithActiveTokenRequest
+func WithIntrospection(c *introspection.Client) AuthOption {
+	return func(am *AuthMiddleware) { am.introspector = c }
+}
+
+// WithRevocationList sets the List of revoked tokens, checked before the active token request
+func WithRevocationList(l *revocation.List) AuthOption {
+	return func(am *AuthMiddleware) { am.revocations = l }
+}
+
+// WithServiceAudience accepts the service tokens of other components whose aud claim names this component.
+// Service tokens are rejected when it is not set.
+func WithServiceAudience(component string) AuthOption {
+	return func(am *AuthMiddleware) { am.audience = component }
+}
+
+// WithAPIKeys authenticates requests without a bearer token by their API key, instead of the api.api_keys
+// settings
+func WithAPIKeys(a *apikey.Authenticator) AuthOption {
+	return func(am *AuthMiddleware) { am.apiKeys = a }
+}
+
+// WithKeyProvider sets the Provider of the keys used to verify tokens, instead of the api.jwt and
+// api.public_key settings.
+func WithKeyProvider(p keys.Provider) AuthOption {
+	return func(am *AuthMiddleware) { am.keys = p }
+}
+
+// WithServiceKeyProvider sets the Provider of the keys used to verify service tokens, instead of the
+// api.service_token.trusted_keys setting. Service tokens are only verified with these keys, never with the keys
+// of user tokens, and their kid must name the component of their svc claim.
+func WithServiceKeyProvider(p keys.Provider) AuthOption {
+	return func(am *AuthMiddleware) { am.serviceKeys = p }
+}
+
+// WithSigningAlgorithms sets the algorithms tokens may be signed with, instead of the api.jwt.algorithms setting
+func WithSigningAlgorithms(algs ...string) AuthOption {
+	return func(am *AuthMiddleware) {
+		am.algorithms = map[string]bool{}
+		for _, alg := range algs {
+			am.algorithms[alg] = true
+		}
+	}
+}
+
 // WithAuthSkipper configures the AuthMiddleware Skipper function.
 // The Skipper function determines which endpoints skip authentication
 func WithAuthSkipper(skipper func(c context.EMFContext) bool) AuthOption {
@@ -56,21 +117,27 @@ func WithAuthSkipper(skipper func(c context.EMFContext) bool) AuthOption {
 // NewAuthMiddleware is a variadic constructor for an AuthMiddleware.
 func NewAuthMiddleware(cfg configurer.ConfigReader, opts ...AuthOption) *AuthMiddleware {
 	var (
-		am   *AuthMiddleware
-		pkey *rsa.PublicKey
-		err  error
+		am  *AuthMiddleware
+		err error
 	)
 
-	var publicKey = []byte(cfg.GetString("api.public_key"))
-
-	if pkey, err = jwt.ParseRSAPublicKeyFromPEM(publicKey); err != nil {
+	if am, err = ConfigureAuthMiddleware(cfg, opts...); err != nil {
 		log.Fatal(err)
 	}
 
+	return am
+}
+
+// ConfigureAuthMiddleware is a variadic constructor for an AuthMiddleware that returns an error
+// instead of exiting when the configured keys are invalid.
+//
+// Tokens are verified with the keys of the api.jwt.jwks_url JWKS, or else of the api.jwt.key_files PEM files
+// by kid, or else of the api.public_key PEM. They may be signed with any of api.jwt.algorithms, RS256 by default.
+// Requests without a bearer token are authenticated with the API keys listed in api.api_keys.keys, if any.
+func ConfigureAuthMiddleware(cfg configurer.ConfigReader, opts ...AuthOption) (am *AuthMiddleware, err error) {
 	am = &AuthMiddleware{
-		signingKey: pkey,
-		claims:     jwt.MapClaims{},
-		// Skip info and metrics endpoints by default
+		claims: jwt.MapClaims{},
+		// Skip info, metrics and health endpoints by default
 		skipper: func(c echo.Context) bool {
 			switch c.Path() {
 			case infoPath:
@@ -78,7 +145,7 @@ func NewAuthMiddleware(cfg configurer.ConfigReader, opts ...AuthOption) *AuthMid
 			case metricsPath:
 				return true
 			default:
-				if strings.HasPrefix(c.Path(), "/debug/pprof") {
+				if strings.HasPrefix(c.Path(), healthPath+"/") {
 					return true
 				}
 				if strings.HasPrefix(c.Path(), "/noauth") {
@@ -93,60 +160,307 @@ func NewAuthMiddleware(cfg configurer.ConfigReader, opts ...AuthOption) *AuthMid
 		opt(am)
 	}
 
-	return am
+	if am.algorithms == nil {
+		var algs []string
+		if err = cfg.UnmarshalKey("api.jwt.algorithms", &algs); err != nil {
+			return nil, fmt.Errorf("invalid api.jwt.algorithms: %w", err)
+		}
+		if len(algs) == 0 {
+			algs = []string{jwt.SigningMethodRS256.Alg()}
+		}
+		WithSigningAlgorithms(algs...)(am)
+	}
+	for alg := range am.algorithms {
+		if jwt.GetSigningMethod(alg) == nil || strings.HasPrefix(alg, "HS") || alg == "none" {
+			return nil, fmt.Errorf("unsupported JWT signing algorithm '%s'", alg)
+		}
+	}
+
+	if am.keys == nil {
+		if am.keys, err = configureKeys(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if am.serviceKeys == nil {
+		if am.serviceKeys, err = configureServiceKeys(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if am.apiKeys == nil {
+		if am.apiKeys, err = apikey.Configure(cfg, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if am.introspector == nil && am.component != "" {
+		am.introspector = introspection.New(am.method, am.component, am.path, am.payload,
+			introspection.WithCacheTTL(time.Duration(cfg.GetInt("api.introspection.cache_seconds"))*time.Second),
+			introspection.WithNegativeCacheTTL(
+				time.Duration(cfg.GetInt("api.introspection.negative_cache_seconds"))*time.Second,
+			),
+		)
+	}
+
+	return am, nil
 }
 
-// Wrapper is a pass through function for handlers that implicitly performs additional business
-// logic per request. In this case, we're wrapping an existing echo middleware with the right config.
-func (am *AuthMiddleware) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
-	conf := emiddleware.JWTConfig{
-		Claims:        am.claims,
-		SigningKey:    am.signingKey,
-		SigningMethod: "RS256",
-		Skipper:       am.skipper,
-	}
-	return emiddleware.JWTWithConfig(conf)(
-		func(c echo.Context) (err error) {
-			type authResponse struct {
-				Active bool `json:"active"`
-			}
-			var resPayload authResponse
+// configureKeys creates the key Provider of the api.jwt settings, or of api.public_key
+func configureKeys(cfg configurer.ConfigReader) (keys.Provider, error) {
+	var opts = []keys.Option{
+		keys.WithRefreshInterval(time.Duration(cfg.GetInt("api.jwt.refresh_seconds")) * time.Second),
+		keys.WithErrorHandler(func(err error) {
+			log.Errorf("JWT key refresh failed, keeping the last known good keys: %s", err)
+		}),
+	}
 
-			var ctx = c.(context.EMFContext)
+	if url := cfg.GetString("api.jwt.jwks_url"); url != "" {
+		return keys.NewJWKSProvider(url, opts...), nil
+	}
 
-			if am.skipper(c) {
-				return next(ctx)
+	var files map[string]string
+	if err := cfg.UnmarshalKey("api.jwt.key_files", &files); err != nil {
+		return nil, fmt.Errorf("invalid api.jwt.key_files: %w", err)
+	}
+	if len(files) > 0 {
+		var fp = keys.NewFileProvider(files, opts...)
+		// Fail on startup rather than on the first request when a file is missing or invalid
+		if err := fp.Load(); err != nil {
+			return nil, fmt.Errorf("invalid api.jwt.key_files: %w", err)
+		}
+		return fp, nil
+	}
+
+	var pub, err = keys.ParsePEM([]byte(cfg.GetString("api.public_key")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid api.public_key: %w", err)
+	}
+	return keys.NewStaticProvider(keys.Key{Public: pub}), nil
+}
+
+// configureServiceKeys creates the key Provider of api.service_token.trusted_keys, or nil when no component
+// is trusted
+func configureServiceKeys(cfg configurer.ConfigReader) (keys.Provider, error) {
+	var files map[string]string
+	if err := cfg.UnmarshalKey("api.service_token.trusted_keys", &files); err != nil {
+		return nil, fmt.Errorf("invalid api.service_token.trusted_keys: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var fp = keys.NewFileProvider(files,
+		keys.WithRefreshInterval(time.Duration(cfg.GetInt("api.jwt.refresh_seconds"))*time.Second),
+		keys.WithErrorHandler(func(err error) {
+			log.Errorf("Service token key refresh failed, keeping the last known good keys: %s", err)
+		}),
+	)
+	if err := fp.Load(); err != nil {
+		return nil, fmt.Errorf("invalid api.service_token.trusted_keys: %w", err)
+	}
+	return fp, nil
+}
+
+// KeyProvider returns the Provider of the keys used to verify tokens
+func (am *AuthMiddleware) KeyProvider() keys.Provider {
+	return am.keys
+}
+
+// parseToken verifies the bearer token of a request with the key selected by its kid and alg headers
+func (am *AuthMiddleware) parseToken(ctx context.EMFContext) (token *jwt.Token, err error) {
+	var auth = ctx.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(auth, bearerScheme) || len(auth) == len(bearerScheme) {
+		return nil, ctx.NewError("emf.400.TokenMissing", map[string]interface{}{
+			"Error": fmt.Errorf("missing or malformed bearer token in the %s header", echo.HeaderAuthorization),
+		})
+	}
+
+	var keyFunc = func(t *jwt.Token) (interface{}, error) {
+		var alg = t.Method.Alg()
+		if !am.algorithms[alg] {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", alg)
+		}
+		var kid, _ = t.Header["kid"].(string)
+
+		// Service tokens are verified with the key of the component they claim to come from
+		if svc := tokenService(t); svc != "" {
+			if am.serviceKeys == nil {
+				return nil, fmt.Errorf("service tokens are not accepted by this component")
 			}
+			if !servicetoken.KeyOf(kid, svc) {
+				return nil, fmt.Errorf("key '%s' does not belong to component '%s'", kid, svc)
+			}
+			return am.serviceKeys.Key(ctx.Request().Context(), kid, alg)
+		}
+		return am.keys.Key(ctx.Request().Context(), kid, alg)
+	}
+
+	// Parse into a new value of the configured claims type, as echo's JWT middleware does
+	var claims = am.claims
+	if _, ok := claims.(jwt.MapClaims); ok {
+		claims = jwt.MapClaims{}
+	} else {
+		claims = reflect.New(reflect.ValueOf(am.claims).Type().Elem()).Interface().(jwt.Claims)
+	}
+
+	if token, err = jwt.ParseWithClaims(auth[len(bearerScheme):], claims, keyFunc); err == nil && token.Valid {
+		return token, nil
+	}
 
-			var token *jwt.Token
-			var ok bool
-			if token, ok = ctx.Get("user").(*jwt.Token); !ok || token == nil {
-				return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
-					"error": err,
-				})
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+		var expiry interface{} = "-"
+		if mc, ok := claims.(jwt.MapClaims); ok {
+			if exp, ok := mc["exp"].(float64); ok {
+				expiry = time.Unix(int64(exp), 0).UTC().Format(time.RFC3339)
 			}
-			ctx.Header().Add("Authorization", "Bearer "+token.Raw)
+		}
+		return nil, ctx.NewError("emf.401.TokenExpired", map[string]interface{}{
+			"ExpirationDate": expiry,
+		})
+	}
+	return nil, ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
+		"error": err,
+	})
+}
 
-			if am.component == "" {
-				ctx.Logger().Warn("No Active Token Request configured, skipping further JWT token checks...")
-				return next(ctx)
+// tokenService returns the svc claim of a token, naming the component of service tokens
+func tokenService(t *jwt.Token) string {
+	var claims, err = mapClaims(t.Claims)
+	if err != nil {
+		return ""
+	}
+	return claimString(claims, context.ServiceClaim)
+}
+
+// authenticateKey sets the Principal of an API key as the claims of the request, so handlers and policies
+// read it as they read a JWT. The key is never forwarded by the Requester.
+func (am *AuthMiddleware) authenticateKey(ctx context.EMFContext, key string, next echo.HandlerFunc) error {
+	var p, err = am.apiKeys.Authenticate(key)
+	if err == nil && p == nil {
+		err = fmt.Errorf("unknown API key")
+	}
+	if err != nil {
+		return ctx.NewError("emf.401.APIKeyInvalid", map[string]interface{}{
+			"Error": err,
+		})
+	}
+
+	ctx.Set("user", &jwt.Token{
+		Header: map[string]interface{}{"typ": "apikey"},
+		Method: jwt.SigningMethodNone,
+		Claims: p.MapClaims(),
+		Valid:  true,
+	})
+	return next(ctx)
+}
+
+// checkRevoked returns emf.401.TokenInactive if the token is in the revocation list
+func (am *AuthMiddleware) checkRevoked(ctx context.EMFContext, token *jwt.Token) error {
+	if am.revocations == nil {
+		return nil
+	}
+
+	var revoked, err = am.revocations.IsTokenRevoked(token.Raw)
+	if err != nil {
+		return ctx.NewError("emf.503.RevocationListUnavailable", map[string]interface{}{
+			"Error": err,
+		})
+	}
+	if !revoked {
+		return nil
+	}
+
+	var data map[string]interface{}
+	if claims, err := mapClaims(token.Claims); err == nil {
+		data = map[string]interface{}{
+			"Role":   claimString(claims, "rol"),
+			"Target": claimString(claims, "tgt"),
+		}
+	}
+	return ctx.NewError("emf.401.TokenInactive", data)
+}
+
+// service returns the name of the component calling with a service token, or "" for user tokens
+func service(ctx context.EMFContext) string {
+	if sc, ok := ctx.(context.ServiceContext); ok {
+		return sc.GetService()
+	}
+	var svc, _ = ctx.GetClaim(context.ServiceClaim)
+	return svc
+}
+
+// checkAudience returns emf.401.TokenVerificationFailure unless the aud claim of a service token names
+// this component
+func (am *AuthMiddleware) checkAudience(ctx context.EMFContext, token *jwt.Token) error {
+	var claims, err = mapClaims(token.Claims)
+	if err == nil {
+		for _, aud := range claimStrings(claims, "aud") {
+			if am.audience != "" && aud == am.audience {
+				return nil
 			}
+		}
+		err = fmt.Errorf("service token of '%s' is not intended for this component", service(ctx))
+	}
+	return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
+		"error": err,
+	})
+}
+
+// Wrapper is a pass through function for handlers that implicitly performs additional business
+// logic per request. The bearer token is verified, then checked against the revocation list and with the
+// active token request if configured.
+func (am *AuthMiddleware) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var ctx = c.(context.EMFContext)
+
+		if am.skipper(c) {
+			return next(ctx)
+		}
 
-			if err = ctx.Requester(
-				am.method,
-				am.component,
-				am.path,
-				am.payload,
-				&resPayload,
-			); err != nil {
-				return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
-					"error": err,
-				})
+		// Requests without a bearer token may be authenticated with an API key instead
+		if am.apiKeys != nil && !strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), bearerScheme) {
+			if key := am.apiKeys.Key(ctx.Request()); key != "" {
+				return am.authenticateKey(ctx, key, next)
 			}
+		}
+
+		var token *jwt.Token
+		if token, err = am.parseToken(ctx); err != nil {
+			return err
+		}
+		ctx.Set("user", token)
+
+		if err = am.checkRevoked(ctx, token); err != nil {
+			return err
+		}
 
-			if !resPayload.Active {
-				return ctx.NewError("emf.401.TokenInactive", nil)
+		// Service tokens are minted by the calling component, so the auth component cannot introspect them.
+		// They are addressed to this component only, so the Requester sends its own service tokens instead.
+		if service(ctx) != "" {
+			if err = am.checkAudience(ctx, token); err != nil {
+				return err
 			}
 			return next(ctx)
-		})
+		}
+		ctx.Header().Add("Authorization", "Bearer "+token.Raw)
+
+		if am.introspector == nil {
+			ctx.Logger().Warn("No Active Token Request configured, skipping further JWT token checks...")
+			return next(ctx)
+		}
+
+		var res *introspection.Result
+		if res, err = am.introspector.Introspect(ctx, token.Raw); err != nil {
+			return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
+				"error": err,
+			})
+		}
+
+		if !res.Active {
+			return ctx.NewError("emf.401.TokenInactive", nil)
+		}
+		ctx.Set(context.IntrospectionKey, res.Claims)
+		return next(ctx)
+	}
 }
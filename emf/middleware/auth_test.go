@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/keys"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+	var token = jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	var raw, err = token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestAuthServiceTokens(t *testing.T) {
+	var userKey, ledgerKey, notificationKey = newKey(t), newKey(t), newKey(t)
+
+	var am, err = ConfigureAuthMiddleware(viper.New(),
+		WithSigningAlgorithms(jwt.SigningMethodES256.Alg()),
+		WithKeyProvider(keys.NewStaticProvider(keys.Key{ID: "users", Public: &userKey.PublicKey})),
+		WithServiceKeyProvider(keys.NewStaticProvider(
+			keys.Key{ID: "ledger", Public: &ledgerKey.PublicKey},
+			keys.Key{ID: "notification:2", Public: &notificationKey.PublicKey},
+		)),
+		WithServiceAudience("vault"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"user token", signToken(t, userKey, "users", jwt.MapClaims{"sub": "alice"}), true},
+		{"service token", signToken(t, ledgerKey, "ledger", jwt.MapClaims{"svc": "ledger", "aud": "vault"}), true},
+		{"rotated service key", signToken(t, notificationKey, "notification:2",
+			jwt.MapClaims{"svc": "notification", "aud": "vault"}), true},
+		{"other audience", signToken(t, ledgerKey, "ledger", jwt.MapClaims{"svc": "ledger", "aud": "kyc"}), false},
+		{"user key claiming a component", signToken(t, userKey, "users",
+			jwt.MapClaims{"svc": "ledger", "aud": "vault"}), false},
+		{"key of another component", signToken(t, ledgerKey, "ledger",
+			jwt.MapClaims{"svc": "notification", "aud": "vault"}), false},
+		{"kid of the claimed component", signToken(t, ledgerKey, "notification:2",
+			jwt.MapClaims{"svc": "notification", "aud": "vault"}), false},
+	}
+
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		req.Header.Set(echo.HeaderAuthorization, bearerScheme+c.token)
+		var ctx = context.NewEMFContext(echo.New().NewContext(req, httptest.NewRecorder()), viper.New())
+
+		var called bool
+		err = am.Wrapper(func(echo.Context) error { called = true; return nil })(ctx)
+		if c.ok && (err != nil || !called) {
+			t.Errorf("%s: expected the token to be accepted, got %v", c.name, err)
+		} else if !c.ok && (err == nil || called) {
+			t.Errorf("%s: expected the token to be rejected", c.name)
+		}
+	}
+}
+
+func TestAuthForwardsUserTokensOnly(t *testing.T) {
+	var userKey, ledgerKey = newKey(t), newKey(t)
+	var am, err = ConfigureAuthMiddleware(viper.New(),
+		WithSigningAlgorithms(jwt.SigningMethodES256.Alg()),
+		WithKeyProvider(keys.NewStaticProvider(keys.Key{ID: "users", Public: &userKey.PublicKey})),
+		WithServiceKeyProvider(keys.NewStaticProvider(keys.Key{ID: "ledger", Public: &ledgerKey.PublicKey})),
+		WithServiceAudience("vault"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = map[string]bool{
+		signToken(t, userKey, "users", jwt.MapClaims{"sub": "alice"}):                     true,
+		signToken(t, ledgerKey, "ledger", jwt.MapClaims{"svc": "ledger", "aud": "vault"}): false,
+	}
+	for token, forwarded := range cases {
+		var req = httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		req.Header.Set(echo.HeaderAuthorization, bearerScheme+token)
+		var ctx = context.NewEMFContext(echo.New().NewContext(req, httptest.NewRecorder()), viper.New())
+
+		if err = am.Wrapper(func(echo.Context) error { return nil })(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// Service tokens are addressed to this component, calls to others get a service token of their own
+		if got := ctx.Header().Get(echo.HeaderAuthorization) != ""; got != forwarded {
+			t.Errorf("expected the token to be forwarded: %v, got %v", forwarded, got)
+		}
+	}
+}
@@ -6,7 +6,12 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/balancer"
+	"github.com/cambridge-blockchain/emf/emf/breaker"
 	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+	"github.com/cambridge-blockchain/emf/emf/retry"
 )
 
 // ContextMiddleware provides a middleware that performs tasks common to all endpoints.
@@ -26,6 +31,70 @@ func WithContextClient(client *http.Client) ContextOption {
 	}
 }
 
+// WithRequestHandlerOptions applies RHOptions, such as context.WithRetries or context.WithServiceTokens, to the
+// RequestHandler of every request.
+func WithRequestHandlerOptions(opts ...context.RHOption) ContextOption {
+	return func(cm *ContextMiddleware) {
+		cm.opts = append(cm.opts, context.WithRequestHandlerOptions(opts...))
+	}
+}
+
+// WithErrorCatalog is used to share one preloaded Catalog of Error Templates across every request.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithRHErrorCatalog(c)).
+func WithErrorCatalog(c *errors.Catalog) ContextOption {
+	return WithRequestHandlerOptions(context.WithRHErrorCatalog(c))
+}
+
+// WithBackgroundTracker is used to track goroutines started from requests, so they are drained on shutdown.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithBackgroundTracker(tracker)).
+func WithBackgroundTracker(tracker context.BackgroundTracker) ContextOption {
+	return WithRequestHandlerOptions(context.WithBackgroundTracker(tracker))
+}
+
+// WithServiceTokens is used to authenticate Requester calls with service tokens when a request carries no user token.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithServiceTokens(tokens)).
+func WithServiceTokens(tokens context.TokenSource) ContextOption {
+	return WithRequestHandlerOptions(context.WithServiceTokens(tokens))
+}
+
+// WithCredentials is used to authorize Requester calls to external APIs with their own credentials.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithCredentials(creds)).
+func WithCredentials(creds context.CredentialsSource) ContextOption {
+	return WithRequestHandlerOptions(context.WithCredentials(creds))
+}
+
+// WithRetries is used to send failed Requester calls again, as configured per component by the Policies.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithRetries(p)).
+func WithRetries(p *retry.Policies) ContextOption {
+	return WithRequestHandlerOptions(context.WithRetries(p))
+}
+
+// WithBreakers is used to fail fast on Requester calls to components whose circuit breaker is open.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithBreakers(b)).
+func WithBreakers(b *breaker.Breakers) ContextOption {
+	return WithRequestHandlerOptions(context.WithBreakers(b))
+}
+
+// WithResolver is used to resolve the components called by the Requester with r instead of the domains block.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithResolver(r)).
+func WithResolver(r discovery.Resolver) ContextOption {
+	return WithRequestHandlerOptions(context.WithResolver(r))
+}
+
+// WithBalancers is used to spread the Requester calls to components with several endpoints across them.
+//
+// Deprecated: use WithRequestHandlerOptions(context.WithBalancers(b)).
+func WithBalancers(b *balancer.Balancers) ContextOption {
+	return WithRequestHandlerOptions(context.WithBalancers(b))
+}
+
 // NewContextMiddleware is a variadic constructor for a ContextMiddleware.
 func NewContextMiddleware(cfg configurer.ConfigReader, opts ...ContextOption) *ContextMiddleware {
 	var cm = &ContextMiddleware{
@@ -7,6 +7,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	emiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 
 	"github.com/cambridge-blockchain/emf/configurer"
 )
@@ -14,6 +15,7 @@ import (
 const (
 	infoPath    = "/info"
 	metricsPath = "/metrics"
+	healthPath  = "/health"
 	// UUIDRegex represents a UUID regular expression
 	UUIDRegex = "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"
 	seconds10 = 10 * time.Second
@@ -31,6 +33,8 @@ type AllMiddlewares struct {
 	RateLimit       *RateLimitMiddleware
 	Token           *TokenMiddleware
 	External        []echo.MiddlewareFunc
+	// Admin is the chain registered on the admin listener, between the Context and Logging middlewares
+	Admin []echo.MiddlewareFunc
 }
 
 type middlewareUser interface {
@@ -54,8 +58,32 @@ func (am *AllMiddlewares) UseMiddlewares(e middlewareUser) {
 	}
 }
 
+// UseAdminMiddlewares registers the middlewares for the admin listener. Authentication is not used
+// there, as the admin port is expected to be reachable only from inside the cluster.
+func (am *AllMiddlewares) UseAdminMiddlewares(e middlewareUser) {
+	e.Pre(emiddleware.RemoveTrailingSlash())
+	e.Use(am.Context.Wrapper) // MUST register the Context middleware first
+	e.Use(am.Admin...)
+	e.Use(am.Logging.Wrapper)
+}
+
 // InitMiddlewares configures default middlewares, and returns them all as a struct for later configuration
 func InitMiddlewares(conf configurer.ConfigReader) (am *AllMiddlewares) {
+	var err error
+	if am, err = ConfigureMiddlewares(conf); err != nil {
+		log.Fatal(err)
+	}
+	return
+}
+
+// ConfigureMiddlewares configures the same default middlewares as InitMiddlewares, but returns an error
+// instead of exiting when one of them cannot be configured.
+func ConfigureMiddlewares(conf configurer.ConfigReader) (am *AllMiddlewares, err error) {
+	var auth *AuthMiddleware
+	if auth, err = ConfigureAuthMiddleware(conf); err != nil {
+		return nil, err
+	}
+
 	var bodyLimit string
 	if bodyLimit = conf.GetString("api.max_http_body"); bodyLimit == "" {
 		bodyLimit = "10M"
@@ -65,7 +93,7 @@ func InitMiddlewares(conf configurer.ConfigReader) (am *AllMiddlewares) {
 	// * Expose Middlewares
 	// ***********************************************
 	am = &AllMiddlewares{
-		Auth: NewAuthMiddleware(conf),
+		Auth: auth,
 		BodyLimitConfig: emiddleware.BodyLimitConfig{
 			Limit: bodyLimit,
 		},
@@ -94,12 +122,16 @@ func InitMiddlewares(conf configurer.ConfigReader) (am *AllMiddlewares) {
 			ElasticSearchOption(conf.GetBool("logging.elasticsearch")),
 		),
 		ParamChecker: NewParamCheckerMiddleware(WithRegex(UUIDRegex + "|^[0-9]+$")),
-		RateLimit:    NewRateLimitMiddleware(),
+		RateLimit:    NewRateLimitMiddleware(WithRate(float64(conf.GetInt("api.rate_limit")))),
 		Token:        NewTokenMiddleware(),
 		External: []echo.MiddlewareFunc{
 			emiddleware.Recover(),
 			emiddleware.RequestID(),
 		},
+		Admin: []echo.MiddlewareFunc{
+			emiddleware.Recover(),
+			emiddleware.RequestID(),
+		},
 	}
-	return
+	return am, nil
 }
@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// Rule is a single authorization requirement on the JWT claims of a request.
+// Description states the requirement in plain words, for auditing.
+type Rule struct {
+	Description string
+	Allow       func(ctx context.EMFContext, claims jwt.MapClaims) bool
+}
+
+// RequireRole allows tokens whose rol claim, or one of whose roles claim entries, has one of the prefixes,
+// such as "pa", "sp" or "tp" in "pa-1234".
+// Prefixes are compared case insensitively, as by cache.GetRoleBasedCacheKey.
+func RequireRole(prefixes ...string) Rule {
+	return Rule{
+		Description: fmt.Sprintf("rol prefix in [%s]", strings.Join(prefixes, ", ")),
+		Allow: func(_ context.EMFContext, claims jwt.MapClaims) bool {
+			var roles = append([]string{claimString(claims, "rol")}, claimStrings(claims, "roles")...)
+			for _, r := range roles {
+				var role = RolePrefix(r)
+				for _, p := range prefixes {
+					if role != "" && strings.EqualFold(role, p) {
+						return true
+					}
+				}
+			}
+			return false
+		},
+	}
+}
+
+// RequireScope allows tokens granted every one of the scopes, listed in a space separated scope claim
+// or a scp array claim, or in the introspection result of the token
+func RequireScope(scopes ...string) Rule {
+	return Rule{
+		Description: fmt.Sprintf("scopes include [%s]", strings.Join(scopes, ", ")),
+		Allow: func(ctx context.EMFContext, claims jwt.MapClaims) bool {
+			var granted = map[string]bool{}
+			if ic, ok := ctx.(context.IntrospectionContext); ok {
+				for _, s := range ic.GetScopes() {
+					granted[s] = true
+				}
+			}
+			for _, name := range []string{"scope", "scp"} {
+				for _, s := range claimStrings(claims, name) {
+					granted[s] = true
+				}
+			}
+			for _, s := range scopes {
+				if !granted[s] {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// RequireClaim allows tokens whose claim equals value
+func RequireClaim(claim, value string) Rule {
+	return Rule{
+		Description: fmt.Sprintf("%s == '%s'", claim, value),
+		Allow: func(_ context.EMFContext, claims jwt.MapClaims) bool {
+			var _, ok = claims[claim]
+			return ok && claimString(claims, claim) == value
+		},
+	}
+}
+
+// RequireTarget allows tokens whose tgt claim equals the path parameter param,
+// so users can only act on their own resources
+func RequireTarget(param string) Rule {
+	return Rule{
+		Description: fmt.Sprintf("tgt == path param '%s'", param),
+		Allow: func(ctx context.EMFContext, claims jwt.MapClaims) bool {
+			var tgt = claimString(claims, "tgt")
+			return tgt != "" && tgt == ctx.Param(param)
+		},
+	}
+}
+
+// RequirePredicate allows tokens for which f returns true
+func RequirePredicate(description string, f func(ctx context.EMFContext, claims jwt.MapClaims) bool) Rule {
+	return Rule{Description: description, Allow: f}
+}
+
+// AnyOf allows tokens allowed by at least one of the rules
+func AnyOf(rules ...Rule) Rule {
+	return Rule{
+		Description: joinRules(rules, " or "),
+		Allow: func(ctx context.EMFContext, claims jwt.MapClaims) bool {
+			for _, r := range rules {
+				if r.Allow(ctx, claims) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// AllOf allows tokens allowed by every one of the rules
+func AllOf(rules ...Rule) Rule {
+	return Rule{
+		Description: joinRules(rules, " and "),
+		Allow: func(ctx context.EMFContext, claims jwt.MapClaims) bool {
+			for _, r := range rules {
+				if !r.Allow(ctx, claims) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// PolicyMiddleware authorizes the requests of the routes it is passed to against a named set of Rules,
+// which must all allow the JWT token set by the AuthMiddleware.
+// Denied requests are returned as emf.401.Unauthorized errors.
+type PolicyMiddleware struct {
+	name  string
+	rules []Rule
+}
+
+// NewPolicyMiddleware is a variadic constructor for a PolicyMiddleware
+func NewPolicyMiddleware(name string, rules ...Rule) *PolicyMiddleware {
+	return &PolicyMiddleware{name: name, rules: rules}
+}
+
+// Name identifies the policy and its rules, as listed on /noauth/routes
+func (pm *PolicyMiddleware) Name() string {
+	return fmt.Sprintf("policy %s: %s", pm.name, pm.String())
+}
+
+// Rules returns the descriptions of the rules of the policy
+func (pm *PolicyMiddleware) Rules() (rules []string) {
+	for _, r := range pm.rules {
+		rules = append(rules, r.Description)
+	}
+	return
+}
+
+// String describes the rules of the policy
+func (pm *PolicyMiddleware) String() string {
+	if len(pm.rules) == 0 {
+		return "any valid token"
+	}
+	return joinRules(pm.rules, " and ")
+}
+
+// Wrapper is a pass through function for handlers that implicitly performs additional business
+// logic per request.
+func (pm *PolicyMiddleware) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		var ctx = c.(context.EMFContext)
+
+		var token, ok = ctx.Get("user").(*jwt.Token)
+		if !ok || token == nil {
+			return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
+				"error": fmt.Errorf("JWT Token not set in Context, run Auth middleware first"),
+			})
+		}
+
+		var claims jwt.MapClaims
+		if claims, err = mapClaims(token.Claims); err != nil {
+			return ctx.NewError("emf.401.TokenVerificationFailure", map[string]interface{}{
+				"error": err,
+			})
+		}
+
+		for _, r := range pm.rules {
+			if !r.Allow(ctx, claims) {
+				ctx.Logger().Debugf("Policy '%s' denied the request: %s", pm.name, r.Description)
+				return ctx.NewError("emf.401.Unauthorized", map[string]interface{}{
+					"Role":   claimString(claims, "rol"),
+					"Target": claimString(claims, "tgt"),
+				})
+			}
+		}
+
+		return next(ctx)
+	}
+}
+
+// RolePrefix returns the lower case prefix of a rol claim, such as "pa" for "PA-1234"
+func RolePrefix(role string) string {
+	return strings.ToLower(strings.Split(role, "-")[0])
+}
+
+// mapClaims converts claims parsed into a custom type to a jwt.MapClaims
+func mapClaims(claims jwt.Claims) (m jwt.MapClaims, err error) {
+	if m, ok := claims.(jwt.MapClaims); ok {
+		return m, nil
+	}
+
+	var raw []byte
+	if raw, err = json.Marshal(claims); err != nil {
+		return nil, fmt.Errorf("failed to read the JWT Token claims: %w", err)
+	}
+	if err = json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("failed to read the JWT Token claims: %w", err)
+	}
+	return m, nil
+}
+
+// claimString formats a claim as GetClaim does, or returns "" if it is missing
+func claimString(claims jwt.MapClaims, name string) string {
+	switch val := claims[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return fmt.Sprintf("%.0f", val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// claimStrings reads a claim holding a space separated string or an array of strings
+func claimStrings(claims jwt.MapClaims, name string) (values []string) {
+	switch val := claims[name].(type) {
+	case string:
+		return strings.Fields(val)
+	case []string:
+		return val
+	case []interface{}:
+		for _, v := range val {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return
+}
+
+func joinRules(rules []Rule, sep string) string {
+	var parts = make([]string, 0, len(rules))
+	for _, r := range rules {
+		parts = append(parts, "("+r.Description+")")
+	}
+	if len(parts) == 1 {
+		return rules[0].Description
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// policyContext returns the context of a request to /accounts/:id authenticated with claims,
+// and introspected into introspection when it is not nil
+func policyContext(id string, claims jwt.MapClaims, introspection map[string]interface{}) context.EMFContext {
+	var req = httptest.NewRequest(http.MethodGet, "/accounts/"+id, nil)
+	var ctx = context.NewEMFContext(echo.New().NewContext(req, httptest.NewRecorder()), viper.New())
+	ctx.SetParamNames("id")
+	ctx.SetParamValues(id)
+	if claims != nil {
+		ctx.Set("user", &jwt.Token{Claims: claims, Valid: true})
+	}
+	if introspection != nil {
+		ctx.Set(context.IntrospectionKey, introspection)
+	}
+	return ctx
+}
+
+func TestPolicyRules(t *testing.T) {
+	var isOwner = RequirePredicate("owner claim set", func(_ context.EMFContext, claims jwt.MapClaims) bool {
+		return claims["owner"] == true
+	})
+
+	var cases = []struct {
+		name          string
+		rule          Rule
+		claims        jwt.MapClaims
+		introspection map[string]interface{}
+		allowed       bool
+	}{
+		{"role", RequireRole("pa", "sp"), jwt.MapClaims{"rol": "PA-1234"}, nil, true},
+		{"role in roles", RequireRole("sp"), jwt.MapClaims{"rol": "tp-1", "roles": []interface{}{"sp-2"}}, nil, true},
+		{"other role", RequireRole("pa"), jwt.MapClaims{"rol": "tp-1234"}, nil, false},
+		{"missing role", RequireRole("pa"), jwt.MapClaims{}, nil, false},
+
+		{"scope", RequireScope("read", "write"), jwt.MapClaims{"scope": "read write admin"}, nil, true},
+		{"scp array", RequireScope("read"), jwt.MapClaims{"scp": []interface{}{"read"}}, nil, true},
+		{"introspected scope", RequireScope("write"), jwt.MapClaims{"scope": "read"},
+			map[string]interface{}{"scope": "write"}, true},
+		{"missing scope", RequireScope("read", "write"), jwt.MapClaims{"scope": "read"}, nil, false},
+
+		{"claim", RequireClaim("tenant", "acme"), jwt.MapClaims{"tenant": "acme"}, nil, true},
+		{"numeric claim", RequireClaim("level", "3"), jwt.MapClaims{"level": float64(3)}, nil, true},
+		{"other claim", RequireClaim("tenant", "acme"), jwt.MapClaims{"tenant": "other"}, nil, false},
+		{"missing claim", RequireClaim("tenant", ""), jwt.MapClaims{}, nil, false},
+
+		{"target", RequireTarget("id"), jwt.MapClaims{"tgt": "1234"}, nil, true},
+		{"other target", RequireTarget("id"), jwt.MapClaims{"tgt": "5678"}, nil, false},
+		{"missing target", RequireTarget("other"), jwt.MapClaims{"tgt": ""}, nil, false},
+
+		{"predicate", isOwner, jwt.MapClaims{"owner": true}, nil, true},
+		{"false predicate", isOwner, jwt.MapClaims{}, nil, false},
+
+		{"any of", AnyOf(RequireRole("pa"), RequireTarget("id")), jwt.MapClaims{"rol": "tp-1", "tgt": "1234"}, nil,
+			true},
+		{"none of", AnyOf(RequireRole("pa"), RequireTarget("id")), jwt.MapClaims{"rol": "tp-1"}, nil, false},
+		{"all of", AllOf(RequireRole("tp"), RequireTarget("id")), jwt.MapClaims{"rol": "tp-1", "tgt": "1234"}, nil,
+			true},
+		{"not all of", AllOf(RequireRole("tp"), RequireTarget("id")), jwt.MapClaims{"rol": "tp-1"}, nil, false},
+	}
+
+	for _, c := range cases {
+		var ctx = policyContext("1234", c.claims, c.introspection)
+		if got := c.rule.Allow(ctx, c.claims); got != c.allowed {
+			t.Errorf("%s: expected allowed: %v, got %v", c.name, c.allowed, got)
+		}
+	}
+}
+
+func TestPolicyDescriptions(t *testing.T) {
+	var pm = NewPolicyMiddleware("accounts", AnyOf(RequireRole("pa"), RequireTarget("id")), RequireScope("read"))
+
+	var expected = "policy accounts: ((rol prefix in [pa]) or (tgt == path param 'id')) and (scopes include [read])"
+	if got := pm.Name(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := NewPolicyMiddleware("open").String(); got != "any valid token" {
+		t.Errorf("expected a policy without rules to describe any valid token, got %q", got)
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	var pm = NewPolicyMiddleware("accounts", RequireRole("pa"), RequireTarget("id"))
+
+	var cases = []struct {
+		name   string
+		claims jwt.MapClaims
+		code   string
+		data   map[string]interface{}
+	}{
+		{"allowed", jwt.MapClaims{"rol": "pa-1", "tgt": "1234"}, "", nil},
+		{"denied by the first rule", jwt.MapClaims{"rol": "tp-1", "tgt": "1234"}, "emf.401.Unauthorized",
+			map[string]interface{}{"Role": "tp-1", "Target": "1234"}},
+		{"denied by the second rule", jwt.MapClaims{"rol": "pa-1", "tgt": "5678"}, "emf.401.Unauthorized",
+			map[string]interface{}{"Role": "pa-1", "Target": "5678"}},
+		{"without a token", nil, "emf.401.TokenVerificationFailure", nil},
+	}
+
+	for _, c := range cases {
+		var called bool
+		var err = pm.Wrapper(func(echo.Context) error { called = true; return nil })(policyContext("1234", c.claims, nil))
+
+		if c.code == "" {
+			if err != nil || !called {
+				t.Errorf("%s: expected the request to be allowed, got %v", c.name, err)
+			}
+			continue
+		}
+		if called {
+			t.Errorf("%s: expected the handler not to be called", c.name)
+		}
+		var e, ok = err.(*errors.EMFErrorType)
+		if !ok || e.ErrorCode != c.code || e.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected a 401 %s error, got %v", c.name, c.code, err)
+			continue
+		}
+		for k, v := range c.data {
+			if e.Data[k] != v {
+				t.Errorf("%s: expected %s to be %v in the error, got %v", c.name, k, v, e.Data[k])
+			}
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"sync"
+
 	"github.com/didip/tollbooth"
 	"github.com/didip/tollbooth/limiter"
 	"github.com/labstack/echo/v4"
@@ -8,6 +10,7 @@ import (
 
 // RateLimitMiddleware provides a middleware that verifies required authentication for endpoints.
 type RateLimitMiddleware struct {
+	mu      sync.RWMutex
 	limiter *limiter.Limiter
 }
 
@@ -20,6 +23,28 @@ func WithLimiter(lmt *limiter.Limiter) RateLimitOption {
 	return func(rlm *RateLimitMiddleware) { rlm.limiter = lmt }
 }
 
+// WithRate is used for specifying the maximum number of requests per second for each client.
+func WithRate(max float64) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		if max > 0 {
+			rlm.limiter = tollbooth.NewLimiter(max, nil)
+		}
+	}
+}
+
+// SetRate replaces the limiter with one allowing max requests per second for each client,
+// dropping the request history of the previous limiter.
+func (rlm *RateLimitMiddleware) SetRate(max float64) {
+	if max <= 0 {
+		return
+	}
+	var lmt = tollbooth.NewLimiter(max, nil)
+
+	rlm.mu.Lock()
+	defer rlm.mu.Unlock()
+	rlm.limiter = lmt
+}
+
 // NewRateLimitMiddleware is a variadic constructor for a RateLimitMiddleware.
 func NewRateLimitMiddleware(opts ...RateLimitOption) *RateLimitMiddleware {
 	const oneRequestPerSecond = 1
@@ -38,7 +63,11 @@ func NewRateLimitMiddleware(opts ...RateLimitOption) *RateLimitMiddleware {
 // logic per request.
 func (rlm *RateLimitMiddleware) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
 	return echo.HandlerFunc(func(c echo.Context) error {
-		httpError := tollbooth.LimitByRequest(rlm.limiter, c.Response(), c.Request())
+		rlm.mu.RLock()
+		var lmt = rlm.limiter
+		rlm.mu.RUnlock()
+
+		httpError := tollbooth.LimitByRequest(lmt, c.Response(), c.Request())
 		if httpError != nil {
 			return echo.NewHTTPError(httpError.StatusCode, httpError.Message)
 		}
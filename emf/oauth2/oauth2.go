@@ -0,0 +1,188 @@
+// Package oauth2 fetches OAuth2 client credentials access tokens for calling external APIs, such as KYC or
+// ledger providers. Tokens are cached per profile and fetched again shortly before they expire.
+package oauth2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExpiryMargin = 30 * time.Second
+	defaultFetchTimeout = 10 * time.Second
+	maxTokenResponse    = 1 << 20
+)
+
+// Profile is the client credentials configuration of an external service
+type Profile struct {
+	TokenURL string `mapstructure:"token_url"`
+	ClientID string `mapstructure:"client_id"`
+	// ClientSecret is expanded with environment variables, so it can reference one as ${KYC_CLIENT_SECRET}
+	ClientSecret string `mapstructure:"client_secret"`
+	// ClientSecretFile is read on every fetch instead of ClientSecret when set, so it can be rotated
+	ClientSecretFile string   `mapstructure:"client_secret_file"`
+	Scopes           []string `mapstructure:"scopes"`
+	Audience         string   `mapstructure:"audience"`
+	// AuthStyle is "header" to send the credentials with HTTP Basic authentication, the default,
+	// or "params" to send them in the form body
+	AuthStyle string `mapstructure:"auth_style"`
+}
+
+// Token is a token endpoint response of RFC 6749
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// TokenError is an error response of the token endpoint
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Error implements the standard error interface
+func (te *TokenError) Error() string {
+	if te.Code == "" {
+		return fmt.Sprintf("token request failed with status %d", te.StatusCode)
+	}
+	return fmt.Sprintf("token request failed with status %d: %s %s", te.StatusCode, te.Code, te.Description)
+}
+
+// Source caches the access token of a Profile, fetching a new one when it is about to expire
+type Source struct {
+	profile Profile
+	client  *http.Client
+	margin  time.Duration
+
+	mu      sync.Mutex
+	token   *Token
+	expires time.Time
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Source.
+type Option func(*Source)
+
+// WithHTTPClient sets the client used to send token requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Source) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithExpiryMargin sets how long before its expiry a token is replaced, 30 seconds by default
+func WithExpiryMargin(d time.Duration) Option {
+	return func(s *Source) { s.margin = d }
+}
+
+// NewSource is a variadic constructor for a Source fetching the tokens of profile
+func NewSource(profile Profile, opts ...Option) *Source {
+	var s = &Source{
+		profile: profile,
+		client:  &http.Client{Timeout: defaultFetchTimeout},
+		margin:  defaultExpiryMargin,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Token returns the cached token, or fetches a new one when it is missing or about to expire.
+// Concurrent callers wait for a single fetch.
+func (s *Source) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	var token, err = s.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	s.token, s.expires = token, time.Now().Add(time.Duration(token.ExpiresIn)*time.Second-s.margin)
+	return token, nil
+}
+
+// Invalidate drops the cached token, for example after the external API rejected it
+func (s *Source) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = nil
+}
+
+func (s *Source) fetch() (token *Token, err error) {
+	var secret = os.ExpandEnv(s.profile.ClientSecret)
+	if s.profile.ClientSecretFile != "" {
+		var data []byte
+		if data, err = ioutil.ReadFile(os.ExpandEnv(s.profile.ClientSecretFile)); err != nil {
+			return nil, fmt.Errorf("failed to read the client secret: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+
+	var form = url.Values{"grant_type": {"client_credentials"}}
+	if len(s.profile.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.profile.Scopes, " "))
+	}
+	if s.profile.Audience != "" {
+		form.Set("audience", s.profile.Audience)
+	}
+	if s.profile.AuthStyle == "params" {
+		form.Set("client_id", s.profile.ClientID)
+		form.Set("client_secret", secret)
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, s.profile.TokenURL, strings.NewReader(form.Encode())); err != nil {
+		return nil, fmt.Errorf("invalid token URL '%s': %w", s.profile.TokenURL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.profile.AuthStyle != "params" {
+		req.SetBasicAuth(url.QueryEscape(s.profile.ClientID), url.QueryEscape(secret))
+	}
+
+	var res *http.Response
+	if res, err = s.client.Do(req); err != nil {
+		return nil, fmt.Errorf("token request to '%s' failed: %w", s.profile.TokenURL, err)
+	}
+	defer res.Body.Close()
+
+	var body []byte
+	if body, err = ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, maxTokenResponse)); err != nil {
+		return nil, fmt.Errorf("failed to read the token response of '%s': %w", s.profile.TokenURL, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var te = &TokenError{}
+		_ = json.Unmarshal(body, te)
+		te.StatusCode = res.StatusCode
+		return nil, te
+	}
+
+	token = &Token{}
+	if err = json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("failed to decode the token response of '%s': %w", s.profile.TokenURL, err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response of '%s' has no access_token", s.profile.TokenURL)
+	}
+	return token, nil
+}
@@ -0,0 +1,203 @@
+package oauth2
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenServer is a token endpoint answering with a fixed status and body, recording the requests
+type tokenServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	body     string
+	requests []*http.Request
+	forms    []url.Values
+}
+
+func newTokenServer(status int, body string) *tokenServer {
+	var ts = &tokenServer{status: status, body: body}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		ts.mu.Lock()
+		ts.requests = append(ts.requests, r)
+		ts.forms = append(ts.forms, r.PostForm)
+		var status, body = ts.status, ts.body
+		ts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body)) //nolint:errcheck
+	}))
+	return ts
+}
+
+// last returns the last request received and its form
+func (ts *tokenServer) last() (*http.Request, url.Values) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests[len(ts.requests)-1], ts.forms[len(ts.forms)-1]
+}
+
+func (ts *tokenServer) fetches() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return len(ts.requests)
+}
+
+func TestSourceRequest(t *testing.T) {
+	var secretFile, err = ioutil.TempFile("", "oauth2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secretFile.Name())
+	_, _ = secretFile.WriteString("file-secret\n")
+	secretFile.Close()
+
+	os.Setenv("OAUTH2_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("OAUTH2_TEST_SECRET")
+
+	var cases = []struct {
+		name    string
+		profile Profile
+		basic   [2]string
+		form    map[string]string
+	}{
+		{"header", Profile{ClientID: "emf", ClientSecret: "s3cr:t"}, [2]string{"emf", "s3cr:t"},
+			map[string]string{"grant_type": "client_credentials", "client_id": "", "client_secret": ""}},
+		{"params", Profile{ClientID: "emf", ClientSecret: "secret", AuthStyle: "params"}, [2]string{},
+			map[string]string{"client_id": "emf", "client_secret": "secret"}},
+		{"scopes and audience", Profile{ClientID: "emf", Scopes: []string{"read", "write"}, Audience: "kyc"},
+			[2]string{"emf", ""}, map[string]string{"scope": "read write", "audience": "kyc"}},
+		{"environment secret", Profile{ClientID: "emf", ClientSecret: "${OAUTH2_TEST_SECRET}"},
+			[2]string{"emf", "env-secret"}, nil},
+		{"secret file", Profile{ClientID: "emf", ClientSecret: "ignored", ClientSecretFile: secretFile.Name()},
+			[2]string{"emf", "file-secret"}, nil},
+	}
+
+	for _, c := range cases {
+		var ts = newTokenServer(http.StatusOK, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+		c.profile.TokenURL = ts.URL
+
+		if _, err := NewSource(c.profile).Token(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			ts.Close()
+			continue
+		}
+
+		var req, form = ts.last()
+		var id, secret, ok = req.BasicAuth()
+		if (c.basic != [2]string{}) != ok || id != c.basic[0] || secret != url.QueryEscape(c.basic[1]) {
+			t.Errorf("%s: expected the basic credentials %v, got %q %q", c.name, c.basic, id, secret)
+		}
+		for k, v := range c.form {
+			if form.Get(k) != v {
+				t.Errorf("%s: expected the form value %s=%q, got %q", c.name, k, v, form.Get(k))
+			}
+		}
+		ts.Close()
+	}
+}
+
+func TestSourceCaching(t *testing.T) {
+	var cases = []struct {
+		name      string
+		expiresIn string
+		fetches   int
+	}{
+		{"cached", "3600", 1},
+		{"within the expiry margin", "20", 3},
+	}
+
+	for _, c := range cases {
+		var ts = newTokenServer(http.StatusOK, `{"access_token":"token","expires_in":`+c.expiresIn+`}`)
+		var s = NewSource(Profile{TokenURL: ts.URL, ClientID: "emf"})
+
+		for i := 0; i < 3; i++ {
+			if token, err := s.Token(); err != nil || token.AccessToken != "token" {
+				t.Fatalf("%s: expected a token, got %v %v", c.name, token, err)
+			}
+		}
+		if ts.fetches() != c.fetches {
+			t.Errorf("%s: expected %d fetches, got %d", c.name, c.fetches, ts.fetches())
+		}
+		ts.Close()
+	}
+}
+
+func TestSourceRefresh(t *testing.T) {
+	var ts = newTokenServer(http.StatusOK, `{"access_token":"token","expires_in":1}`)
+	defer ts.Close()
+
+	var s = NewSource(Profile{TokenURL: ts.URL}, WithExpiryMargin(900*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		if _, err := s.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ts.fetches() != 1 {
+		t.Fatalf("expected the token to be cached, got %d fetches", ts.fetches())
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := s.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if ts.fetches() != 2 {
+		t.Errorf("expected the token to be fetched again once it is about to expire, got %d fetches", ts.fetches())
+	}
+
+	s.Invalidate()
+	if _, err := s.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if ts.fetches() != 3 {
+		t.Errorf("expected the token to be fetched again after Invalidate, got %d fetches", ts.fetches())
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	var cases = []struct {
+		name   string
+		status int
+		body   string
+		code   string
+	}{
+		{"error response", http.StatusBadRequest, `{"error":"invalid_client","error_description":"unknown"}`,
+			"invalid_client"},
+		{"error without body", http.StatusInternalServerError, "", ""},
+		{"invalid JSON", http.StatusOK, `{"access_token":`, ""},
+		{"no access token", http.StatusOK, `{"token_type":"Bearer"}`, ""},
+	}
+
+	for _, c := range cases {
+		var ts = newTokenServer(c.status, c.body)
+		var token, err = NewSource(Profile{TokenURL: ts.URL}).Token()
+		ts.Close()
+
+		if err == nil {
+			t.Errorf("%s: expected an error, got %v", c.name, token)
+			continue
+		}
+		var te *TokenError
+		if isTokenError := errors.As(err, &te); isTokenError != (c.status != http.StatusOK) {
+			t.Errorf("%s: expected a TokenError: %v, got %v", c.name, c.status != http.StatusOK, err)
+		} else if isTokenError && (te.StatusCode != c.status || te.Code != c.code) {
+			t.Errorf("%s: expected status %d and code %q, got %+v", c.name, c.status, c.code, te)
+		}
+	}
+
+	var _, err = NewSource(Profile{TokenURL: "http://127.0.0.1:1", ClientSecretFile: "testdata/missing"}).Token()
+	if err == nil {
+		t.Error("expected a missing client secret file to be reported")
+	}
+}
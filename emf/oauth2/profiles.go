@@ -0,0 +1,90 @@
+package oauth2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+)
+
+// Profiles holds a Source per external service profile of the oauth2 config block. A profile applies to the
+// requests sent under the domains entry of the same name, so Requester calls to the service are authorized
+// with its access token instead of the user token.
+type Profiles struct {
+	cfg      configurer.ConfigReader
+	resolver discovery.Resolver
+	sources  map[string]*Source
+	names    []string
+}
+
+// LoadProfiles reads the profiles of the oauth2 config block
+func LoadProfiles(cfg configurer.ConfigReader, opts ...Option) (*Profiles, error) {
+	var profiles map[string]Profile
+	if err := cfg.UnmarshalKey("oauth2", &profiles); err != nil {
+		return nil, fmt.Errorf("invalid oauth2 profiles: %w", err)
+	}
+
+	var p = &Profiles{cfg: cfg, resolver: discovery.NewStatic(cfg), sources: map[string]*Source{}}
+	for name, profile := range profiles {
+		if profile.TokenURL == "" || profile.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 profile '%s' requires a token_url and a client_id", name)
+		}
+		p.sources[name] = NewSource(profile, opts...)
+		p.names = append(p.names, name)
+	}
+	sort.Strings(p.names)
+
+	return p, nil
+}
+
+// Source returns the Source of the profile name, or nil
+func (p *Profiles) Source(name string) *Source {
+	return p.sources[name]
+}
+
+// Len returns the number of profiles
+func (p *Profiles) Len() int {
+	return len(p.names)
+}
+
+// SetResolver resolves the domains of the profiles with r, as the Requester does, instead of the domains block
+func (p *Profiles) SetResolver(r discovery.Resolver) {
+	if r != nil {
+		p.resolver = r
+	}
+}
+
+// Authorization returns the Authorization header of a request to rawURL, when it is under an endpoint of the
+// domain of a profile. The longest matching endpoint wins.
+func (p *Profiles) Authorization(rawURL string) (header string, ok bool, err error) {
+	var match, length = "", 0
+	for _, name := range p.names {
+		// Profiles without a domain only provide their Source
+		var endpoints, _ = p.resolver.Resolve(name)
+		for _, e := range endpoints {
+			var domain = strings.TrimSuffix(e.URL, "/")
+			if domain == "" || len(domain) <= length {
+				continue
+			}
+			if rawURL == domain || strings.HasPrefix(rawURL, domain+"/") || strings.HasPrefix(rawURL, domain+"?") {
+				match, length = name, len(domain)
+			}
+		}
+	}
+	if match == "" {
+		return "", false, nil
+	}
+
+	var token *Token
+	if token, err = p.sources[match].Token(); err != nil {
+		return "", true, fmt.Errorf("oauth2 profile '%s': %w", match, err)
+	}
+
+	var tokenType = token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + token.AccessToken, true, nil
+}
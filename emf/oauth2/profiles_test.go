@@ -0,0 +1,38 @@
+package oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestProfilesEndpointLists(t *testing.T) {
+	var tokens = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"kyc-token","expires_in":3600}`)) //nolint:errcheck
+	}))
+	defer tokens.Close()
+
+	var v = viper.New()
+	v.Set("domains.kyc", []interface{}{"https://kyc-a.example.com/api", "https://kyc-b.example.com/api"})
+	v.Set("oauth2.kyc", map[string]interface{}{"token_url": tokens.URL, "client_id": "emf"})
+
+	var p, err = LoadProfiles(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = map[string]bool{
+		"https://kyc-a.example.com/api/checks":   true,
+		"https://kyc-b.example.com/api?limit=1":  true,
+		"https://kyc-b.example.com/apiv2/checks": false,
+		"https://ledger.example.com/api":         false,
+	}
+	for url, want := range cases {
+		var header, ok, err = p.Authorization(url)
+		if err != nil || ok != want || (ok && header != "Bearer kyc-token") {
+			t.Errorf("%s: expected a token: %v, got '%s', %v, %v", url, want, header, ok, err)
+		}
+	}
+}
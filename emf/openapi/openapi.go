@@ -0,0 +1,375 @@
+// Package openapi builds an OpenAPI 3 document from the routes recorded in a router Registry.
+// Request and response schemas are reflected from the Go types attached with router.Describe,
+// and error responses from the EMFError definitions of the builtin errors and the errors file.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+	"github.com/cambridge-blockchain/emf/emf/router"
+)
+
+// Version is the OpenAPI specification version of the generated documents
+const Version = "3.0.3"
+
+const (
+	mimeJSON          = "application/json"
+	errorSchemaName   = "EMFError"
+	bearerSchemeName  = "bearerAuth"
+	wildcardParamName = "wildcard"
+)
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string              `json:"openapi" yaml:"openapi"`
+	Info       Info                `json:"info" yaml:"info"`
+	Servers    []Server            `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths" yaml:"paths"`
+	Components Components          `json:"components" yaml:"components"`
+}
+
+// Info is the metadata of the API
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+// Server is a base URL the API is served on
+type Server struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+// PathItem holds the operations of a path, by lower case HTTP method
+type PathItem map[string]*Operation
+
+// Operation describes a single route
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses" yaml:"responses"`
+	Security    []map[string][]string `json:"security,omitempty" yaml:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema" yaml:"schema"`
+}
+
+// RequestBody is the body of a request
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]MediaType `json:"content" yaml:"content"`
+}
+
+// MediaType is the schema of a body for one content type
+type MediaType struct {
+	Schema *Schema `json:"schema" yaml:"schema"`
+}
+
+// Response is a response for one status code
+type Response struct {
+	Description string               `json:"description" yaml:"description"`
+	Content     map[string]MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// Components holds the schemas referenced from the operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is an authentication method of the API
+type SecurityScheme struct {
+	Type         string `json:"type" yaml:"type"`
+	Scheme       string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty" yaml:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty" yaml:"in,omitempty"`
+	Name         string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// Builder holds the options used to build a Document
+type Builder struct {
+	info    Info
+	servers []Server
+	catalog *errors.Catalog
+	schemes map[string]SecurityScheme
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Builder.
+type Option func(*Builder)
+
+// WithInfo sets the title, version and description of the API. Empty fields keep their default.
+func WithInfo(info Info) Option {
+	return func(b *Builder) {
+		if info.Title != "" {
+			b.info.Title = info.Title
+		}
+		if info.Version != "" {
+			b.info.Version = info.Version
+		}
+		b.info.Description = info.Description
+	}
+}
+
+// WithServers sets the base URLs the API is served on
+func WithServers(urls ...string) Option {
+	return func(b *Builder) {
+		for _, u := range urls {
+			if u != "" {
+				b.servers = append(b.servers, Server{URL: u})
+			}
+		}
+	}
+}
+
+// WithErrorCatalog sets the Catalog used to describe the EMFError codes of the routes.
+// Builtin errors are always described.
+func WithErrorCatalog(c *errors.Catalog) Option {
+	return func(b *Builder) { b.catalog = c }
+}
+
+// WithSecurityScheme maps the Auth value of route metadata to a security scheme.
+// "jwt" is mapped to a bearer JWT scheme by default.
+func WithSecurityScheme(auth string, scheme SecurityScheme) Option {
+	return func(b *Builder) { b.schemes[auth] = scheme }
+}
+
+// NewBuilder is a variadic constructor for a Builder
+func NewBuilder(opts ...Option) *Builder {
+	var b = &Builder{
+		info: Info{Title: "EMF Service", Version: "0.0.0"},
+		schemes: map[string]SecurityScheme{
+			"jwt": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Build creates the Document describing routes
+func (b *Builder) Build(routes []router.Route) *Document {
+	var doc = &Document{
+		OpenAPI:    Version,
+		Info:       b.info,
+		Servers:    b.servers,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+	var gen = newGenerator(doc.Components.Schemas)
+	var ids = map[string]int{}
+
+	for _, r := range routes {
+		var method = strings.ToLower(r.Method)
+		if !isOpenAPIMethod(r.Method) {
+			continue
+		}
+
+		var p, params = convertPath(r.Path)
+		var op = &Operation{
+			OperationID: operationID(r, ids),
+			Summary:     r.Summary,
+			Description: r.Description,
+			Tags:        r.Tags,
+			Parameters:  params,
+			Responses:   map[string]Response{},
+			Deprecated:  r.Deprecated,
+		}
+
+		b.addRequest(gen, op, r)
+		b.addResponses(gen, op, r)
+		b.addSecurity(doc, op, r.Auth)
+
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = PathItem{}
+		}
+		doc.Paths[p][method] = op
+	}
+
+	if len(doc.Components.Schemas) == 0 {
+		doc.Components.Schemas = nil
+	}
+	return doc
+}
+
+func (b *Builder) addRequest(gen *generator, op *Operation, r router.Route) {
+	if r.Request == nil {
+		return
+	}
+
+	var t = indirect(reflect.TypeOf(r.Request))
+	if t.Kind() == reflect.Struct {
+		op.Parameters = mergeParameters(op.Parameters, gen.parameters(t))
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return
+	}
+
+	if body := gen.body(t); body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{mimeJSON: {Schema: body}},
+		}
+	}
+}
+
+func (b *Builder) addResponses(gen *generator, op *Operation, r router.Route) {
+	var status = r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	var success = Response{Description: http.StatusText(status)}
+	if r.Response != nil {
+		success.Content = map[string]MediaType{mimeJSON: {Schema: gen.schema(reflect.TypeOf(r.Response))}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	// Group the error codes by status, every group shares the EMFError schema
+	var byStatus = map[string][]string{}
+	for _, code := range r.Errors {
+		var def, ok = b.catalog.Definition(code)
+		var key = statusKey(def.StatusCode, code)
+
+		var line = "`" + code + "`"
+		if ok && def.Description != "" {
+			line += ": " + strings.TrimSpace(def.Description)
+		}
+		byStatus[key] = append(byStatus[key], line)
+	}
+
+	if len(byStatus) == 0 {
+		return
+	}
+
+	var errSchema = gen.schema(errorType)
+	for key, lines := range byStatus {
+		op.Responses[key] = Response{
+			Description: strings.Join(lines, "\n\n"),
+			Content:     map[string]MediaType{mimeJSON: {Schema: errSchema}},
+		}
+	}
+}
+
+func (b *Builder) addSecurity(doc *Document, op *Operation, auth string) {
+	switch auth {
+	case "":
+		return
+	case "none":
+		op.Security = []map[string][]string{}
+		return
+	}
+
+	var name = auth + "Auth"
+	if auth == "jwt" {
+		name = bearerSchemeName
+	}
+	if scheme, ok := b.schemes[auth]; ok {
+		if doc.Components.SecuritySchemes == nil {
+			doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+		}
+		doc.Components.SecuritySchemes[name] = scheme
+	}
+	op.Security = []map[string][]string{{name: {}}}
+}
+
+// statusKey returns the status of an error, falling back on the status segment of its code,
+// as in emf.401.Unauthorized, and on "default" when neither is known
+func statusKey(status int, code string) string {
+	if status != 0 {
+		return strconv.Itoa(status)
+	}
+	if parts := strings.Split(code, "."); len(parts) >= 3 {
+		if s, err := strconv.Atoi(parts[len(parts)-2]); err == nil && s >= 100 && s < 600 {
+			return strconv.Itoa(s)
+		}
+	}
+	return "default"
+}
+
+// convertPath converts an echo path such as /users/:id/* to /users/{id}/{wildcard} and its parameters
+func convertPath(p string) (string, []Parameter) {
+	var (
+		segments = strings.Split(p, "/")
+		params   []Parameter
+	)
+
+	for i, seg := range segments {
+		var name string
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			name = seg[1:]
+		case seg == "*":
+			name = wildcardParamName
+		default:
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// mergeParameters adds the parameters reflected from the request type, which take precedence
+// over the untyped parameters found in the path
+func mergeParameters(params, typed []Parameter) []Parameter {
+	for _, t := range typed {
+		var replaced bool
+		for i, p := range params {
+			if p.Name == t.Name && p.In == t.In {
+				params[i], replaced = t, true
+			}
+		}
+		if !replaced && t.In != "path" {
+			params = append(params, t)
+		}
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].In == "path" && params[j].In != "path" })
+	return params
+}
+
+// operationID names an operation after its handler, numbering it when the handler serves several routes
+func operationID(r router.Route, ids map[string]int) string {
+	var name = strings.TrimSuffix(r.Handler, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || strings.HasPrefix(name, "func") {
+		return ""
+	}
+
+	ids[name]++
+	if ids[name] > 1 {
+		return fmt.Sprintf("%s%d", name, ids[name])
+	}
+	return name
+}
+
+func isOpenAPIMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+		http.MethodOptions, http.MethodHead, http.MethodPatch, http.MethodTrace:
+		return true
+	}
+	return false
+}
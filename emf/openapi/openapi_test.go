@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cambridge-blockchain/emf/emf/router"
+)
+
+type getAccountRequest struct {
+	ID      string `param:"id" validate:"required"`
+	Verbose bool   `query:"verbose"`
+	Trace   string `header:"X-Trace"`
+}
+
+type account struct {
+	ID      string            `json:"id" validate:"required"`
+	Name    string            `json:"name" validate:"min=1,max=64"`
+	Tags    []string          `json:"tags" validate:"dive,alphanum"`
+	Meta    map[string]string `json:"meta,omitempty"`
+	Created time.Time         `json:"created"`
+	Owner   *account          `json:"owner,omitempty"`
+	Secret  string            `json:"-"`
+}
+
+type createAccountRequest struct {
+	Name string `json:"name" validate:"required,oneof=savings current"`
+	Kind int    `json:"kind" validate:"gte=1,lt=4"`
+}
+
+var openapiInfo = Info{Title: "Ledger", Version: "1.2.0", Description: "Accounts and transfers"}
+
+func route(method, path, handler string, meta router.Metadata) router.Route {
+	return router.Route{Method: method, Path: path, Handler: handler, Metadata: meta}
+}
+
+func TestBuild(t *testing.T) {
+	var b = NewBuilder(
+		WithInfo(openapiInfo),
+		WithServers("", "https://ledger.example.com"),
+	)
+	var doc = b.Build([]router.Route{
+		route(http.MethodGet, "/accounts/:id", "ledger.getAccount", router.Metadata{
+			Summary:  "Get an account",
+			Auth:     "jwt",
+			Tags:     []string{"accounts"},
+			Request:  getAccountRequest{},
+			Response: account{},
+			Errors:   []string{"emf.401.Unauthorized", "ledger.404.AccountNotFound", "ledger.Unknown"},
+		}),
+		route(http.MethodPost, "/accounts", "ledger.createAccount", router.Metadata{
+			Auth:     "none",
+			Request:  &createAccountRequest{},
+			Response: &account{},
+			Status:   http.StatusCreated,
+		}),
+		route(http.MethodGet, "/v1/accounts/:id", "ledger.getAccount", router.Metadata{Deprecated: true}),
+		route(http.MethodGet, "/files/*", "ledger.RegisterFiles.func1", router.Metadata{}),
+		route(http.MethodConnect, "/tunnel", "ledger.tunnel", router.Metadata{}),
+	})
+
+	if doc.OpenAPI != Version || doc.Info != openapiInfo {
+		t.Errorf("expected the version and info of the document, got %s %+v", doc.OpenAPI, doc.Info)
+	}
+	if !reflect.DeepEqual(doc.Servers, []Server{{URL: "https://ledger.example.com"}}) {
+		t.Errorf("expected the configured server, got %+v", doc.Servers)
+	}
+
+	var paths []string
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	if len(paths) != 4 || doc.Paths["/accounts/{id}"] == nil || doc.Paths["/files/{wildcard}"] == nil {
+		t.Errorf("expected the echo paths converted without the CONNECT route, got %v", paths)
+	}
+
+	var get = doc.Paths["/accounts/{id}"]["get"]
+	if get == nil {
+		t.Fatal("expected the GET operation")
+	}
+	if get.OperationID != "getAccount" || get.Summary != "Get an account" || get.RequestBody != nil {
+		t.Errorf("expected the operation of the handler without a body, got %+v", get)
+	}
+	var params = []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "verbose", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Name: "X-Trace", In: "header", Schema: &Schema{Type: "string"}},
+	}
+	if !reflect.DeepEqual(get.Parameters, params) {
+		t.Errorf("expected the typed parameters, got %+v", get.Parameters)
+	}
+	if ref := get.Responses["200"].Content[mimeJSON].Schema.Ref; ref != "#/components/schemas/account" {
+		t.Errorf("expected the response to refer to the account schema, got %q", ref)
+	}
+	for status, code := range map[string]string{
+		"401":     "`emf.401.Unauthorized`: ",
+		"404":     "`ledger.404.AccountNotFound`",
+		"default": "`ledger.Unknown`",
+	} {
+		var res, ok = get.Responses[status]
+		if !ok || !strings.HasPrefix(res.Description, code) {
+			t.Errorf("expected the %s response to describe %s, got %q", status, code, res.Description)
+			continue
+		}
+		if ref := res.Content[mimeJSON].Schema.Ref; ref != "#/components/schemas/"+errorSchemaName {
+			t.Errorf("expected the %s response to refer to the error schema, got %q", status, ref)
+		}
+	}
+	if !reflect.DeepEqual(get.Security, []map[string][]string{{bearerSchemeName: {}}}) {
+		t.Errorf("expected the bearer security requirement, got %v", get.Security)
+	}
+	if _, ok := doc.Components.SecuritySchemes[bearerSchemeName]; !ok {
+		t.Errorf("expected the bearer security scheme, got %v", doc.Components.SecuritySchemes)
+	}
+
+	var post = doc.Paths["/accounts"]["post"]
+	if post == nil || post.RequestBody == nil || !post.RequestBody.Required {
+		t.Fatalf("expected the POST operation with a required body, got %+v", post)
+	}
+	if ref := post.RequestBody.Content[mimeJSON].Schema.Ref; ref != "#/components/schemas/createAccountRequest" {
+		t.Errorf("expected the body to refer to the request schema, got %q", ref)
+	}
+	if _, ok := post.Responses["201"]; !ok {
+		t.Errorf("expected the 201 response, got %v", post.Responses)
+	}
+	if post.Security == nil || len(post.Security) != 0 {
+		t.Errorf("expected no security requirement, got %v", post.Security)
+	}
+
+	if op := doc.Paths["/v1/accounts/{id}"]["get"]; op == nil || op.OperationID != "getAccount2" || !op.Deprecated {
+		t.Errorf("expected a numbered deprecated operation, got %+v", op)
+	}
+	if op := doc.Paths["/files/{wildcard}"]["get"]; op == nil || op.OperationID != "" {
+		t.Errorf("expected an anonymous handler not to name the operation, got %+v", op)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	var doc = NewBuilder().Build([]router.Route{
+		route(http.MethodPost, "/accounts", "ledger.createAccount", router.Metadata{
+			Request:  createAccountRequest{},
+			Response: account{},
+		}),
+	})
+
+	var one, four = 1.0, 4.0
+	var sixtyFour, oneLen = int64(64), int64(1)
+	var expected = map[string]*Schema{
+		"createAccountRequest": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"name": {Type: "string", Enum: []interface{}{"savings", "current"}},
+				"kind": {Type: "integer", Format: "int64", Minimum: &one, Maximum: &four, ExclusiveMaximum: true},
+			},
+			Required: []string{"name"},
+		},
+		"account": {
+			Type: "object",
+			Properties: map[string]*Schema{
+				"id":      {Type: "string"},
+				"name":    {Type: "string", MinLength: &oneLen, MaxLength: &sixtyFour},
+				"tags":    {Type: "array", Items: &Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$"}},
+				"meta":    {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+				"created": {Type: "string", Format: "date-time"},
+				"owner":   {Ref: "#/components/schemas/account"},
+			},
+			Required: []string{"id"},
+		},
+	}
+
+	for name, schema := range expected {
+		if got := doc.Components.Schemas[name]; !reflect.DeepEqual(got, schema) {
+			t.Errorf("%s: expected %+v, got %+v", name, schema, got)
+		}
+	}
+	if len(doc.Components.Schemas) != len(expected) {
+		t.Errorf("expected only the request and response schemas, got %v", doc.Components.Schemas)
+	}
+}
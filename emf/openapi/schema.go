@@ -0,0 +1,361 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// Schema is a JSON schema, as restricted by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty" yaml:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty" yaml:"exclusiveMaximum,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	MinItems             *int64             `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int64             `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	rawType   = reflect.TypeOf(json.RawMessage{})
+	errorType = reflect.TypeOf(errors.SimpleErrorType{})
+)
+
+// generator reflects Go types into schemas, registering named structs as components
+type generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newGenerator(components map[string]*Schema) *generator {
+	return &generator{components: components, names: map[reflect.Type]string{}}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// schema returns the schema of t, a reference for named structs
+func (g *generator) schema(t reflect.Type) *Schema {
+	t = indirect(t)
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		var zero float64
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.register(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// register adds a named struct to the components, prefixing its package name if the name is already taken
+func (g *generator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	var name = t.Name()
+	if t == errorType {
+		name = errorSchemaName
+	}
+	if _, taken := g.components[name]; taken {
+		var pkg = t.PkgPath()
+		name = strings.Title(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+
+	// Register before reflecting the fields, so recursive types refer to themselves
+	g.names[t] = name
+	g.components[name] = &Schema{}
+	*g.components[name] = *g.object(t)
+	return name
+}
+
+// object reflects the json fields of a struct, skipping path, query and header parameters
+func (g *generator) object(t reflect.Type) *Schema {
+	var s = &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, s)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if isParameter(f) {
+			continue
+		}
+
+		var name, skip = jsonName(f)
+		if skip {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened, as encoding/json does
+		if ft := indirect(f.Type); f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.fields(ft, s)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var prop = g.schema(f.Type)
+		if applyValidation(prop, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// parameters reflects the param, query and header tagged fields of a request struct
+func (g *generator) parameters(t reflect.Type) (params []Parameter) {
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if ft := indirect(f.Type); f.Anonymous && ft.Kind() == reflect.Struct && !isParameter(f) {
+			params = append(params, g.parameters(ft)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		var p = Parameter{Schema: g.schema(f.Type)}
+		switch {
+		case f.Tag.Get("param") != "":
+			p.Name, p.In, p.Required = f.Tag.Get("param"), "path", true
+		case f.Tag.Get("query") != "":
+			p.Name, p.In = f.Tag.Get("query"), "query"
+		case f.Tag.Get("header") != "":
+			p.Name, p.In = f.Tag.Get("header"), "header"
+		default:
+			continue
+		}
+
+		if applyValidation(p.Schema, f.Tag.Get("validate")) {
+			p.Required = true
+		}
+		params = append(params, p)
+	}
+	return
+}
+
+// body returns the schema of the json fields of a request, or nil when it only has parameters
+func (g *generator) body(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Struct {
+		return g.schema(t)
+	}
+
+	var hasBody bool
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if _, skip := jsonName(f); !skip && (f.PkgPath == "" || f.Anonymous) && !isParameter(f) {
+			hasBody = true
+		}
+	}
+	if !hasBody {
+		return nil
+	}
+	return g.schema(t)
+}
+
+func isParameter(f reflect.StructField) bool {
+	return f.Tag.Get("param") != "" || f.Tag.Get("query") != "" || f.Tag.Get("header") != ""
+}
+
+func jsonName(f reflect.StructField) (name string, skip bool) {
+	var tag = f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	return strings.Split(tag, ",")[0], false
+}
+
+// applyValidation reflects the go-playground validate tag of a field into its schema,
+// and reports whether the field is required. References cannot carry constraints in OpenAPI 3.0.
+func applyValidation(s *Schema, tag string) (required bool) {
+	var rules = strings.Split(tag, ",")
+
+	for i, rule := range rules {
+		var name, param = rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+
+		if name == "required" {
+			required = true
+			continue
+		}
+		if s.Ref != "" {
+			continue
+		}
+		if name == "dive" {
+			if s.Items != nil {
+				applyValidation(s.Items, strings.Join(rules[i+1:], ","))
+			}
+			return
+		}
+
+		switch name {
+		case "min", "gte":
+			s.setLower(param, false)
+		case "max", "lte":
+			s.setUpper(param, false)
+		case "gt":
+			s.setLower(param, true)
+		case "lt":
+			s.setUpper(param, true)
+		case "len":
+			s.setLower(param, false)
+			s.setUpper(param, false)
+		case "oneof":
+			s.setEnum(strings.Fields(param))
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "datetime":
+			s.Format = "date-time"
+		case "ip", "ipv4":
+			s.Format = "ipv4"
+		case "ipv6":
+			s.Format = "ipv6"
+		case "alpha":
+			s.Pattern = "^[a-zA-Z]+$"
+		case "alphanum":
+			s.Pattern = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			s.Pattern = "^[-+]?[0-9]+(\\.[0-9]+)?$"
+		}
+	}
+	return
+}
+
+// setLower applies a lower bound to the length of strings, the size of arrays and objects, or numbers
+func (s *Schema) setLower(param string, exclusive bool) {
+	var n, err = strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	var size = int64(n)
+	switch s.Type {
+	case "string":
+		if exclusive {
+			size++
+		}
+		s.MinLength = &size
+	case "array", "object":
+		if exclusive {
+			size++
+		}
+		s.MinItems = &size
+	case "integer", "number":
+		s.Minimum, s.ExclusiveMinimum = &n, exclusive
+	}
+}
+
+// setUpper applies an upper bound to the length of strings, the size of arrays and objects, or numbers
+func (s *Schema) setUpper(param string, exclusive bool) {
+	var n, err = strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	var size = int64(n)
+	switch s.Type {
+	case "string":
+		if exclusive {
+			size--
+		}
+		s.MaxLength = &size
+	case "array", "object":
+		if exclusive {
+			size--
+		}
+		s.MaxItems = &size
+	case "integer", "number":
+		s.Maximum, s.ExclusiveMaximum = &n, exclusive
+	}
+}
+
+func (s *Schema) setEnum(values []string) {
+	s.Enum = make([]interface{}, 0, len(values))
+	for _, v := range values {
+		switch s.Type {
+		case "integer":
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				s.Enum = append(s.Enum, i)
+				continue
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				s.Enum = append(s.Enum, f)
+				continue
+			}
+		}
+		s.Enum = append(s.Enum, v)
+	}
+}
@@ -0,0 +1,115 @@
+package emf
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+	"github.com/cambridge-blockchain/emf/emf/apikey"
+	"github.com/cambridge-blockchain/emf/emf/discovery"
+	"github.com/cambridge-blockchain/emf/emf/middleware"
+	"github.com/cambridge-blockchain/emf/emf/revocation"
+	"github.com/cambridge-blockchain/emf/notifications"
+)
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Controller
+// created with NewController.
+type Option func(*options)
+
+type options struct {
+	configFile        string
+	config            configurer.Config
+	build             configurer.BuildConfig
+	notificationCodes []notifications.NotificationType
+	logger            echo.Logger
+	httpClient        *http.Client
+	middlewares       []func(*middleware.AllMiddlewares)
+	revocations       revocation.Store
+	apiKeys           apikey.Store
+	resolver          discovery.Resolver
+}
+
+// WithConfigFile specifies the path of the config file to read. An empty path reads the default config.yaml.
+func WithConfigFile(path string) Option {
+	return func(o *options) { o.configFile = path }
+}
+
+// WithConfig specifies an already loaded config, so no config file is read.
+func WithConfig(conf configurer.Config) Option {
+	return func(o *options) { o.config = conf }
+}
+
+// WithBuildConfig specifies the hard-coded build configuration exposed by the logger and the info endpoint.
+func WithBuildConfig(bc configurer.BuildConfig) Option {
+	return func(o *options) { o.build = bc }
+}
+
+// WithNotificationCodes specifies the notification codes listed by the notifications endpoint.
+func WithNotificationCodes(codes []notifications.NotificationType) Option {
+	return func(o *options) { o.notificationCodes = codes }
+}
+
+// WithLogger specifies the Logger to use instead of the one built from the logging config.
+func WithLogger(l echo.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithHTTPClient specifies the HTTP Client used by the Requester of every request.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.httpClient = client }
+}
+
+// WithMiddlewares registers a callback that can replace or reconfigure the default middlewares
+// before they are wired to the rest of the Controller.
+func WithMiddlewares(f func(*middleware.AllMiddlewares)) Option {
+	return func(o *options) { o.middlewares = append(o.middlewares, f) }
+}
+
+// WithRevocationStore specifies the cache holding the token revocation list, such as a cache.Client.
+// Revoked tokens are then rejected by the Auth middleware, and revocations are accepted on POST /revocations.
+func WithRevocationStore(store revocation.Store) Option {
+	return func(o *options) { o.revocations = store }
+}
+
+// WithAPIKeyStore specifies the Store of hashed API keys, such as an apikey.CacheStore or an apikey.StoreFunc,
+// instead of the api.api_keys.keys list.
+func WithAPIKeyStore(store apikey.Store) Option {
+	return func(o *options) { o.apiKeys = store }
+}
+
+// WithResolver specifies the Resolver of the components called with the Requester, instead of the one
+// selected by the discovery config block.
+func WithResolver(r discovery.Resolver) Option {
+	return func(o *options) { o.resolver = r }
+}
+
+// StartupError collects every problem found while creating a Controller
+type StartupError struct {
+	Problems []error
+}
+
+// Error implements the standard error interface, listing every problem
+func (se *StartupError) Error() string {
+	var problems = make([]string, len(se.Problems))
+	for i, p := range se.Problems {
+		problems[i] = p.Error()
+	}
+	return fmt.Sprintf("failed to start server, %d problem(s) found: %s", len(problems), strings.Join(problems, "; "))
+}
+
+// Unwrap returns the first problem, allowing errors.Is and errors.As comparisons against it
+func (se *StartupError) Unwrap() error {
+	if len(se.Problems) == 0 {
+		return nil
+	}
+	return se.Problems[0]
+}
+
+func (se *StartupError) add(err error) {
+	if err != nil {
+		se.Problems = append(se.Problems, err)
+	}
+}
@@ -0,0 +1,298 @@
+// Package retry decides whether and when a failed Requester call is sent again. Policies are configured per
+// component, with exponential backoff and jitter, Retry-After support and a retry budget bounding the extra
+// load retries put on a struggling component.
+package retry
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cambridge-blockchain/emf/configurer"
+)
+
+// Error classes of failed attempts, listed in Policy.RetryOn
+const (
+	ClassConnection = "connection"
+	ClassTimeout    = "timeout"
+)
+
+var attemptsHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "emf_requester_attempts",
+	Help:    "Number of attempts made per Requester call, by target component",
+	Buckets: []float64{1, 2, 3, 4, 5, 8},
+}, []string{"component"})
+
+var retriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "emf_requester_retries_total",
+	Help: "Number of Requester calls sent again, by target component and reason",
+}, []string{"component", "reason"})
+
+// Policy is the retry configuration of a component
+type Policy struct {
+	// MaxAttempts is the number of attempts including the first one. 0 or 1 disables retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the delay before the first retry, doubled (by Multiplier) on every retry up to MaxBackoff
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	Multiplier     float64       `mapstructure:"multiplier"`
+	// Jitter is the fraction of every delay that is randomized, from 0 to 1
+	Jitter float64 `mapstructure:"jitter"`
+	// MaxRetryAfter caps the delay requested by a Retry-After header. Longer delays are not waited for.
+	MaxRetryAfter time.Duration `mapstructure:"max_retry_after"`
+	// RetryableStatus lists the response status codes that are retried
+	RetryableStatus []int `mapstructure:"retryable_status"`
+	// RetryOn lists the error classes that are retried: connection, timeout
+	RetryOn []string `mapstructure:"retry_on"`
+	// NonIdempotent also retries POST and PATCH requests
+	NonIdempotent bool `mapstructure:"non_idempotent"`
+	// BudgetRatio is the number of retries allowed per request sent, 0.2 allowing one retry for 5 requests
+	BudgetRatio float64 `mapstructure:"budget_ratio"`
+	// BudgetMinPerSecond is the number of retries always allowed per second, whatever the ratio
+	BudgetMinPerSecond float64 `mapstructure:"budget_min_per_second"`
+}
+
+// Policies holds the Retrier of every component, configured by the requester.retry block: the default
+// policy, overridden per component under requester.retry.components.<name>.
+type Policies struct {
+	cfg      configurer.ConfigReader
+	mu       sync.Mutex
+	retriers map[string]*Retrier
+}
+
+// NewPolicies returns the Policies of the requester.retry config block
+func NewPolicies(cfg configurer.ConfigReader) *Policies {
+	return &Policies{cfg: cfg, retriers: map[string]*Retrier{}}
+}
+
+// Validate checks that the default and component policies of the config can be decoded
+func (p *Policies) Validate() error {
+	var _, err = p.policy("")
+	if err != nil {
+		return err
+	}
+
+	var components map[string]interface{}
+	if err = p.cfg.UnmarshalKey("requester.retry.components", &components); err != nil {
+		return err
+	}
+	for name := range components {
+		if _, err = p.policy(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// For returns the Retrier of component, or the default one for an empty or unconfigured component
+func (p *Policies) For(component string) *Retrier {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r, ok := p.retriers[component]; ok {
+		return r
+	}
+
+	// Invalid policies are reported by Validate on startup, a single attempt is made meanwhile
+	var policy, _ = p.policy(component)
+	var r = NewRetrier(component, policy)
+	p.retriers[component] = r
+	return r
+}
+
+func (p *Policies) policy(component string) (policy Policy, err error) {
+	if err = p.cfg.UnmarshalKey("requester.retry", &policy); err != nil || component == "" {
+		return
+	}
+	err = p.cfg.UnmarshalKey("requester.retry.components."+component, &policy)
+	return
+}
+
+// Retrier applies a Policy to the calls to a component, sharing its retry budget
+type Retrier struct {
+	component string
+	policy    Policy
+	status    map[int]bool
+	classes   map[string]bool
+
+	mu       sync.Mutex
+	tokens   float64
+	refilled time.Time
+}
+
+// NewRetrier returns a Retrier applying policy to the calls to component
+func NewRetrier(component string, policy Policy) *Retrier {
+	var r = &Retrier{
+		component: component,
+		policy:    policy,
+		status:    map[int]bool{},
+		classes:   map[string]bool{},
+		refilled:  time.Now(),
+	}
+	for _, s := range policy.RetryableStatus {
+		r.status[s] = true
+	}
+	for _, c := range policy.RetryOn {
+		r.classes[c] = true
+	}
+	if r.policy.Multiplier < 1 {
+		r.policy.Multiplier = 2
+	}
+	r.tokens = r.capacity()
+	return r
+}
+
+// Begin records a new call against the retry budget
+func (r *Retrier) Begin() {
+	if r == nil || r.policy.BudgetRatio <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = math.Min(r.tokens+r.policy.BudgetRatio, r.capacity())
+}
+
+// Retry decides whether the attempt-th attempt of req, which returned res or err, is sent again and after
+// which delay. reason names the status code or error class that was retried.
+func (r *Retrier) Retry(
+	req *http.Request, res *http.Response, err error, attempt int,
+) (delay time.Duration, reason string, ok bool) {
+	if r == nil || attempt >= r.policy.MaxAttempts {
+		return 0, "", false
+	}
+	if !r.policy.NonIdempotent && !Idempotent(req.Method) {
+		return 0, "", false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body was consumed and cannot be sent again
+		return 0, "", false
+	}
+
+	switch {
+	case err != nil:
+		if reason = Classify(err); !r.classes[reason] {
+			return 0, "", false
+		}
+	case r.status[res.StatusCode]:
+		reason = strconv.Itoa(res.StatusCode)
+	default:
+		return 0, "", false
+	}
+
+	delay = r.Backoff(attempt)
+	if res != nil {
+		if after, found := RetryAfter(res.Header.Get("Retry-After"), time.Now()); found {
+			if r.policy.MaxRetryAfter > 0 && after > r.policy.MaxRetryAfter {
+				return 0, "", false
+			}
+			delay = after
+		}
+	}
+
+	if !r.withdraw() {
+		return 0, "", false
+	}
+	retriesCounter.WithLabelValues(r.component, reason).Inc()
+	return delay, reason, true
+}
+
+// Done records the number of attempts made for a call
+func (r *Retrier) Done(attempts int) {
+	var component = ""
+	if r != nil {
+		component = r.component
+	}
+	attemptsHistogram.WithLabelValues(component).Observe(float64(attempts))
+}
+
+// Backoff returns the delay before the attempt+1-th attempt
+func (r *Retrier) Backoff(attempt int) time.Duration {
+	var d = float64(r.policy.InitialBackoff) * math.Pow(r.policy.Multiplier, float64(attempt-1))
+	if r.policy.MaxBackoff > 0 && d > float64(r.policy.MaxBackoff) {
+		d = float64(r.policy.MaxBackoff)
+	}
+	if j := math.Min(math.Max(r.policy.Jitter, 0), 1); j > 0 {
+		d = d*(1-j) + d*j*rand.Float64() //nolint:gosec
+	}
+	return time.Duration(d)
+}
+
+// capacity bounds the retries saved up while the component is healthy
+func (r *Retrier) capacity() float64 {
+	return math.Max(10, r.policy.BudgetMinPerSecond*10)
+}
+
+// withdraw takes a retry from the budget, refilled with BudgetMinPerSecond retries per second
+func (r *Retrier) withdraw() bool {
+	if r.policy.BudgetRatio <= 0 && r.policy.BudgetMinPerSecond <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var now = time.Now()
+	r.tokens = math.Min(r.tokens+now.Sub(r.refilled).Seconds()*r.policy.BudgetMinPerSecond, r.capacity())
+	r.refilled = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// Idempotent reports whether requests with method can be sent again without side effects
+func Idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify returns the class of an error returned by an HTTP client: timeout, connection, or ""
+func Classify(err error) string {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return ClassTimeout
+	}
+
+	var oe *net.OpError
+	if errors.As(err, &oe) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return ClassConnection
+	}
+	return ""
+}
+
+// RetryAfter parses a Retry-After header holding a number of seconds or an HTTP date
+func RetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestBackoff(t *testing.T) {
+	var cases = []struct {
+		name     string
+		policy   Policy
+		attempt  int
+		expected time.Duration
+	}{
+		{"first retry", Policy{InitialBackoff: 100 * time.Millisecond}, 1, 100 * time.Millisecond},
+		{"doubled", Policy{InitialBackoff: 100 * time.Millisecond}, 3, 400 * time.Millisecond},
+		{"multiplier", Policy{InitialBackoff: 100 * time.Millisecond, Multiplier: 3}, 3, 900 * time.Millisecond},
+		{"capped", Policy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, 10, 5 * time.Second},
+		{"multiplier below 1", Policy{InitialBackoff: time.Second, Multiplier: 0.5}, 2, 2 * time.Second},
+	}
+
+	for _, c := range cases {
+		if got := NewRetrier("ledger", c.policy).Backoff(c.attempt); got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	var r = NewRetrier("ledger", Policy{InitialBackoff: time.Second, Jitter: 0.5})
+	for i := 0; i < 100; i++ {
+		if d := r.Backoff(1); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("expected a delay between 500ms and 1s, got %s", d)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var now = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var cases = []struct {
+		value    string
+		expected time.Duration
+		found    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, c := range cases {
+		var got, found = RetryAfter(c.value, now)
+		if got != c.expected || found != c.found {
+			t.Errorf("%q: expected %s %v, got %s %v", c.value, c.expected, c.found, got, found)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	var cases = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+		http.MethodPost:    false,
+		http.MethodPatch:   false,
+	}
+	for method, expected := range cases {
+		if got := Idempotent(method); got != expected {
+			t.Errorf("%s: expected %v, got %v", method, expected, got)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	var refused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	var cases = []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"timeout", timeoutError{}, ClassTimeout},
+		{"wrapped timeout", &url.Error{Op: "Get", URL: "http://ledger", Err: timeoutError{}}, ClassTimeout},
+		{"connection refused", refused, ClassConnection},
+		{"wrapped connection refused", &url.Error{Op: "Get", URL: "http://ledger", Err: refused}, ClassConnection},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), ClassConnection},
+		{"unexpected EOF", io.ErrUnexpectedEOF, ClassConnection},
+		{"other", errors.New("invalid URL"), ""},
+	}
+
+	for _, c := range cases {
+		if got := Classify(c.err); got != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var policy = Policy{
+		MaxAttempts:     3,
+		RetryableStatus: []int{http.StatusServiceUnavailable},
+		RetryOn:         []string{ClassConnection},
+		MaxRetryAfter:   time.Minute,
+	}
+	var response = func(status int, retryAfter string) *http.Response {
+		var res = &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			res.Header.Set("Retry-After", retryAfter)
+		}
+		return res
+	}
+	var unreplayable = func() *http.Request {
+		var req, _ = http.NewRequest(http.MethodPut, "http://ledger/accounts", strings.NewReader("{}"))
+		req.GetBody = nil
+		return req
+	}
+
+	var cases = []struct {
+		name    string
+		policy  Policy
+		req     *http.Request
+		res     *http.Response
+		err     error
+		attempt int
+		reason  string
+		delay   time.Duration
+		ok      bool
+	}{
+		{"retryable status", policy, get(), response(503, ""), nil, 1, "503", 0, true},
+		{"other status", policy, get(), response(500, ""), nil, 1, "", 0, false},
+		{"last attempt", policy, get(), response(503, ""), nil, 3, "", 0, false},
+		{"retryable error", policy, get(), nil, io.EOF, 1, ClassConnection, 0, true},
+		{"other error class", policy, get(), nil, timeoutError{}, 1, "", 0, false},
+		{"Retry-After", policy, get(), response(503, "2"), nil, 1, "503", 2 * time.Second, true},
+		{"Retry-After too long", policy, get(), response(503, "3600"), nil, 1, "", 0, false},
+		{"POST", policy, request(http.MethodPost), response(503, ""), nil, 1, "", 0, false},
+		{"POST allowed", Policy{MaxAttempts: 2, RetryableStatus: []int{503}, NonIdempotent: true},
+			request(http.MethodPost), response(503, ""), nil, 1, "503", 0, true},
+		{"consumed body", policy, unreplayable(), response(503, ""), nil, 1, "", 0, false},
+		{"retries disabled", Policy{RetryableStatus: []int{503}}, get(), response(503, ""), nil, 1, "", 0, false},
+	}
+
+	for _, c := range cases {
+		var delay, reason, ok = NewRetrier("ledger", c.policy).Retry(c.req, c.res, c.err, c.attempt)
+		if ok != c.ok || reason != c.reason || delay != c.delay {
+			t.Errorf("%s: expected %v %q %s, got %v %q %s", c.name, c.ok, c.reason, c.delay, ok, reason, delay)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var r = NewRetrier("ledger", Policy{
+		MaxAttempts:     2,
+		RetryableStatus: []int{http.StatusServiceUnavailable},
+		BudgetRatio:     0.5,
+	})
+	var res = &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	// The budget starts full with 10 retries
+	for i := 0; i < 10; i++ {
+		if _, _, ok := r.Retry(get(), res, nil, 1); !ok {
+			t.Fatalf("expected retry %d to be allowed", i+1)
+		}
+	}
+	if _, _, ok := r.Retry(get(), res, nil, 1); ok {
+		t.Fatal("expected the retry to be denied once the budget is spent")
+	}
+
+	// Every request sent adds BudgetRatio retries
+	r.Begin()
+	r.Begin()
+	if _, _, ok := r.Retry(get(), res, nil, 1); !ok {
+		t.Error("expected a retry to be allowed after two requests")
+	}
+	if _, _, ok := r.Retry(get(), res, nil, 1); ok {
+		t.Error("expected a single retry to be allowed after two requests")
+	}
+}
+
+func TestPoliciesFor(t *testing.T) {
+	var v = viper.New()
+	v.Set("requester.retry", map[string]interface{}{
+		"max_attempts":    3,
+		"initial_backoff": "100ms",
+		"components": map[string]interface{}{
+			"ledger": map[string]interface{}{"max_attempts": 5},
+		},
+	})
+	var p = NewPolicies(v)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		component string
+		attempts  int
+	}{
+		{"", 3},
+		{"kyc", 3},
+		{"ledger", 5},
+	}
+	for _, c := range cases {
+		var r = p.For(c.component)
+		if r.policy.MaxAttempts != c.attempts || r.policy.InitialBackoff != 100*time.Millisecond {
+			t.Errorf("%q: expected %d attempts after 100ms, got %+v", c.component, c.attempts, r.policy)
+		}
+		if p.For(c.component) != r {
+			t.Errorf("%q: expected the Retrier to be cached", c.component)
+		}
+	}
+}
+
+func TestPoliciesValidate(t *testing.T) {
+	var v = viper.New()
+	v.Set("requester.retry.max_attempts", "many")
+	if err := NewPolicies(v).Validate(); err == nil {
+		t.Error("expected an invalid policy to be reported")
+	}
+}
+
+func get() *http.Request {
+	return request(http.MethodGet)
+}
+
+func request(method string) *http.Request {
+	var req, _ = http.NewRequest(method, "http://ledger/accounts", nil)
+	return req
+}
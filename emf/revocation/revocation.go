@@ -0,0 +1,264 @@
+// Package revocation keeps a list of revoked tokens in a cache, so they can be rejected locally before
+// any request is sent to the auth component. Tokens are identified by their jti claim, or else by the
+// hash of the token, and each entry expires when the token it revokes does.
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	defaultKeyPrefix = "emf:revoked:"
+	defaultTTL       = 24 * time.Hour
+	defaultSweep     = time.Minute
+)
+
+// Store is the subset of cache.Client used by a List, so any cache.Client can hold the revocations
+type Store interface {
+	Set(path string, data []byte) error
+	Get(path string) ([]byte, bool, error)
+	Delete(path string) error
+}
+
+// ExpiringStore is implemented by stores able to expire an entry by themselves, such as a Redis client.
+// Entries of other stores are deleted when they are read after the token expired, or by the sweep of the
+// List that wrote them.
+type ExpiringStore interface {
+	SetWithTTL(path string, data []byte, ttl time.Duration) error
+}
+
+// List is a token revocation list held in a Store
+type List struct {
+	store  Store
+	prefix string
+	ttl    time.Duration
+	sweep  time.Duration
+
+	mu       sync.Mutex
+	expiries map[string]time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type entry struct {
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a List.
+type Option func(*List)
+
+// WithKeyPrefix sets the prefix of the cache keys of the entries, "emf:revoked:" by default
+func WithKeyPrefix(prefix string) Option {
+	return func(l *List) { l.prefix = prefix }
+}
+
+// WithDefaultTTL sets how long a revocation is kept when the expiry of the token is unknown, 24 hours by default
+func WithDefaultTTL(d time.Duration) Option {
+	return func(l *List) {
+		if d > 0 {
+			l.ttl = d
+		}
+	}
+}
+
+// WithSweepInterval sets how often the entries written to a store that is not an ExpiringStore are deleted
+// once their token expired, every minute by default
+func WithSweepInterval(d time.Duration) Option {
+	return func(l *List) {
+		if d > 0 {
+			l.sweep = d
+		}
+	}
+}
+
+// New is a variadic constructor for a List held in store
+func New(store Store, opts ...Option) *List {
+	var l = &List{
+		store:    store,
+		prefix:   defaultKeyPrefix,
+		ttl:      defaultTTL,
+		sweep:    defaultSweep,
+		expiries: map[string]time.Time{},
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Revoke revokes the token with the jti or hash id until exp. A zero exp keeps the entry for the default TTL.
+// Revoking an already expired token is a no-op.
+func (l *List) Revoke(id string, exp time.Time) error {
+	if id == "" {
+		return fmt.Errorf("missing token id")
+	}
+	if exp.IsZero() {
+		exp = time.Now().Add(l.ttl)
+	}
+
+	var ttl = time.Until(exp)
+	if ttl <= 0 {
+		return nil
+	}
+
+	var data, err = json.Marshal(entry{ExpiresAt: exp.Unix()})
+	if err != nil {
+		return err
+	}
+
+	if es, ok := l.store.(ExpiringStore); ok {
+		err = es.SetWithTTL(l.prefix+id, data, ttl)
+	} else if err = l.store.Set(l.prefix+id, data); err == nil {
+		l.mu.Lock()
+		l.expiries[id] = exp
+		l.mu.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store the revocation of token '%s': %w", id, err)
+	}
+	return nil
+}
+
+// RevokeToken revokes a raw token until its exp claim, identifying it as ID does
+func (l *List) RevokeToken(raw string) error {
+	return l.Revoke(ID(raw), Expiry(raw))
+}
+
+// IsRevoked reports whether the token with the jti or hash id is revoked
+func (l *List) IsRevoked(id string) (bool, error) {
+	var data, found, err = l.store.Get(l.prefix + id)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the revocation of token '%s': %w", id, err)
+	}
+	if !found {
+		return false, nil
+	}
+
+	var e entry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return false, fmt.Errorf("invalid revocation of token '%s': %w", id, err)
+	}
+
+	if time.Now().Unix() >= e.ExpiresAt {
+		// The token can no longer be used anyway, the entry is only removed to free the cache
+		if l.store.Delete(l.prefix+id) == nil {
+			l.mu.Lock()
+			delete(l.expiries, id)
+			l.mu.Unlock()
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// Sweep deletes the entries this List wrote to a store that is not an ExpiringStore once their token expired.
+// Entries that fail to be deleted are retried on the next sweep.
+func (l *List) Sweep() error {
+	var now = time.Now()
+	var expired []string
+
+	l.mu.Lock()
+	for id, exp := range l.expiries {
+		if !now.Before(exp) {
+			expired = append(expired, id)
+		}
+	}
+	l.mu.Unlock()
+
+	var failed error
+	for _, id := range expired {
+		if err := l.store.Delete(l.prefix + id); err != nil {
+			failed = fmt.Errorf("failed to delete the revocation of token '%s': %w", id, err)
+			continue
+		}
+		l.mu.Lock()
+		delete(l.expiries, id)
+		l.mu.Unlock()
+	}
+	return failed
+}
+
+// Start sweeps the expired entries every sweep interval until Stop is called.
+// Nothing is started for an ExpiringStore.
+func (l *List) Start(context.Context) error {
+	if _, ok := l.store.(ExpiringStore); ok {
+		return nil
+	}
+
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(l.done)
+		var ticker = time.NewTicker(l.sweep)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				// A failed delete is retried on the next tick
+				_ = l.Sweep()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the periodic sweep
+func (l *List) Stop(ctx context.Context) error {
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	l.stop = nil
+	return nil
+}
+
+// IsTokenRevoked reports whether a raw token is revoked, identifying it as ID does
+func (l *List) IsTokenRevoked(raw string) (bool, error) {
+	return l.IsRevoked(ID(raw))
+}
+
+// ID returns the jti claim of a JWT, or else the hex encoded SHA-256 hash of the token.
+// The token is not verified.
+func ID(raw string) string {
+	var claims = jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(raw, claims); err == nil {
+		if jti, ok := claims["jti"].(string); ok && jti != "" {
+			return jti
+		}
+	}
+	return Hash(raw)
+}
+
+// Hash returns the hex encoded SHA-256 hash of a token, its id when it has no jti claim
+func Hash(raw string) string {
+	var sum = sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expiry returns the exp claim of a JWT, or the zero time. The token is not verified.
+func Expiry(raw string) time.Time {
+	var claims = jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(raw, claims); err == nil {
+		if exp, ok := claims["exp"].(float64); ok {
+			return time.Unix(int64(exp), 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,234 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// memory is a Store that never expires its entries
+type memory struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	broken  bool
+}
+
+func (m *memory) Set(path string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.broken {
+		return errors.New("connection refused")
+	}
+	if m.entries == nil {
+		m.entries = map[string][]byte{}
+	}
+	m.entries[path] = data
+	return nil
+}
+
+func (m *memory) Get(path string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.broken {
+		return nil, false, errors.New("connection refused")
+	}
+	var data, ok = m.entries[path]
+	return data, ok, nil
+}
+
+func (m *memory) Delete(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, path)
+	return nil
+}
+
+func (m *memory) has(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var _, ok = m.entries[path]
+	return ok
+}
+
+// expiring is a Store recording the TTL of each entry
+type expiring struct {
+	memory
+	ttls map[string]time.Duration
+}
+
+func (e *expiring) SetWithTTL(path string, data []byte, ttl time.Duration) error {
+	e.ttls[path] = ttl
+	return e.Set(path, data)
+}
+
+func TestRevoke(t *testing.T) {
+	var store = &memory{}
+	var l = New(store, WithKeyPrefix("revoked:"), WithDefaultTTL(time.Hour))
+
+	var cases = []struct {
+		name    string
+		id      string
+		exp     time.Time
+		ok      bool
+		revoked bool
+	}{
+		{"until exp", "t-1", time.Now().Add(time.Minute), true, true},
+		{"default TTL", "t-2", time.Time{}, true, true},
+		{"expired token", "t-3", time.Now().Add(-time.Minute), true, false},
+		{"missing id", "", time.Now().Add(time.Minute), false, false},
+	}
+
+	for _, c := range cases {
+		if err := l.Revoke(c.id, c.exp); c.ok != (err == nil) {
+			t.Errorf("%s: expected success: %v, got %v", c.name, c.ok, err)
+		}
+		if c.id == "" {
+			continue
+		}
+		if revoked, err := l.IsRevoked(c.id); err != nil || revoked != c.revoked {
+			t.Errorf("%s: expected revoked: %v, got %v %v", c.name, c.revoked, revoked, err)
+		}
+		if store.has("revoked:"+c.id) != c.revoked {
+			t.Errorf("%s: expected the entry to be stored: %v", c.name, c.revoked)
+		}
+	}
+
+	if revoked, err := l.IsRevoked("unknown"); err != nil || revoked {
+		t.Errorf("expected an unknown token not to be revoked, got %v %v", revoked, err)
+	}
+
+	store.broken = true
+	if err := l.Revoke("t-4", time.Time{}); err == nil {
+		t.Error("expected the store error to be returned by Revoke")
+	}
+	if _, err := l.IsRevoked("t-1"); err == nil {
+		t.Error("expected the store error to be returned by IsRevoked")
+	}
+}
+
+func TestIsRevokedExpiry(t *testing.T) {
+	var store = &memory{}
+	var l = New(store)
+
+	if err := store.Set(defaultKeyPrefix+"expired", []byte(`{"exp":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(defaultKeyPrefix+"invalid", []byte(`{"exp":`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired entry is deleted when it is read
+	if revoked, err := l.IsRevoked("expired"); err != nil || revoked {
+		t.Errorf("expected an expired entry not to be revoked, got %v %v", revoked, err)
+	}
+	if store.has(defaultKeyPrefix + "expired") {
+		t.Error("expected the expired entry to be deleted")
+	}
+
+	if _, err := l.IsRevoked("invalid"); err == nil {
+		t.Error("expected an invalid entry to be reported")
+	}
+}
+
+func TestExpiringStore(t *testing.T) {
+	var store = &expiring{ttls: map[string]time.Duration{}}
+	var l = New(store)
+
+	if err := l.Revoke("t-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := store.ttls[defaultKeyPrefix+"t-1"]; ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the entry to expire with the token, got a TTL of %s", ttl)
+	}
+	if err := l.Revoke("t-2", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := store.ttls[defaultKeyPrefix+"t-2"]; ttl <= defaultTTL-time.Minute || ttl > defaultTTL {
+		t.Errorf("expected the entry to expire after the default TTL, got %s", ttl)
+	}
+
+	// The store expires its entries, so there is nothing to sweep
+	if len(l.expiries) != 0 {
+		t.Errorf("expected no entry to be swept, got %v", l.expiries)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	var store = &memory{}
+	var l = New(store, WithSweepInterval(10*time.Millisecond))
+
+	if err := l.Revoke("short", time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Revoke("long", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Stop(context.Background())
+
+	// The entry is deleted without being read once its token expired
+	var deadline = time.Now().Add(3 * time.Second)
+	for store.has(defaultKeyPrefix+"short") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if store.has(defaultKeyPrefix + "short") {
+		t.Error("expected the expired entry to be swept")
+	}
+	if !store.has(defaultKeyPrefix + "long") {
+		t.Error("expected the entry of a valid token to be kept")
+	}
+
+	if err := l.Stop(context.Background()); err != nil {
+		t.Errorf("expected the sweep to stop, got %v", err)
+	}
+}
+
+func TestTokenID(t *testing.T) {
+	var sign = func(claims jwt.MapClaims) string {
+		var raw, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	var withJTI = sign(jwt.MapClaims{"jti": "t-1", "exp": float64(2000000000)})
+	var withoutJTI = sign(jwt.MapClaims{"sub": "alice"})
+
+	var cases = []struct {
+		name string
+		raw  string
+		id   string
+		exp  time.Time
+	}{
+		{"jti and exp", withJTI, "t-1", time.Unix(2000000000, 0)},
+		{"no jti", withoutJTI, Hash(withoutJTI), time.Time{}},
+		{"opaque token", "opaque", Hash("opaque"), time.Time{}},
+	}
+
+	for _, c := range cases {
+		if got := ID(c.raw); got != c.id {
+			t.Errorf("%s: expected the id %s, got %s", c.name, c.id, got)
+		}
+		if got := Expiry(c.raw); !got.Equal(c.exp) {
+			t.Errorf("%s: expected the expiry %s, got %s", c.name, c.exp, got)
+		}
+	}
+
+	var l = New(&memory{})
+	if err := l.RevokeToken(withJTI); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := l.IsTokenRevoked(withJTI); err != nil || !revoked {
+		t.Errorf("expected the token to be revoked, got %v %v", revoked, err)
+	}
+	if revoked, err := l.IsTokenRevoked(withoutJTI); err != nil || revoked {
+		t.Errorf("expected another token not to be revoked, got %v %v", revoked, err)
+	}
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Headers sent on the responses of deprecated routes
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+	HeaderLink        = "Link"
+)
+
+// deprecatedRequests is exposed on /metrics when monitoring.prometheus is enabled
+var deprecatedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "emf",
+	Subsystem: "router",
+	Name:      "deprecated_requests_total",
+	Help:      "Number of requests served by deprecated routes, by route and caller.",
+}, []string{"method", "route", "caller"})
+
+// Deprecation describes when a route or API version was deprecated and when it will be removed.
+// Link points to the documentation of the migration. Caller names the caller counted in the
+// emf_router_deprecated_requests_total metric, DefaultCaller by default.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	Link   string
+	Caller func(c echo.Context) string
+}
+
+type deprecator struct {
+	dep     Deprecation
+	headers map[string]string
+}
+
+// Deprecate marks the routes or version groups it is passed to as deprecated. Their responses carry
+// the Deprecation header, as an RFC 9745 date or "true" when Since is not set, the RFC 8594 Sunset header
+// and a deprecation Link, and every request is counted by caller.
+func Deprecate(d Deprecation) Middleware {
+	if d.Caller == nil {
+		d.Caller = DefaultCaller
+	}
+
+	var headers = map[string]string{HeaderDeprecation: "true"}
+	if !d.Since.IsZero() {
+		headers[HeaderDeprecation] = fmt.Sprintf("@%d", d.Since.Unix())
+	}
+	if !d.Sunset.IsZero() {
+		headers[HeaderSunset] = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	if d.Link != "" {
+		headers[HeaderLink] = fmt.Sprintf(`<%s>; rel="deprecation"`, d.Link)
+	}
+
+	return deprecator{dep: d, headers: headers}
+}
+
+// Name identifies the middleware in the route registry
+func (d deprecator) Name() string {
+	return "router.Deprecate"
+}
+
+func (d deprecator) metadata() Metadata {
+	return Metadata{Deprecated: true}
+}
+
+// Wrapper sets the deprecation headers and counts the request
+func (d deprecator) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var h = c.Response().Header()
+		for k, v := range d.headers {
+			if k == HeaderLink {
+				h.Add(k, v)
+				continue
+			}
+			h.Set(k, v)
+		}
+
+		deprecatedRequests.WithLabelValues(c.Request().Method, c.Path(), d.dep.Caller(c)).Inc()
+		return next(c)
+	}
+}
+
+// DefaultCaller identifies a caller by the common name of its verified client certificate,
+// or else by the product of its User-Agent, such as "okhttp" for "okhttp/4.9.0"
+func DefaultCaller(c echo.Context) string {
+	var req = c.Request()
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		return req.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+
+	if ua := strings.Fields(req.UserAgent()); len(ua) > 0 {
+		return strings.SplitN(ua[0], "/", 2)[0]
+	}
+	return "unknown"
+}
@@ -32,8 +32,9 @@ type EchoRouter interface {
 
 // Router is a generic router type that is used to register and dispatch routes
 type Router struct {
-	echo   EchoRouter
-	Logger echo.Logger
+	echo     EchoRouter
+	registry *Registry
+	Logger   echo.Logger
 }
 
 // Option provides the client a callback that is used to dynamically specify attributes for a
@@ -57,8 +58,9 @@ func WithRouter(router *echo.Echo) Option {
 func New(opts ...Option) *Router {
 	e := echo.New()
 	r := &Router{
-		echo:   e,
-		Logger: e.Logger,
+		echo:     e,
+		registry: newRegistry(),
+		Logger:   e.Logger,
 	}
 
 	for _, opt := range opts {
@@ -75,6 +77,11 @@ func (r *Router) NewGroup(prefix string, m ...Middleware) (g *Group) {
 	return
 }
 
+// Registry returns the record of every route registered through the groups of the Router
+func (r *Router) Registry() *Registry {
+	return r.registry
+}
+
 // UseGlobalMiddlewares registers global middlewares for all endpoints
 func (r *Router) UseGlobalMiddlewares(m ...Middleware) {
 	r.Use(MiddlewaresWrapper(m)...)
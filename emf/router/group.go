@@ -137,19 +137,26 @@ func (g *Group) Match(methods []string, path string, h HandlerFunc, m ...Middlew
 
 // Group creates a new sub-group with prefix and optional sub-group-level middleware.
 func (g *Group) Group(prefix string, middleware ...Middleware) *Group {
-	m := make([]Middleware, 0, len(g.middleware)+len(middleware))
-	m = append(m, g.middleware...)
-	m = append(m, middleware...)
-	return g.router.NewGroup(g.prefix+prefix, m...)
+	return g.router.NewGroup(g.prefix+prefix, g.combine(middleware)...)
 }
 
 // Add implements `Echo#Add()` for sub-routes within the Group.
 func (g *Group) Add(method, path string, h HandlerFunc, middleware ...Middleware) *echo.Route {
+	return g.add(method, path, funcName(h), h, g.combine(middleware))
+}
+
+// combine appends middleware to the group middlewares
+func (g *Group) combine(middleware []Middleware) []Middleware {
 	// Combine into a new slice to avoid accidentally passing the same slice for
 	// multiple routes, which would lead to later add() calls overwriting the
 	// middleware from earlier calls.
 	m := make([]Middleware, 0, len(g.middleware)+len(middleware))
 	m = append(m, g.middleware...)
 	m = append(m, middleware...)
+	return m
+}
+
+func (g *Group) add(method, path, name string, h HandlerFunc, m []Middleware) *echo.Route {
+	g.router.registry.add(method, g.prefix+path, name, m)
 	return g.router.echo.Add(method, g.prefix+path, HandlerWrapper(h), MiddlewaresWrapper(m)...)
 }
@@ -0,0 +1,223 @@
+package router
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Metadata is optional documentation attached to routes with Describe.
+// Auth names the authentication a route requires, such as "jwt" or "none".
+// Request and Response are values of the types bound from the request and returned with the Status code,
+// which defaults to 200, and Errors lists the EMFError codes the route may return.
+// Deprecated is set by the Deprecate middleware.
+type Metadata struct {
+	Summary     string      `json:"summary,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Auth        string      `json:"auth,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Request     interface{} `json:"-"`
+	Response    interface{} `json:"-"`
+	Status      int         `json:"status,omitempty"`
+	Errors      []string    `json:"errors,omitempty"`
+	Deprecated  bool        `json:"deprecated,omitempty"`
+}
+
+// merge overlays m on top of base, appending tags and errors and overriding the other fields when they are set
+func (base Metadata) merge(m Metadata) Metadata {
+	if m.Summary != "" {
+		base.Summary = m.Summary
+	}
+	if m.Description != "" {
+		base.Description = m.Description
+	}
+	if m.Auth != "" {
+		base.Auth = m.Auth
+	}
+	if m.Request != nil {
+		base.Request = m.Request
+	}
+	if m.Response != nil {
+		base.Response = m.Response
+	}
+	if m.Status != 0 {
+		base.Status = m.Status
+	}
+	if m.Deprecated {
+		base.Deprecated = true
+	}
+	base.Tags = append(append([]string(nil), base.Tags...), m.Tags...)
+	base.Errors = append(append([]string(nil), base.Errors...), m.Errors...)
+	return base
+}
+
+// describing is implemented by Middlewares that attach Metadata to the routes they are passed to
+type describing interface {
+	metadata() Metadata
+}
+
+// describer is a pass-through Middleware that only carries Metadata for the registry
+type describer struct {
+	meta Metadata
+}
+
+func (d describer) metadata() Metadata {
+	return d.meta
+}
+
+// Wrapper does not add any logic to the route
+func (d describer) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
+
+// Describe attaches Metadata to the routes it is passed to. Passed to NewGroup or Group, it applies to every
+// route of the group, with the tags of nested groups and routes appended and their other fields taking precedence.
+func Describe(m Metadata) Middleware {
+	return describer{meta: m}
+}
+
+// describe merges the Metadata attached by mids, in order
+func describe(mids []Middleware) (meta Metadata) {
+	for _, m := range mids {
+		if d, ok := m.(describing); ok {
+			meta = meta.merge(d.metadata())
+		}
+	}
+	return
+}
+
+// Route describes a registered endpoint
+type Route struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
+	Metadata
+}
+
+// Registry records every route registered through a Router's groups
+type Registry struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+func newRegistry() *Registry {
+	return &Registry{routes: map[string]Route{}}
+}
+
+// Routes returns every registered route, sorted by path and method
+func (reg *Registry) Routes() (routes []Route) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	routes = make([]Route, 0, len(reg.routes))
+	for _, r := range reg.routes {
+		routes = append(routes, r)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return
+}
+
+// Lookup returns the route registered for a method and echo path, such as "/users/:id"
+func (reg *Registry) Lookup(method, path string) (r Route, ok bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	r, ok = reg.routes[method+" "+path]
+	return
+}
+
+// Match returns the route that serves a method and request path, such as "/users/42".
+// Static segments take precedence over parameters, and parameters over wildcards, as in echo.
+func (reg *Registry) Match(method, path string) (r Route, ok bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	if r, ok = reg.routes[method+" "+path]; ok {
+		return
+	}
+
+	var best = -1
+	for _, route := range reg.routes {
+		if route.Method != method {
+			continue
+		}
+		if score, matched := matchPath(route.Path, path); matched && score > best {
+			r, ok, best = route, true, score
+		}
+	}
+	return
+}
+
+// matchPath matches a request path against an echo path, scoring more specific matches higher
+func matchPath(pattern, path string) (score int, ok bool) {
+	var segs, parts = strings.Split(pattern, "/"), strings.Split(path, "/")
+
+	for i, seg := range segs {
+		switch {
+		case seg == "*":
+			return score, i == len(segs)-1
+		case i >= len(parts):
+			return 0, false
+		case strings.HasPrefix(seg, ":"):
+			score++
+		case seg == parts[i]:
+			score += 2
+		default:
+			return 0, false
+		}
+	}
+	return score, len(segs) == len(parts)
+}
+
+// add records a route, splitting the Describe metadata from the actual middlewares.
+// Registering the same method and path again replaces the previous route, as echo does.
+func (reg *Registry) add(method, path, handler string, mids []Middleware) Route {
+	var r = Route{
+		Method:      method,
+		Path:        path,
+		Handler:     handler,
+		Middlewares: []string{},
+		Metadata:    describe(mids),
+	}
+
+	for _, m := range mids {
+		if _, ok := m.(describer); !ok {
+			r.Middlewares = append(r.Middlewares, middlewareName(m))
+		}
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.routes[method+" "+path] = r
+	return r
+}
+
+// middlewareName names a Middleware by its Name method if it has one, otherwise by its type
+func middlewareName(m Middleware) string {
+	if named, ok := m.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", m), "*")
+}
+
+func funcName(f interface{}) string {
+	var v = reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
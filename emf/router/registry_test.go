@@ -0,0 +1,144 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// newTestRouter returns a Router whose handlers are called with an EMFContext, as the Context middleware does
+func newTestRouter() (*Router, *echo.Echo) {
+	var e = echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return next(context.NewEMFContext(c, viper.New()))
+		}
+	})
+	return New(WithRouter(e)), e
+}
+
+// named is a pass-through Middleware named in the registry by its Name method
+type named string
+
+func (n named) Name() string {
+	return string(n)
+}
+
+func (n named) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
+
+// passthrough is a pass-through Middleware named in the registry by its type
+type passthrough struct{}
+
+func (passthrough) Wrapper(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
+
+func listAccounts(ctx context.EMFContext) error {
+	return ctx.NoContent(http.StatusOK)
+}
+
+func getAccount(ctx context.EMFContext) error {
+	return ctx.String(http.StatusOK, ctx.Param("id"))
+}
+
+func TestRegistry(t *testing.T) {
+	var r, _ = newTestRouter()
+
+	var accounts = r.NewGroup("/accounts", named("auth"), Describe(Metadata{Auth: "jwt", Tags: []string{"accounts"}}))
+	accounts.GET("", listAccounts, Describe(Metadata{Summary: "List the accounts"}))
+	accounts.GET("/:id", getAccount, passthrough{})
+	accounts.GET("/me", getAccount)
+	accounts.Group("/:id/transfers", Describe(Metadata{Tags: []string{"transfers"}, Auth: "mtls"})).
+		POST("", listAccounts, Describe(Metadata{Status: http.StatusCreated, Errors: []string{"ledger.404.Account"}}))
+	r.NewGroup("/files").GET("/*", listAccounts)
+
+	var routes = r.Registry().Routes()
+	var expected = []struct {
+		method, path, handler string
+		middlewares           []string
+		meta                  Metadata
+	}{
+		{http.MethodGet, "/accounts", "router.listAccounts", []string{"auth"},
+			Metadata{Auth: "jwt", Tags: []string{"accounts"}, Summary: "List the accounts"}},
+		{http.MethodGet, "/accounts/:id", "router.getAccount", []string{"auth", "router.passthrough"},
+			Metadata{Auth: "jwt", Tags: []string{"accounts"}}},
+		{http.MethodPost, "/accounts/:id/transfers", "router.listAccounts", []string{"auth"},
+			Metadata{Auth: "mtls", Tags: []string{"accounts", "transfers"}, Status: http.StatusCreated,
+				Errors: []string{"ledger.404.Account"}}},
+		{http.MethodGet, "/accounts/me", "router.getAccount", []string{"auth"},
+			Metadata{Auth: "jwt", Tags: []string{"accounts"}}},
+		{http.MethodGet, "/files/*", "router.listAccounts", []string{}, Metadata{}},
+	}
+
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %d routes sorted by path, got %+v", len(expected), routes)
+	}
+	for i, e := range expected {
+		var route = routes[i]
+		if route.Method != e.method || route.Path != e.path {
+			t.Errorf("route %d: expected %s %s, got %s %s", i, e.method, e.path, route.Method, route.Path)
+		}
+		if !strings.HasSuffix(route.Handler, e.handler) {
+			t.Errorf("%s %s: expected the handler %s, got %s", e.method, e.path, e.handler, route.Handler)
+		}
+		if !reflect.DeepEqual(route.Middlewares, e.middlewares) {
+			t.Errorf("%s %s: expected the middlewares %v, got %v", e.method, e.path, e.middlewares, route.Middlewares)
+		}
+		if !reflect.DeepEqual(route.Metadata, e.meta) {
+			t.Errorf("%s %s: expected the metadata %+v, got %+v", e.method, e.path, e.meta, route.Metadata)
+		}
+	}
+
+	if _, ok := r.Registry().Lookup(http.MethodGet, "/accounts/:id"); !ok {
+		t.Error("expected the route to be looked up by its echo path")
+	}
+	if _, ok := r.Registry().Lookup(http.MethodGet, "/accounts/42"); ok {
+		t.Error("expected a request path not to be looked up")
+	}
+
+	// Registering a method and path again replaces the route
+	accounts.GET("", getAccount)
+	if route, _ := r.Registry().Lookup(http.MethodGet, "/accounts"); !strings.HasSuffix(route.Handler, "getAccount") {
+		t.Errorf("expected the route to be replaced, got %s", route.Handler)
+	}
+	if n := len(r.Registry().Routes()); n != len(expected) {
+		t.Errorf("expected %d routes after replacing one, got %d", len(expected), n)
+	}
+}
+
+func TestRegistryMatch(t *testing.T) {
+	var r, _ = newTestRouter()
+	var accounts = r.NewGroup("/accounts")
+	accounts.GET("/:id", getAccount)
+	accounts.GET("/me", getAccount)
+	accounts.GET("/:id/transfers/:tid", getAccount)
+	r.NewGroup("/files").GET("/*", listAccounts)
+
+	var cases = []struct {
+		method, path string
+		route        string
+	}{
+		{http.MethodGet, "/accounts/me", "/accounts/me"},
+		{http.MethodGet, "/accounts/42", "/accounts/:id"},
+		{http.MethodGet, "/accounts/42/transfers/7", "/accounts/:id/transfers/:tid"},
+		{http.MethodGet, "/files/a/b.txt", "/files/*"},
+		{http.MethodGet, "/accounts/42/other", ""},
+		{http.MethodGet, "/accounts", ""},
+		{http.MethodPost, "/accounts/42", ""},
+	}
+
+	for _, c := range cases {
+		var route, ok = r.Registry().Match(c.method, c.path)
+		if ok != (c.route != "") || route.Path != c.route {
+			t.Errorf("%s %s: expected the route %q, got %q", c.method, c.path, c.route, route.Path)
+		}
+	}
+}
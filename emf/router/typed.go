@@ -0,0 +1,221 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/cambridge-blockchain/emf/emf/bind"
+	"github.com/cambridge-blockchain/emf/emf/codec"
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+var (
+	emfContextType = reflect.TypeOf((*context.EMFContext)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typedHandler calls a function shaped like func(ctx context.EMFContext, in *Req) (*Resp, error)
+type typedHandler struct {
+	fn     reflect.Value
+	in     reflect.Type
+	out    reflect.Type
+	status int
+}
+
+// TypedOption provides the client a callback that is used to dynamically specify attributes for a typed handler.
+type TypedOption func(*typedHandler)
+
+// WithStatus sets the status code of successful responses, 200 by default
+func WithStatus(status int) TypedOption {
+	return func(t *typedHandler) { t.status = status }
+}
+
+// Typed converts a function shaped like func(ctx context.EMFContext, in *Req) (*Resp, error) into a HandlerFunc.
+//
+// The request is bound into a new Req before every call: the body is decoded with the echo Binder, then
+// the fields tagged `param`, `query` and `header` are set from the path parameters, query string and headers.
+// Req is then checked with the echo Validator. Failures are returned as emf.400.InvalidParametersFailure,
+// emf.400.QueryParameterInvalid, emf.400.RequestBodyInvalid or emf.400.RequestValidationFailure EMFErrors.
+// The returned Resp is sent with the status code in the media type negotiated from the Accept header among the
+// codecs of codec.Default, JSON by default, or as 204 No Content when it is nil.
+//
+// Typed panics if f does not have the expected signature, so mistakes are caught when routes are registered.
+func Typed(f interface{}, opts ...TypedOption) HandlerFunc {
+	return newTypedHandler(f, opts...).handle
+}
+
+func newTypedHandler(f interface{}, opts ...TypedOption) *typedHandler {
+	var fn = reflect.ValueOf(f)
+	var t = fn.Type()
+
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 ||
+		t.In(0) != emfContextType || t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct ||
+		t.Out(1) != errorType {
+		panic(fmt.Sprintf("router: typed handler %T must be shaped like func(context.EMFContext, *Req) (Resp, error)", f))
+	}
+
+	var th = &typedHandler{fn: fn, in: t.In(1).Elem(), out: t.Out(0), status: http.StatusOK}
+	for _, opt := range opts {
+		opt(th)
+	}
+	return th
+}
+
+func (th *typedHandler) handle(ctx context.EMFContext) (err error) {
+	var in = reflect.New(th.in)
+
+	if err = th.bind(ctx, in.Interface()); err != nil {
+		return
+	}
+	if err = th.validate(ctx, in.Interface()); err != nil {
+		return
+	}
+
+	var results = th.fn.Call([]reflect.Value{reflect.ValueOf(ctx), in})
+	if errVal := results[1]; !errVal.IsNil() {
+		return errVal.Interface().(error)
+	}
+
+	var out = results[0]
+	switch out.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if out.IsNil() {
+			return ctx.NoContent(http.StatusNoContent)
+		}
+	}
+	return th.respond(ctx, out.Interface())
+}
+
+// respond encodes out with the codec negotiated from the Accept header, JSON being the default
+func (th *typedHandler) respond(ctx context.EMFContext, out interface{}) error {
+	var c, ok = codec.Default.Negotiate(ctx.Request().Header.Get(echo.HeaderAccept))
+	if !ok || c.ContentType() == codec.MIMEJSON {
+		return ctx.JSON(th.status, out)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, out); err != nil {
+		if errors.Is(err, codec.ErrUnsupportedType) {
+			return ctx.JSON(th.status, out)
+		}
+		return err
+	}
+	return ctx.Blob(th.status, c.ContentType(), buf.Bytes())
+}
+
+// bind decodes the body first, so that the path parameters, query string and headers take precedence
+func (th *typedHandler) bind(ctx context.EMFContext, in interface{}) (err error) {
+	if err = ctx.Bind(in); err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			err = fmt.Errorf("%v", he.Message)
+		}
+		return ctx.NewError("emf.400.RequestBodyInvalid", map[string]interface{}{
+			"Error": err,
+		})
+	}
+
+	var params = map[string][]string{}
+	for i, name := range ctx.ParamNames() {
+		params[name] = []string{ctx.ParamValues()[i]}
+	}
+	if err = bind.BindParams(in, params, "param"); err != nil {
+		return ctx.NewError("emf.400.InvalidParametersFailure", map[string]interface{}{
+			"Error": err,
+		})
+	}
+
+	if err = bind.BindParams(in, ctx.QueryParams(), "query"); err != nil {
+		var param = "-"
+		if pe, ok := err.(*bind.ParamError); ok {
+			param, err = pe.Param, pe.Err
+		}
+		return ctx.NewError("emf.400.QueryParameterInvalid", map[string]interface{}{
+			"Param": param,
+			"Error": err,
+		})
+	}
+
+	if err = bind.BindParams(in, ctx.Request().Header, "header"); err != nil {
+		return ctx.NewError("emf.400.InvalidParametersFailure", map[string]interface{}{
+			"Error": err,
+		})
+	}
+	return nil
+}
+
+func (th *typedHandler) validate(ctx context.EMFContext, in interface{}) (err error) {
+	if err = ctx.Validate(in); err == nil || err == echo.ErrValidatorNotRegistered {
+		return nil
+	}
+
+	var fields []string
+	if ves, ok := err.(validator.ValidationErrors); ok {
+		for _, fe := range ves {
+			var ns = fe.Namespace()
+			fields = append(fields, ns[strings.Index(ns, ".")+1:])
+		}
+	}
+	return ctx.NewError("emf.400.RequestValidationFailure", map[string]interface{}{
+		"Fields": strings.Join(fields, ", "),
+		"Error":  err,
+	})
+}
+
+// metadata describes the request and response types, and the errors that binding and validation may return
+func (th *typedHandler) metadata() Metadata {
+	var m = Metadata{
+		Request: reflect.New(th.in).Elem().Interface(),
+		Errors:  []string{"emf.400.RequestBodyInvalid", "emf.400.RequestValidationFailure"},
+	}
+	if th.out.Kind() != reflect.Interface {
+		m.Response = reflect.New(th.out).Elem().Interface()
+	}
+
+	var tags = fieldTags(th.in)
+	if tags["param"] || tags["header"] {
+		m.Errors = append(m.Errors, "emf.400.InvalidParametersFailure")
+	}
+	if tags["query"] {
+		m.Errors = append(m.Errors, "emf.400.QueryParameterInvalid")
+	}
+	return m
+}
+
+// fieldTags reports which of the param, query and header tags are used by the fields of t
+func fieldTags(t reflect.Type) map[string]bool {
+	var tags = map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for tag := range fieldTags(f.Type) {
+				tags[tag] = true
+			}
+		}
+		for _, tag := range []string{"param", "query", "header"} {
+			if f.Tag.Get(tag) != "" {
+				tags[tag] = true
+			}
+		}
+	}
+	return tags
+}
+
+// Handle registers a typed handler, as converted by Typed, for a method and path. The request and
+// response types and the binding errors are recorded in the route Metadata, and the status code of
+// successful responses is taken from the Metadata passed with Describe.
+func (g *Group) Handle(method, path string, f interface{}, middleware ...Middleware) *echo.Route {
+	var th = newTypedHandler(f)
+	var m = g.combine(append([]Middleware{Describe(th.metadata())}, middleware...))
+
+	if status := describe(m).Status; status != 0 {
+		th.status = status
+	}
+	return g.add(method, path, funcName(f), th.handle, m)
+}
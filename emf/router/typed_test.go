@@ -0,0 +1,192 @@
+package router
+
+import (
+	"bytes"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/cambridge-blockchain/emf/emf/bind"
+	"github.com/cambridge-blockchain/emf/emf/codec"
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+type structValidator struct {
+	validate *validator.Validate
+}
+
+func (v structValidator) Validate(i interface{}) error {
+	return v.validate.Struct(i)
+}
+
+// newTypedRouter returns a test Router binding and validating requests as the controller does,
+// which answers EMFErrors with their code
+func newTypedRouter() (*Router, *echo.Echo) {
+	var r, e = newTestRouter()
+	e.Binder = &bind.DefaultBinder{}
+	e.Validator = structValidator{validate: validator.New()}
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		var emfErr *errors.EMFErrorType
+		if stderrors.As(err, &emfErr) {
+			_ = c.String(emfErr.StatusCode, emfErr.ErrorCode)
+			return
+		}
+		_ = c.String(http.StatusInternalServerError, err.Error())
+	}
+	return r, e
+}
+
+type transferRequest struct {
+	Account string `param:"account"`
+	Limit   int    `query:"limit" validate:"max=100"`
+	Trace   string `header:"X-Trace"`
+	Amount  int64  `json:"amount" validate:"required,gt=0"`
+}
+
+type transferResponse struct {
+	Account string `json:"account"`
+	Limit   int    `json:"limit"`
+	Trace   string `json:"trace"`
+	Amount  int64  `json:"amount"`
+}
+
+func createTransfer(_ context.EMFContext, in *transferRequest) (*transferResponse, error) {
+	switch in.Amount {
+	case 1:
+		return nil, nil
+	case 13:
+		return nil, stderrors.New("ledger unavailable")
+	}
+	return &transferResponse{Account: in.Account, Limit: in.Limit, Trace: in.Trace, Amount: in.Amount}, nil
+}
+
+func TestTypedHandler(t *testing.T) {
+	var r, e = newTypedRouter()
+	r.NewGroup("/accounts").Handle(http.MethodPost, "/:account/transfers", createTransfer,
+		Describe(Metadata{Status: http.StatusCreated}))
+
+	var cases = []struct {
+		name   string
+		query  string
+		body   string
+		status int
+		result string
+	}{
+		{"bound from every source", "?limit=10", `{"amount":42}`, http.StatusCreated,
+			`{"account":"acc-1","limit":10,"trace":"t-1","amount":42}`},
+		{"invalid body", "", `{"amount":`, http.StatusBadRequest, "emf.400.RequestBodyInvalid"},
+		{"invalid query parameter", "?limit=ten", `{"amount":42}`, http.StatusBadRequest,
+			"emf.400.QueryParameterInvalid"},
+		{"invalid query value", "?limit=1000", `{"amount":42}`, http.StatusBadRequest,
+			"emf.400.RequestValidationFailure"},
+		{"invalid body value", "", `{"amount":0}`, http.StatusBadRequest, "emf.400.RequestValidationFailure"},
+		{"no response", "", `{"amount":1}`, http.StatusNoContent, ""},
+		{"handler error", "", `{"amount":13}`, http.StatusInternalServerError, "ledger unavailable"},
+	}
+
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodPost, "/accounts/acc-1/transfers"+c.query, strings.NewReader(c.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Trace", "t-1")
+		var rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != c.status || strings.TrimSpace(rec.Body.String()) != c.result {
+			t.Errorf("%s: expected %d %s, got %d %s", c.name, c.status, c.result, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestTypedHandlerNegotiation(t *testing.T) {
+	var r, e = newTypedRouter()
+	r.NewGroup("/accounts").Handle(http.MethodPost, "/:account/transfers", createTransfer)
+
+	var cases = []struct {
+		accept      string
+		contentType string
+	}{
+		{"", codec.MIMEJSON},
+		{codec.MIMEMessagePack, codec.MIMEMessagePack},
+		{codec.MIMECBOR + ", application/json;q=0.5", codec.MIMECBOR},
+		// Protocol Buffers cannot encode the response, which falls back on JSON
+		{codec.MIMEProtobuf, codec.MIMEJSON},
+	}
+
+	var expected = transferResponse{Account: "acc-1", Amount: 42}
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodPost, "/accounts/acc-1/transfers", strings.NewReader(`{"amount":42}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, c.accept)
+		var rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var contentType = rec.Header().Get(echo.HeaderContentType)
+		if rec.Code != http.StatusOK || !strings.HasPrefix(contentType, c.contentType) {
+			t.Errorf("%q: expected a 200 %s response, got %d %s", c.accept, c.contentType, rec.Code, contentType)
+			continue
+		}
+
+		var cd, _ = codec.Default.Negotiate(c.contentType)
+		var got transferResponse
+		if err := cd.Decode(bytes.NewReader(rec.Body.Bytes()), &got); err != nil || got != expected {
+			t.Errorf("%q: expected %+v, got %+v %v", c.accept, expected, got, err)
+		}
+	}
+}
+
+func TestTypedHandlerMetadata(t *testing.T) {
+	var r, _ = newTypedRouter()
+	r.NewGroup("/accounts").Handle(http.MethodPost, "/:account/transfers", createTransfer,
+		Describe(Metadata{Status: http.StatusCreated, Errors: []string{"ledger.404.AccountNotFound"}}))
+
+	var route, ok = r.Registry().Lookup(http.MethodPost, "/accounts/:account/transfers")
+	if !ok {
+		t.Fatal("expected the typed route to be registered")
+	}
+	if !strings.HasSuffix(route.Handler, "router.createTransfer") {
+		t.Errorf("expected the route to be named after the typed function, got %s", route.Handler)
+	}
+	if _, isRequest := route.Request.(transferRequest); !isRequest {
+		t.Errorf("expected the request type, got %T", route.Request)
+	}
+	if _, isResponse := route.Response.(*transferResponse); !isResponse {
+		t.Errorf("expected the response type, got %T", route.Response)
+	}
+	var errs = []string{"emf.400.RequestBodyInvalid", "emf.400.RequestValidationFailure",
+		"emf.400.InvalidParametersFailure", "emf.400.QueryParameterInvalid", "ledger.404.AccountNotFound"}
+	if route.Status != http.StatusCreated || !reflect.DeepEqual(route.Errors, errs) {
+		t.Errorf("expected the status and errors of the route, got %d %v", route.Status, route.Errors)
+	}
+}
+
+func TestTypedPanicsOnInvalidSignatures(t *testing.T) {
+	var cases = map[string]interface{}{
+		"not a function":     "createTransfer",
+		"missing context":    func(*transferRequest) (*transferResponse, error) { return nil, nil },
+		"request not a ptr":  func(context.EMFContext, transferRequest) (*transferResponse, error) { return nil, nil },
+		"request not struct": func(context.EMFContext, *string) (*transferResponse, error) { return nil, nil },
+		"no error":           func(context.EMFContext, *transferRequest) (*transferResponse, bool) { return nil, false },
+		"single result":      func(context.EMFContext, *transferRequest) error { return nil },
+	}
+
+	for name, f := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected Typed to panic", name)
+				}
+			}()
+			Typed(f)
+		}()
+	}
+
+	// Responses may be of any type, such as a slice
+	Typed(func(context.EMFContext, *transferRequest) ([]transferResponse, error) { return nil, nil })
+}
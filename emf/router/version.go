@@ -0,0 +1,167 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultVersionHeader is the request header read for the requested API version, as in "X-API-Version: 2"
+const DefaultVersionHeader = "X-API-Version"
+
+var (
+	versionSegment   = regexp.MustCompile(`^/v(\d+)(/|$)`)
+	versionMediaType = regexp.MustCompile(`^application/vnd\.[^;]+\.v(\d+)(\+[a-z]+)?$`)
+)
+
+// VersionedAPI serves several major versions of an API under one prefix, each registered as a Group on
+// prefix/vN. Requests are dispatched to a version by the version segment of their path, and otherwise by
+// the version header or a versioned Accept media type, such as application/vnd.emf.v2+json or
+// application/json; version=2. When none is given, the latest version is used.
+//
+// A request falls back on the latest version, no newer than the requested one, that serves its route,
+// so routes which did not change do not have to be registered again in every version.
+type VersionedAPI struct {
+	prefix string
+	header string
+	router *Router
+
+	mu       sync.RWMutex
+	versions []int
+}
+
+// VersionOption provides the client a callback that is used to dynamically specify attributes for a VersionedAPI.
+type VersionOption func(*VersionedAPI)
+
+// WithVersionHeader sets the request header read for the requested version, DefaultVersionHeader by default
+func WithVersionHeader(name string) VersionOption {
+	return func(api *VersionedAPI) { api.header = name }
+}
+
+// NewVersionedAPI creates a VersionedAPI serving the versions registered with Version under prefix
+func (r *Router) NewVersionedAPI(prefix string, opts ...VersionOption) *VersionedAPI {
+	var api = &VersionedAPI{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		header: DefaultVersionHeader,
+		router: r,
+	}
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	r.Pre(api.dispatch)
+	return api
+}
+
+// Version creates the Group of a major version, served on prefix/vN with optional version-level middleware.
+// Pass Deprecate to deprecate the whole version.
+func (api *VersionedAPI) Version(major int, m ...Middleware) *Group {
+	api.mu.Lock()
+	var i = sort.SearchInts(api.versions, major)
+	if i == len(api.versions) || api.versions[i] != major {
+		api.versions = append(api.versions, 0)
+		copy(api.versions[i+1:], api.versions[i:])
+		api.versions[i] = major
+	}
+	api.mu.Unlock()
+
+	return api.router.NewGroup(api.versionPrefix(major), m...)
+}
+
+// Versions returns the registered major versions, in ascending order
+func (api *VersionedAPI) Versions() []int {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return append([]int(nil), api.versions...)
+}
+
+func (api *VersionedAPI) versionPrefix(major int) string {
+	return api.prefix + "/v" + strconv.Itoa(major)
+}
+
+// dispatch rewrites the path of a request to the version that serves it, before echo routes it
+func (api *VersionedAPI) dispatch(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req = c.Request()
+		var p = req.URL.Path
+		if p != api.prefix && !strings.HasPrefix(p, api.prefix+"/") {
+			return next(c)
+		}
+
+		var versions = api.Versions()
+		if len(versions) == 0 {
+			return next(c)
+		}
+
+		var (
+			rest      = p[len(api.prefix):]
+			from      = api.prefix
+			requested = versions[len(versions)-1]
+		)
+		if m := versionSegment.FindStringSubmatch(rest); m != nil {
+			requested, _ = strconv.Atoi(m[1])
+			from += rest[:len(m[0])-len(m[2])]
+			rest = rest[len(m[0])-len(m[2]):]
+		} else {
+			c.Response().Header().Add(echo.HeaderVary, "Accept, "+api.header)
+			if v, ok := api.requestedVersion(req); ok {
+				requested = v
+			}
+		}
+
+		// Serve the request from the latest version that is no newer than the requested one and has its route
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i] > requested {
+				continue
+			}
+			var to = api.versionPrefix(versions[i])
+			if _, ok := api.router.registry.Match(req.Method, to+rest); ok {
+				rewritePath(req, from, to)
+				c.Response().Header().Set(api.header, strconv.Itoa(versions[i]))
+				break
+			}
+		}
+		return next(c)
+	}
+}
+
+// requestedVersion reads the version header, then the Accept header
+func (api *VersionedAPI) requestedVersion(req *http.Request) (int, bool) {
+	if h := strings.TrimPrefix(strings.TrimSpace(req.Header.Get(api.header)), "v"); h != "" {
+		if v, err := strconv.Atoi(h); err == nil {
+			return v, true
+		}
+	}
+
+	for _, accept := range strings.Split(req.Header.Get(echo.HeaderAccept), ",") {
+		var params = strings.Split(accept, ";")
+		if m := versionMediaType.FindStringSubmatch(strings.TrimSpace(params[0])); m != nil {
+			if v, err := strconv.Atoi(m[1]); err == nil {
+				return v, true
+			}
+		}
+		for _, param := range params[1:] {
+			var kv = strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "version") {
+				if v, err := strconv.Atoi(strings.TrimPrefix(strings.Trim(kv[1], `"`), "v")); err == nil {
+					return v, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// rewritePath replaces the from prefix of the request path with to
+func rewritePath(req *http.Request, from, to string) {
+	req.URL.Path = to + strings.TrimPrefix(req.URL.Path, from)
+	if req.URL.RawPath != "" {
+		req.URL.RawPath = to + strings.TrimPrefix(req.URL.RawPath, from)
+	}
+}
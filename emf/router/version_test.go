@@ -0,0 +1,174 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// answer returns a handler answering with body and the id path parameter, if any
+func answer(body string) HandlerFunc {
+	return func(ctx context.EMFContext) error {
+		return ctx.String(http.StatusOK, body+ctx.Param("id"))
+	}
+}
+
+func TestVersionedAPI(t *testing.T) {
+	var since, sunset = time.Unix(1700000000, 0), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var r, e = newTestRouter()
+	var api = r.NewVersionedAPI("/api")
+	// Versions may be registered in any order
+	api.Version(3).GET("/transfers", answer("v3 transfers"))
+	var v1 = api.Version(1, Deprecate(Deprecation{Since: since, Sunset: sunset, Link: "https://docs.example.com/v2"}))
+	v1.GET("/accounts/:id", answer("v1 account "))
+	v1.GET("/rates", answer("v1 rates"))
+	api.Version(2).GET("/accounts/:id", answer("v2 account "))
+
+	if got := api.Versions(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("expected the sorted versions, got %v", got)
+	}
+
+	var cases = []struct {
+		name    string
+		path    string
+		header  map[string]string
+		status  int
+		body    string
+		version string
+	}{
+		{"version segment", "/api/v1/accounts/42", nil, http.StatusOK, "v1 account 42", "1"},
+		{"other version segment", "/api/v2/accounts/42", nil, http.StatusOK, "v2 account 42", "2"},
+		{"latest version serving the route", "/api/accounts/42", nil, http.StatusOK, "v2 account 42", "2"},
+		{"latest version", "/api/transfers", nil, http.StatusOK, "v3 transfers", "3"},
+		{"version header", "/api/accounts/42", map[string]string{DefaultVersionHeader: "1"}, http.StatusOK,
+			"v1 account 42", "1"},
+		{"prefixed version header", "/api/accounts/42", map[string]string{DefaultVersionHeader: "v1"},
+			http.StatusOK, "v1 account 42", "1"},
+		{"versioned media type", "/api/accounts/42", map[string]string{"Accept": "application/vnd.emf.v1+json"},
+			http.StatusOK, "v1 account 42", "1"},
+		{"version parameter", "/api/accounts/42", map[string]string{"Accept": "application/json; version=1"},
+			http.StatusOK, "v1 account 42", "1"},
+		{"route of an older version", "/api/v2/rates", nil, http.StatusOK, "v1 rates", "1"},
+		{"route of a newer version", "/api/v1/transfers", nil, http.StatusNotFound, "", ""},
+		{"outside of the prefix", "/apix/v1/accounts/42", nil, http.StatusNotFound, "", ""},
+	}
+
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodGet, c.path, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		var rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != c.status || (c.body != "" && rec.Body.String() != c.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", c.name, c.status, c.body, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get(DefaultVersionHeader); got != c.version {
+			t.Errorf("%s: expected the served version %q, got %q", c.name, c.version, got)
+		}
+
+		// Unknown routes under the prefix of a deprecated version are answered by its group, with its headers
+		var deprecated = c.version == "1" || strings.HasPrefix(c.path, "/api/v1/")
+		if got := rec.Header().Get(HeaderDeprecation); deprecated != (got == "@1700000000") {
+			t.Errorf("%s: expected deprecated: %v, got the Deprecation header %q", c.name, deprecated, got)
+		}
+		if deprecated {
+			if got := rec.Header().Get(HeaderSunset); got != "Tue, 01 Jan 2030 00:00:00 GMT" {
+				t.Errorf("%s: expected the Sunset header, got %q", c.name, got)
+			}
+			if got := rec.Header().Get(HeaderLink); got != `<https://docs.example.com/v2>; rel="deprecation"` {
+				t.Errorf("%s: expected the deprecation Link header, got %q", c.name, got)
+			}
+		}
+	}
+
+	// Responses negotiated from headers vary with them
+	var rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/accounts/42", nil))
+	if got := rec.Header().Get(echo.HeaderVary); got != "Accept, "+DefaultVersionHeader {
+		t.Errorf("expected the Vary header, got %q", got)
+	}
+}
+
+func TestVersionHeaderOption(t *testing.T) {
+	var r, e = newTestRouter()
+	var api = r.NewVersionedAPI("/api", WithVersionHeader("Api-Version"))
+	api.Version(1).GET("/accounts", answer("v1"))
+	api.Version(2).GET("/accounts", answer("v2"))
+
+	var req = httptest.NewRequest(http.MethodGet, "/api/accounts", nil)
+	req.Header.Set("Api-Version", "1")
+	req.Header.Set(DefaultVersionHeader, "2")
+	var rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "v1" || rec.Header().Get("Api-Version") != "1" {
+		t.Errorf("expected the configured header to select the version, got %q %v", rec.Body, rec.Header())
+	}
+}
+
+func TestDeprecate(t *testing.T) {
+	var r, e = newTestRouter()
+	r.NewGroup("/rates").GET("", answer("rates"), Deprecate(Deprecation{}))
+
+	if route, _ := r.Registry().Lookup(http.MethodGet, "/rates"); !route.Deprecated {
+		t.Error("expected the route to be deprecated in the registry")
+	}
+
+	var counter = deprecatedRequests.WithLabelValues(http.MethodGet, "/rates", "okhttp")
+	var before = testutil.ToFloat64(counter)
+
+	var req = httptest.NewRequest(http.MethodGet, "/rates", nil)
+	req.Header.Set("User-Agent", "okhttp/4.9.0")
+	var rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(HeaderDeprecation); got != "true" {
+		t.Errorf("expected the Deprecation header without a date, got %q", got)
+	}
+	if got := rec.Header().Get(HeaderSunset) + rec.Header().Get(HeaderLink); got != "" {
+		t.Errorf("expected no Sunset or Link header, got %q", got)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("expected the request to be counted once, got %v", got)
+	}
+}
+
+func TestDefaultCaller(t *testing.T) {
+	var verified = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "ledger"}},
+	}}}
+
+	var cases = []struct {
+		name   string
+		agent  string
+		tls    *tls.ConnectionState
+		caller string
+	}{
+		{"client certificate", "okhttp/4.9.0", verified, "ledger"},
+		{"user agent", "okhttp/4.9.0 (Android)", nil, "okhttp"},
+		{"unknown", "", nil, "unknown"},
+	}
+
+	for _, c := range cases {
+		var req = httptest.NewRequest(http.MethodGet, "/rates", nil)
+		req.Header.Set("User-Agent", c.agent)
+		req.TLS = c.tls
+		if got := DefaultCaller(echo.New().NewContext(req, httptest.NewRecorder())); got != c.caller {
+			t.Errorf("%s: expected %q, got %q", c.name, c.caller, got)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -18,6 +19,7 @@ type Option func(*Server)
 // Server is a robust server that can be easily be spun up and shut down with well implemented error handeling.
 type Server struct {
 	endpoint *http.Server
+	admin    *http.Server
 }
 
 // WithServer creates an Option that is used for specifying the http.Server for a Server.
@@ -25,6 +27,20 @@ func WithServer(serv *http.Server) Option {
 	return func(s *Server) { s.endpoint = serv }
 }
 
+// WithAdminServer creates an Option that is used for specifying a second http.Server for operational
+// endpoints. Both listeners are started and stopped together.
+func WithAdminServer(serv *http.Server) Option {
+	return func(s *Server) { s.admin = serv }
+}
+
+// servers returns every configured listener, the API server first
+func (s *Server) servers() []*http.Server {
+	if s.admin == nil {
+		return []*http.Server{s.endpoint}
+	}
+	return []*http.Server{s.endpoint, s.admin}
+}
+
 // New is a variadic constructor for a Server.
 func New(opts ...Option) *Server {
 	var s = &Server{
@@ -48,18 +64,73 @@ func (s *Server) Startup() (quit chan bool) {
 	sigs = make(chan os.Signal, 1)
 	quit = make(chan bool, 1)
 
-	signal.Notify(sigs, os.Interrupt)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		s.Shutdown(<-sigs, quit)
 	}()
 
-	if err = s.endpoint.ListenAndServe(); err != nil {
+	if err = s.Serve(); err != nil {
 		panic(fmt.Errorf("failed to start server with error: '%s'", err))
 	}
 	return quit
 }
 
+// Serve listens and serves on every listener until the server is stopped. If one listener fails, the
+// others are stopped too and the first failure is returned. A graceful shutdown is not reported as an error.
+func (s *Server) Serve() (err error) {
+	var (
+		servers = s.servers()
+		errs    = make(chan error, len(servers))
+	)
+
+	for _, serv := range servers {
+		go func(serv *http.Server) {
+			if serveErr := listenAndServe(serv); serveErr != http.ErrServerClosed {
+				errs <- fmt.Errorf("failed to serve on '%s': %w", serv.Addr, serveErr)
+				return
+			}
+			errs <- nil
+		}(serv)
+	}
+
+	for range servers {
+		if serveErr := <-errs; serveErr != nil && err == nil {
+			err = serveErr
+			go s.Stop(context.Background()) //nolint:errcheck // the listener failure is reported instead
+		}
+	}
+	return err
+}
+
+// listenAndServe serves over TLS when the http.Server has a TLSConfig, its certificates are provided by
+// the TLSConfig itself
+func listenAndServe(serv *http.Server) error {
+	if serv.TLSConfig != nil {
+		return serv.ListenAndServeTLS("", "")
+	}
+	return serv.ListenAndServe()
+}
+
+// Stop gracefully shuts every listener down, waiting for in-flight requests until ctx expires.
+func (s *Server) Stop(ctx context.Context) (err error) {
+	var (
+		servers = s.servers()
+		errs    = make(chan error, len(servers))
+	)
+
+	for _, serv := range servers {
+		go func(serv *http.Server) { errs <- serv.Shutdown(ctx) }(serv)
+	}
+
+	for range servers {
+		if stopErr := <-errs; stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
 // Shutdown stops the server gracefully.
 func (s *Server) Shutdown(signal os.Signal, done chan bool) {
 	var err error
@@ -69,7 +140,7 @@ func (s *Server) Shutdown(signal os.Signal, done chan bool) {
 
 	defer cancel()
 
-	if err = s.endpoint.Shutdown(ctx); err != nil {
+	if err = s.Stop(ctx); err != nil {
 		panic(fmt.Errorf("failed to shutdown server with error: '%s'", err))
 	}
 
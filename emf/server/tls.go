@@ -0,0 +1,213 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long to wait for further changes before reloading the TLS files
+const reloadDelay = 500 * time.Millisecond
+
+// TLSConfig holds the file paths and client authentication mode used to serve over TLS
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// ClientAuth is one of none, request, require, verify_if_given or require_and_verify.
+	// Defaults to require_and_verify when ClientCAFile is set, and none otherwise.
+	ClientAuth string
+}
+
+// ParseClientAuth converts a client_auth config value to a tls.ClientAuthType
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown TLS client_auth mode '%s'", mode)
+	}
+}
+
+// CertReloader serves the certificate, key and client CA files configured in a TLSConfig,
+// and reloads them when they change on disk. The last valid files keep being served if a reload fails.
+type CertReloader struct {
+	conf TLSConfig
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	cas  *x509.CertPool
+
+	watcher *fsnotify.Watcher
+}
+
+// NewCertReloader loads the files of a TLSConfig
+func NewCertReloader(conf TLSConfig) (cr *CertReloader, err error) {
+	cr = &CertReloader{conf: conf}
+
+	if err = cr.Reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Reload reads the certificate, key and client CA files again
+func (cr *CertReloader) Reload() (err error) {
+	var (
+		cert tls.Certificate
+		cas  *x509.CertPool
+		pem  []byte
+	)
+
+	if cert, err = tls.LoadX509KeyPair(cr.conf.CertFile, cr.conf.KeyFile); err != nil {
+		return fmt.Errorf("failed to load TLS certificate '%s' and key '%s': %w", cr.conf.CertFile, cr.conf.KeyFile, err)
+	}
+
+	if cr.conf.ClientCAFile != "" {
+		if pem, err = ioutil.ReadFile(cr.conf.ClientCAFile); err != nil {
+			return fmt.Errorf("failed to read TLS client CA file '%s': %w", cr.conf.ClientCAFile, err)
+		}
+		cas = x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS client CA file '%s'", cr.conf.ClientCAFile)
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert, cr.cas = &cert, cas
+	return nil
+}
+
+// TLSConfig builds a tls.Config that always uses the latest loaded certificate and client CAs
+func (cr *CertReloader) TLSConfig() (conf *tls.Config, err error) {
+	var mode = cr.conf.ClientAuth
+	if mode == "" {
+		mode = "none"
+		if cr.conf.ClientCAFile != "" {
+			mode = "require_and_verify"
+		}
+	}
+
+	conf = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Set explicitly, as the per-connection config below is cloned before net/http adds h2 to it
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cr.mu.RLock()
+			defer cr.mu.RUnlock()
+			return cr.cert, nil
+		},
+	}
+	if conf.ClientAuth, err = ParseClientAuth(mode); err != nil {
+		return nil, err
+	}
+	if conf.ClientAuth >= tls.VerifyClientCertIfGiven && cr.conf.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLS client_auth mode '%s' requires a client CA file", mode)
+	}
+
+	conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cr.mu.RLock()
+		defer cr.mu.RUnlock()
+
+		var c = conf.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = cr.cas
+		return c, nil
+	}
+	return conf, nil
+}
+
+// Watch reloads the files whenever they change on disk, until Close is called. The parent directories are
+// watched rather than the files, so atomic renames and Kubernetes secret updates are picked up too.
+// onError is called with reload failures and may be nil.
+func (cr *CertReloader) Watch(onError func(error)) (err error) {
+	var files = map[string]bool{}
+	var dirs = map[string]bool{}
+
+	for _, f := range []string{cr.conf.CertFile, cr.conf.KeyFile, cr.conf.ClientCAFile} {
+		if f != "" {
+			files[filepath.Clean(f)] = true
+			dirs[filepath.Dir(f)] = true
+		}
+	}
+
+	if cr.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return fmt.Errorf("failed to watch the TLS files: %w", err)
+	}
+	for dir := range dirs {
+		if err = cr.watcher.Add(dir); err != nil {
+			cr.watcher.Close()
+			return fmt.Errorf("failed to watch the TLS directory '%s': %w", dir, err)
+		}
+	}
+
+	var reload = func() {
+		if reloadErr := cr.Reload(); reloadErr != nil && onError != nil {
+			onError(reloadErr)
+		}
+	}
+
+	go func(w *fsnotify.Watcher) {
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					if debounce != nil {
+						debounce.Stop()
+					}
+					return
+				}
+				if !files[filepath.Clean(event.Name)] && !isSymlinkSwap(event.Name) {
+					continue
+				}
+				// Certificate and key are usually written one after the other, wait for both
+				if debounce == nil {
+					debounce = time.AfterFunc(reloadDelay, reload)
+				} else {
+					debounce.Reset(reloadDelay)
+				}
+			case watchErr, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				if onError != nil {
+					onError(watchErr)
+				}
+			}
+		}
+	}(cr.watcher)
+
+	return nil
+}
+
+// Close stops watching the files
+func (cr *CertReloader) Close() error {
+	if cr.watcher == nil {
+		return nil
+	}
+	return cr.watcher.Close()
+}
+
+// isSymlinkSwap reports whether an event is Kubernetes replacing the ..data symlink of a mounted secret
+func isSymlinkSwap(name string) bool {
+	var fi, err = os.Lstat(name)
+	return filepath.Base(name) == "..data" && err == nil && fi.Mode()&os.ModeSymlink != 0
+}
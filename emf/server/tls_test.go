@@ -0,0 +1,335 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// identity is a certificate and its key, signed by a CA identity or self-signed
+type identity struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newIdentity(t *testing.T, cn string, ca *identity) *identity {
+	var key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmpl = &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	var parent, signer = tmpl, key
+	if ca == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+
+	var der []byte
+	if der, err = x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer); err != nil {
+		t.Fatal(err)
+	}
+	var id = &identity{key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	if id.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	var keyDER []byte
+	if keyDER, err = x509.MarshalECPrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	id.keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return id
+}
+
+func (id *identity) tlsCertificate(t *testing.T) *tls.Certificate {
+	var cert, err = tls.X509KeyPair(id.certPEM, id.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cert
+}
+
+// tlsFiles holds the files of a TLSConfig in a temporary directory
+type tlsFiles struct {
+	t   *testing.T
+	dir string
+}
+
+func newTLSFiles(t *testing.T) *tlsFiles {
+	var dir, err = ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tlsFiles{t: t, dir: dir}
+}
+
+func (f *tlsFiles) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+func (f *tlsFiles) write(name string, data []byte) {
+	if err := ioutil.WriteFile(f.path(name), data, 0600); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *tlsFiles) serve(id *identity) {
+	f.write("tls.crt", id.certPEM)
+	f.write("tls.key", id.keyPEM)
+}
+
+func (f *tlsFiles) config(clientCA bool, clientAuth string) TLSConfig {
+	var conf = TLSConfig{CertFile: f.path("tls.crt"), KeyFile: f.path("tls.key"), ClientAuth: clientAuth}
+	if clientCA {
+		conf.ClientCAFile = f.path("ca.crt")
+	}
+	return conf
+}
+
+// handshake completes a TLS handshake with a server using conf, presenting cert when it is not nil.
+// It returns the common name of the server certificate, and the error of either side.
+func handshake(t *testing.T, conf *tls.Config, cert *tls.Certificate) (string, error) {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var served = make(chan error, 1)
+	go func() {
+		var conn, err = l.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		defer conn.Close()
+		served <- tls.Server(conn, conf).Handshake()
+	}()
+
+	var client = &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		// Present the certificate even when the server does not list its CA as acceptable
+		client.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return cert, nil }
+	}
+	var conn *tls.Conn
+	if conn, err = tls.Dial("tcp", l.Addr().String(), client); err != nil {
+		<-served
+		return "", err
+	}
+	defer conn.Close()
+
+	if err = <-served; err != nil {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestParseClientAuth(t *testing.T) {
+	var cases = map[string]tls.ClientAuthType{
+		"none":               tls.NoClientCert,
+		"request":            tls.RequestClientCert,
+		"REQUIRE":            tls.RequireAnyClientCert,
+		"verify_if_given":    tls.VerifyClientCertIfGiven,
+		"require_and_verify": tls.RequireAndVerifyClientCert,
+	}
+	for mode, expected := range cases {
+		if got, err := ParseClientAuth(mode); err != nil || got != expected {
+			t.Errorf("%s: expected %v, got %v %v", mode, expected, got, err)
+		}
+	}
+	if _, err := ParseClientAuth("optional"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	var files = newTLSFiles(t)
+	defer os.RemoveAll(files.dir)
+	var ca = newIdentity(t, "ca", nil)
+	files.serve(newIdentity(t, "first", ca))
+
+	var cr, err = NewCertReloader(files.config(false, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf *tls.Config
+	if conf, err = cr.TLSConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var failures []error
+	if err = cr.Watch(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, err)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer cr.Close()
+
+	if cn, err := handshake(t, conf, nil); err != nil || cn != "first" {
+		t.Fatalf("expected the first certificate, got %q %v", cn, err)
+	}
+
+	// New handshakes see the rewritten certificate without a restart
+	files.serve(newIdentity(t, "second", ca))
+	var cn string
+	for deadline := time.Now().Add(5 * time.Second); cn != "second" && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		if cn, err = handshake(t, conf, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cn != "second" {
+		t.Fatalf("expected the rewritten certificate to be served, got %q", cn)
+	}
+
+	// An invalid certificate is reported and the last valid one is kept
+	files.write("tls.crt", []byte("invalid"))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		mu.Lock()
+		var failed = len(failures) > 0
+		mu.Unlock()
+		if failed {
+			break
+		}
+	}
+	mu.Lock()
+	if len(failures) == 0 {
+		t.Error("expected the invalid certificate to be reported")
+	}
+	mu.Unlock()
+	if cn, err = handshake(t, conf, nil); err != nil || cn != "second" {
+		t.Errorf("expected the last valid certificate to be kept, got %q %v", cn, err)
+	}
+}
+
+func TestCertReloaderClientCA(t *testing.T) {
+	var files = newTLSFiles(t)
+	defer os.RemoveAll(files.dir)
+	var first, second = newIdentity(t, "first-ca", nil), newIdentity(t, "second-ca", nil)
+	files.serve(newIdentity(t, "server", first))
+	files.write("ca.crt", first.certPEM)
+
+	var cr, err = NewCertReloader(files.config(true, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf *tls.Config
+	if conf, err = cr.TLSConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("expected client certificates to be required and verified by default, got %v", conf.ClientAuth)
+	}
+
+	var firstClient = newIdentity(t, "ledger", first).tlsCertificate(t)
+	var secondClient = newIdentity(t, "ledger", second).tlsCertificate(t)
+
+	if _, err = handshake(t, conf, firstClient); err != nil {
+		t.Errorf("expected a client of the first CA to be accepted, got %v", err)
+	}
+	if _, err = handshake(t, conf, secondClient); err == nil {
+		t.Error("expected a client of the second CA to be rejected")
+	}
+
+	// The reloaded CAs are used by the config of each new connection
+	files.write("ca.crt", second.certPEM)
+	if err = cr.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = handshake(t, conf, firstClient); err == nil {
+		t.Error("expected a client of the replaced CA to be rejected")
+	}
+	if _, err = handshake(t, conf, secondClient); err != nil {
+		t.Errorf("expected a client of the new CA to be accepted, got %v", err)
+	}
+
+	files.write("ca.crt", []byte("invalid"))
+	if err = cr.Reload(); err == nil {
+		t.Error("expected a CA file without certificates to be reported")
+	}
+	if _, err = handshake(t, conf, secondClient); err != nil {
+		t.Errorf("expected the last valid CAs to be kept, got %v", err)
+	}
+}
+
+func TestTLSConfigClientAuth(t *testing.T) {
+	var files = newTLSFiles(t)
+	defer os.RemoveAll(files.dir)
+	var ca = newIdentity(t, "ca", nil)
+	files.serve(newIdentity(t, "server", ca))
+	files.write("ca.crt", ca.certPEM)
+
+	var trusted = newIdentity(t, "ledger", ca).tlsCertificate(t)
+	var untrusted = newIdentity(t, "ledger", newIdentity(t, "other-ca", nil)).tlsCertificate(t)
+
+	var cases = []struct {
+		mode     string
+		clientCA bool
+		// whether a handshake without a certificate, with an untrusted one and with a trusted one succeeds
+		none, untrusted, trusted bool
+	}{
+		{"", false, true, true, true},
+		{"none", true, true, true, true},
+		{"request", false, true, true, true},
+		{"require", false, false, true, true},
+		{"verify_if_given", true, true, false, true},
+		{"require_and_verify", true, false, false, true},
+	}
+
+	for _, c := range cases {
+		var cr, err = NewCertReloader(files.config(c.clientCA, c.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var conf *tls.Config
+		if conf, err = cr.TLSConfig(); err != nil {
+			t.Errorf("%q: %v", c.mode, err)
+			continue
+		}
+
+		for _, h := range []struct {
+			name string
+			cert *tls.Certificate
+			ok   bool
+		}{{"no certificate", nil, c.none}, {"untrusted", untrusted, c.untrusted}, {"trusted", trusted, c.trusted}} {
+			if _, err = handshake(t, conf, h.cert); h.ok != (err == nil) {
+				t.Errorf("%q, %s: expected success: %v, got %v", c.mode, h.name, h.ok, err)
+			}
+		}
+	}
+
+	for _, mode := range []string{"optional", "verify_if_given", "require_and_verify"} {
+		var cr, err = NewCertReloader(files.config(false, mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = cr.TLSConfig(); err == nil {
+			t.Errorf("%q: expected the mode to be rejected without a client CA file", mode)
+		}
+	}
+}
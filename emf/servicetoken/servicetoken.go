@@ -0,0 +1,208 @@
+// Package servicetoken mints short-lived JWTs identifying a component to the components it calls,
+// for requests made without a user token, such as background jobs and notifications.
+package servicetoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+	"github.com/cambridge-blockchain/emf/emf/keys"
+)
+
+const (
+	// RolePrefix is the prefix of the rol claim of service tokens, such as "svc-notification"
+	RolePrefix = "svc"
+
+	defaultTTL = 5 * time.Minute
+)
+
+// Minter signs service tokens for a component with its private key. Tokens are cached per audience
+// and minted again once half of their lifetime has elapsed.
+type Minter struct {
+	component string
+	key       crypto.PrivateKey
+	method    jwt.SigningMethod
+	kid       string
+	ttl       time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+type token struct {
+	raw     string
+	renewAt time.Time
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Minter.
+type Option func(*Minter)
+
+// WithKeyID sets the kid header of the tokens, the component name by default. Receiving services select the
+// verification key by this kid, which must be the component name or start with it and a colon, such as
+// "ledger:2024-01", as checked by KeyOf.
+func WithKeyID(kid string) Option {
+	return func(m *Minter) {
+		if kid != "" {
+			m.kid = kid
+		}
+	}
+}
+
+// WithAlgorithm sets the signing algorithm, chosen from the key type by default:
+// RS256 for RSA keys, ES256, ES384 or ES512 for ECDSA keys by curve, and EdDSA for Ed25519 keys
+func WithAlgorithm(alg string) Option {
+	return func(m *Minter) {
+		if method := jwt.GetSigningMethod(alg); method != nil {
+			m.method = method
+		}
+	}
+}
+
+// WithTTL sets the lifetime of the tokens, 5 minutes by default
+func WithTTL(d time.Duration) Option {
+	return func(m *Minter) {
+		if d > 0 {
+			m.ttl = d
+		}
+	}
+}
+
+// New is a variadic constructor for a Minter signing tokens for component with key
+func New(component string, key crypto.PrivateKey, opts ...Option) (m *Minter, err error) {
+	if component == "" {
+		return nil, fmt.Errorf("missing component name for service tokens")
+	}
+
+	m = &Minter{
+		component: component,
+		key:       key,
+		kid:       component,
+		ttl:       defaultTTL,
+		tokens:    map[string]token{},
+	}
+	if m.method, err = defaultMethod(key); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if !KeyOf(m.kid, component) {
+		return nil, fmt.Errorf("service token key id '%s' must be '%s' or start with '%s:'", m.kid, component, component)
+	}
+	if _, err = jwt.New(m.method).SignedString(m.key); err != nil {
+		return nil, fmt.Errorf("service token key cannot sign %s tokens: %w", m.method.Alg(), err)
+	}
+	return m, nil
+}
+
+// NewFromFile is a variadic constructor for a Minter signing tokens with the PEM private key stored at path
+func NewFromFile(component, path string, opts ...Option) (*Minter, error) {
+	var data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service token key: %w", err)
+	}
+
+	var key crypto.PrivateKey
+	if key, err = ParsePrivateKeyPEM(data); err != nil {
+		return nil, fmt.Errorf("invalid service token key '%s': %w", path, err)
+	}
+	return New(component, key, opts...)
+}
+
+// Token returns a token for requests to the audience component
+func (m *Minter) Token(audience string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var now = time.Now()
+	if t, ok := m.tokens[audience]; ok && now.Before(t.renewAt) {
+		return t.raw, nil
+	}
+
+	var jti = make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	var t = jwt.NewWithClaims(m.method, jwt.MapClaims{
+		"iss":                m.component,
+		"sub":                m.component,
+		"aud":                audience,
+		"iat":                now.Unix(),
+		"nbf":                now.Unix(),
+		"exp":                now.Add(m.ttl).Unix(),
+		"jti":                hex.EncodeToString(jti),
+		"rol":                RolePrefix + "-" + m.component,
+		"tgt":                m.component,
+		context.ServiceClaim: m.component,
+	})
+	t.Header["kid"] = m.kid
+
+	var raw, err = t.SignedString(m.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service token: %w", err)
+	}
+
+	m.tokens[audience] = token{raw: raw, renewAt: now.Add(m.ttl / 2)}
+	return raw, nil
+}
+
+// KeyOf reports whether kid identifies a service token key of component: the component name itself, or the
+// component name followed by a colon and a version
+func KeyOf(kid, component string) bool {
+	return component != "" && (kid == component || strings.HasPrefix(kid, component+":"))
+}
+
+// ParsePrivateKeyPEM parses a PKCS #8, PKCS #1 RSA or SEC 1 EC private key
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	var block, _ = pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+func defaultMethod(key crypto.PrivateKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported curve '%s'", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return keys.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
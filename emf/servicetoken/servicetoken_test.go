@@ -0,0 +1,200 @@
+package servicetoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/cambridge-blockchain/emf/emf/context"
+)
+
+func ecKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	var key, err = ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyOf(t *testing.T) {
+	var cases = []struct {
+		kid, component string
+		expected       bool
+	}{
+		{"ledger", "ledger", true},
+		{"ledger:2", "ledger", true},
+		{"ledger:2024-01", "ledger", true},
+		{"ledger2", "ledger", false},
+		{"ledger-2", "ledger", false},
+		{"notification", "ledger", false},
+		{"", "", false},
+		{":2", "", false},
+	}
+
+	for _, c := range cases {
+		if got := KeyOf(c.kid, c.component); got != c.expected {
+			t.Errorf("KeyOf(%q, %q): expected %v, got %v", c.kid, c.component, c.expected, got)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	var rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _, edKey, _ = ed25519.GenerateKey(rand.Reader)
+
+	var cases = []struct {
+		name      string
+		component string
+		key       crypto.PrivateKey
+		opts      []Option
+		alg       string
+		ok        bool
+	}{
+		{"RSA", "ledger", rsaKey, nil, "RS256", true},
+		{"P-256", "ledger", ecKey(t, elliptic.P256()), nil, "ES256", true},
+		{"P-384", "ledger", ecKey(t, elliptic.P384()), nil, "ES384", true},
+		{"Ed25519", "ledger", edKey, nil, "EdDSA", true},
+		{"algorithm", "ledger", rsaKey, []Option{WithAlgorithm("PS256")}, "PS256", true},
+		{"versioned kid", "ledger", rsaKey, []Option{WithKeyID("ledger:2")}, "RS256", true},
+		{"missing component", "", rsaKey, nil, "", false},
+		{"unsupported key", "ledger", "secret", nil, "", false},
+		{"unsupported curve", "ledger", ecKey(t, elliptic.P224()), nil, "", false},
+		{"kid of another component", "ledger", rsaKey, []Option{WithKeyID("notification")}, "", false},
+		{"algorithm of another key type", "ledger", rsaKey, []Option{WithAlgorithm("ES256")}, "", false},
+	}
+
+	for _, c := range cases {
+		var m, err = New(c.component, c.key, c.opts...)
+		if !c.ok {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if m.method.Alg() != c.alg {
+			t.Errorf("%s: expected %s, got %s", c.name, c.alg, m.method.Alg())
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	var key = ecKey(t, elliptic.P256())
+	var m, err = New("notification", key, WithKeyID("notification:2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	if raw, err = m.Token("ledger"); err != nil {
+		t.Fatal(err)
+	}
+
+	var claims = jwt.MapClaims{}
+	var parsed *jwt.Token
+	if parsed, err = jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Header["kid"] != "notification:2" {
+		t.Errorf("expected the kid notification:2, got %v", parsed.Header["kid"])
+	}
+	var expected = map[string]string{
+		"iss":                "notification",
+		"sub":                "notification",
+		"aud":                "ledger",
+		"rol":                "svc-notification",
+		context.ServiceClaim: "notification",
+	}
+	for claim, value := range expected {
+		if claims[claim] != value {
+			t.Errorf("expected the %s claim %q, got %v", claim, value, claims[claim])
+		}
+	}
+	if exp := int64(claims["exp"].(float64)); exp-time.Now().Unix() > int64(defaultTTL.Seconds()) {
+		t.Errorf("expected the token to expire within %s, got exp %d", defaultTTL, exp)
+	}
+}
+
+func TestTokenCaching(t *testing.T) {
+	var m, err = New("notification", ecKey(t, elliptic.P256()), WithTTL(40*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first, _ = m.Token("ledger")
+	if second, _ := m.Token("ledger"); second != first {
+		t.Error("expected the token to be cached")
+	}
+	if other, _ := m.Token("kyc"); other == first {
+		t.Error("expected a token per audience")
+	}
+
+	// Tokens are renewed once half of their lifetime has elapsed
+	time.Sleep(25 * time.Millisecond)
+	if renewed, _ := m.Token("ledger"); renewed == first {
+		t.Error("expected the token to be renewed")
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	var rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ec = ecKey(t, elliptic.P256())
+	var sec1, _ = x509.MarshalECPrivateKey(ec)
+	var pkcs8, _ = x509.MarshalPKCS8PrivateKey(ec)
+
+	var cases = []struct {
+		name string
+		pem  []byte
+		ok   bool
+	}{
+		{"PKCS #1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			true},
+		{"SEC 1", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), true},
+		{"PKCS #8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), true},
+		{"not PEM", []byte("secret"), false},
+		{"invalid key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("secret")}), false},
+	}
+
+	for _, c := range cases {
+		var f, err = ioutil.TempFile("", "servicetoken")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.Write(c.pem)
+		f.Close()
+
+		var _, newErr = NewFromFile("ledger", f.Name())
+		os.Remove(f.Name())
+
+		if c.ok && newErr != nil {
+			t.Errorf("%s: %v", c.name, newErr)
+		} else if !c.ok && newErr == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+
+	if _, err = NewFromFile("ledger", "testdata/missing.pem"); err == nil {
+		t.Error("expected a missing key file to be reported")
+	}
+}
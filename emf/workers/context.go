@@ -0,0 +1,22 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext exposes the values of a request context, while its deadline and cancellation
+// come from the Pool, so a job is not cancelled when the request that submitted it completes.
+type detachedContext struct {
+	values    context.Context
+	lifecycle context.Context
+}
+
+func detach(values, lifecycle context.Context) context.Context {
+	return detachedContext{values: values, lifecycle: lifecycle}
+}
+
+func (d detachedContext) Deadline() (time.Time, bool)       { return d.lifecycle.Deadline() }
+func (d detachedContext) Done() <-chan struct{}             { return d.lifecycle.Done() }
+func (d detachedContext) Err() error                        { return d.lifecycle.Err() }
+func (d detachedContext) Value(key interface{}) interface{} { return d.values.Value(key) }
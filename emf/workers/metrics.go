@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusRejected  = "rejected"
+)
+
+// Metrics exposed on /metrics when monitoring.prometheus is enabled
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "emf",
+		Subsystem: "workers",
+		Name:      "queue_depth",
+		Help:      "Number of jobs waiting for a worker.",
+	})
+	activeWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "emf",
+		Subsystem: "workers",
+		Name:      "active",
+		Help:      "Number of jobs being processed.",
+	})
+	jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "emf",
+		Subsystem: "workers",
+		Name:      "jobs_total",
+		Help:      "Number of jobs by outcome: succeeded, failed or rejected because the queue was full.",
+	}, []string{"status"})
+	jobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "emf",
+		Subsystem: "workers",
+		Name:      "job_duration_seconds",
+		Help:      "Time taken to process a job.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
@@ -0,0 +1,354 @@
+// Package workers runs background jobs on a fixed number of goroutines fed by a bounded queue.
+// Jobs keep the request-scoped values and request ID of the request that submitted them, and get a
+// RequestHandler built from the config of its own, and the queue is drained before the service exits.
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	emfcontext "github.com/cambridge-blockchain/emf/emf/context"
+)
+
+// Defaults for a Pool
+const (
+	DefaultQueueSize = 5000
+	DefaultHeartbeat = 15 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned when a job is submitted while the queue is at capacity
+	ErrQueueFull = errors.New("workers: the job queue is full")
+	// ErrStopped is returned when a job is submitted to a Pool that is not running
+	ErrStopped = errors.New("workers: the pool is not running")
+)
+
+// propagated are the headers of the submitting request sent by the RequestHandler of its jobs
+var propagated = []string{
+	echo.HeaderXRequestID,
+	"X-Datadog-Trace-Id",
+	"X-Datadog-Parent-Id",
+	"X-Datadog-Sampling-Priority",
+}
+
+// Handler processes a single job
+type Handler func(jc *JobContext) error
+
+// JobContext is passed to a Handler. It carries the values of the submitting request, but is only
+// cancelled when the Pool fails to drain in time, so jobs may outlive the request that submitted them.
+type JobContext struct {
+	context.Context
+	Name           string
+	RequestID      string
+	RequestHandler emfcontext.RequestHandler
+}
+
+// Logger is a helper function to expose the logger of the submitting request
+func (jc *JobContext) Logger() echo.Logger {
+	return jc.RequestHandler.Logger()
+}
+
+// Stats is a snapshot of the state of a Pool
+type Stats struct {
+	Workers   int    `json:"workers"`
+	Capacity  int    `json:"capacity"`
+	Queued    int    `json:"queued"`
+	Active    int64  `json:"active"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	Rejected  uint64 `json:"rejected"`
+}
+
+// Option provides the client a callback that is used to dynamically specify attributes for a Pool.
+type Option func(*Pool)
+
+// WithWorkers sets the number of goroutines processing jobs. Defaults to the number of CPUs.
+func WithWorkers(n int) Option {
+	return func(p *Pool) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets the number of jobs that can wait for a worker before submissions are rejected
+func WithQueueSize(n int) Option {
+	return func(p *Pool) {
+		if n > 0 {
+			p.capacity = n
+		}
+	}
+}
+
+// WithHeartbeat sets how often the Pool logs its Stats and updates its metrics
+func WithHeartbeat(d time.Duration) Option {
+	return func(p *Pool) {
+		if d > 0 {
+			p.heartbeat = d
+		}
+	}
+}
+
+// WithLogger is used to specify the Logger for the heartbeat and for failed jobs
+func WithLogger(logger echo.Logger) Option {
+	return func(p *Pool) { p.logger = logger }
+}
+
+type job struct {
+	jc      *JobContext
+	handler Handler
+}
+
+// Pool is a bounded queue of jobs processed by a fixed number of workers
+type Pool struct {
+	workers   int
+	capacity  int
+	heartbeat time.Duration
+	logger    echo.Logger
+
+	mu      sync.RWMutex
+	running bool
+	stopped bool
+	queue   chan job
+	wg      sync.WaitGroup
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	active    int64
+	succeeded uint64
+	failed    uint64
+	rejected  uint64
+}
+
+// New is a variadic constructor for a Pool. The Pool accepts jobs once Start is called.
+func New(opts ...Option) *Pool {
+	var p = &Pool{
+		workers:   runtime.NumCPU(),
+		capacity:  DefaultQueueSize,
+		heartbeat: DefaultHeartbeat,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.queue = make(chan job, p.capacity)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	return p
+}
+
+// Start launches the workers and the heartbeat. A stopped Pool cannot be restarted.
+func (p *Pool) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrStopped
+	}
+	if p.running {
+		return nil
+	}
+	p.running = true
+	p.done = make(chan struct{})
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	go p.beat(p.done)
+
+	return nil
+}
+
+// Stop rejects new jobs and waits for the queued and running jobs to finish.
+// If ctx is done first, the context of the remaining jobs is cancelled and an error is returned.
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return nil
+	}
+	p.running = false
+	p.stopped = true
+	close(p.queue)
+	close(p.done)
+	p.mu.Unlock()
+
+	var drained = make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return fmt.Errorf("workers: %d job(s) did not finish before the shutdown timeout: %w",
+			len(p.queue)+int(atomic.LoadInt64(&p.active)), ctx.Err())
+	}
+}
+
+// Submit queues a job that keeps the request ID and values of the request. Its RequestHandler is built from
+// the config of the request's RequestHandler, without the request's Authorization and other headers, so
+// its requests are authenticated with service tokens. It returns an emf.503.WorkerQueueFull EMFError when
+// the queue is at capacity.
+func (p *Pool) Submit(c emfcontext.EMFContext, name string, h Handler) error {
+	var jc = &JobContext{
+		Context:        detach(c.Request().Context(), p.ctx),
+		Name:           name,
+		RequestID:      c.GetRequestID(),
+		RequestHandler: detachHandler(c.GetRequestHandler()),
+	}
+
+	if err := p.enqueue(job{jc: jc, handler: h}); err != nil {
+		if err == ErrQueueFull {
+			return c.NewError("emf.503.WorkerQueueFull", map[string]interface{}{
+				"Job":       name,
+				"QueueSize": p.capacity,
+			}, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Enqueue queues a job that is not tied to a request, using rh for its logger and outgoing requests.
+// It returns ErrQueueFull when the queue is at capacity, and ErrStopped when the Pool is not running.
+func (p *Pool) Enqueue(rh emfcontext.RequestHandler, name string, h Handler) error {
+	return p.enqueue(job{
+		jc: &JobContext{
+			Context:        p.ctx,
+			Name:           name,
+			RequestHandler: rh,
+		},
+		handler: h,
+	})
+}
+
+// detachHandler returns a RequestHandler for a job from the RequestHandler of the submitting request, only
+// copying the headers listed in propagated. RequestHandlers that cannot be detached are used as they are.
+func detachHandler(rh emfcontext.RequestHandler) emfcontext.RequestHandler {
+	var d, ok = rh.(interface {
+		Detach(header http.Header) *emfcontext.RequestHandlerType
+	})
+	if !ok {
+		return rh
+	}
+
+	var header = http.Header{}
+	for _, k := range propagated {
+		if v := rh.Header().Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+	return d.Detach(header)
+}
+
+func (p *Pool) enqueue(j job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.running {
+		return ErrStopped
+	}
+
+	select {
+	case p.queue <- j:
+		queueDepth.Set(float64(len(p.queue)))
+		return nil
+	default:
+		atomic.AddUint64(&p.rejected, 1)
+		jobsTotal.WithLabelValues(statusRejected).Inc()
+		return ErrQueueFull
+	}
+}
+
+// Stats returns a snapshot of the state of the Pool
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:   p.workers,
+		Capacity:  p.capacity,
+		Queued:    len(p.queue),
+		Active:    atomic.LoadInt64(&p.active),
+		Succeeded: atomic.LoadUint64(&p.succeeded),
+		Failed:    atomic.LoadUint64(&p.failed),
+		Rejected:  atomic.LoadUint64(&p.rejected),
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for j := range p.queue {
+		queueDepth.Set(float64(len(p.queue)))
+		p.run(j)
+	}
+}
+
+func (p *Pool) run(j job) {
+	var start = time.Now()
+
+	atomic.AddInt64(&p.active, 1)
+	activeWorkers.Inc()
+	defer func() {
+		atomic.AddInt64(&p.active, -1)
+		activeWorkers.Dec()
+		jobDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	if err := p.call(j); err != nil {
+		atomic.AddUint64(&p.failed, 1)
+		jobsTotal.WithLabelValues(statusFailed).Inc()
+		if j.jc.RequestHandler != nil {
+			j.jc.Logger().Errorf("request_id=%s | job '%s' failed: %s", j.jc.RequestID, j.jc.Name, err)
+		} else if p.logger != nil {
+			p.logger.Errorf("job '%s' failed: %s", j.jc.Name, err)
+		}
+		return
+	}
+
+	atomic.AddUint64(&p.succeeded, 1)
+	jobsTotal.WithLabelValues(statusSucceeded).Inc()
+}
+
+// call runs the job handler, turning a panic into an error so one job cannot take down a worker
+func (p *Pool) call(j job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.handler(j.jc)
+}
+
+func (p *Pool) beat(done chan struct{}) {
+	var ticker = time.NewTicker(p.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var s = p.Stats()
+			queueDepth.Set(float64(s.Queued))
+			if p.logger != nil {
+				p.logger.Infof("workers heartbeat | workers=%d queued=%d/%d active=%d succeeded=%d failed=%d rejected=%d",
+					s.Workers, s.Queued, s.Capacity, s.Active, s.Succeeded, s.Failed, s.Rejected)
+			}
+		}
+	}
+}
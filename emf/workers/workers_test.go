@@ -0,0 +1,170 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+
+	emfcontext "github.com/cambridge-blockchain/emf/emf/context"
+	emferrors "github.com/cambridge-blockchain/emf/emf/context/errors"
+)
+
+// newContext returns the context of a request whose RequestHandler sends header, as set by the middlewares
+func newContext(header map[string]string) emfcontext.EMFContext {
+	var req = httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	var ctx = emfcontext.NewEMFContext(echo.New().NewContext(req, httptest.NewRecorder()), viper.New())
+	for k, v := range header {
+		ctx.Header().Set(k, v)
+	}
+	ctx.Response().Header().Set(echo.HeaderXRequestID, header[echo.HeaderXRequestID])
+	return ctx
+}
+
+func startPool(t *testing.T, opts ...Option) *Pool {
+	var p = New(opts...)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	var p = startPool(t, WithWorkers(1), WithQueueSize(1))
+	var ctx = newContext(nil)
+
+	var started, release = make(chan struct{}), make(chan struct{})
+	var blocking = func(*JobContext) error {
+		close(started)
+		<-release
+		return nil
+	}
+	var noop = func(*JobContext) error { return nil }
+
+	if err := p.Submit(ctx, "blocking", blocking); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := p.Submit(ctx, "queued", noop); err != nil {
+		t.Fatal(err)
+	}
+
+	var err = p.Submit(ctx, "rejected", noop)
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	if !errors.Is(err, emferrors.ErrorType("emf.503.WorkerQueueFull")) {
+		t.Errorf("expected an emf.503.WorkerQueueFull EMFError, got %v", err)
+	}
+	if s := p.Stats(); s.Rejected != 1 || s.Queued != 1 {
+		t.Errorf("expected 1 queued and 1 rejected job, got %+v", s)
+	}
+
+	close(release)
+	if err = p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Succeeded != 2 {
+		t.Errorf("expected the queued job to run before Stop returned, got %+v", s)
+	}
+	if err = p.Submit(ctx, "stopped", noop); err != ErrStopped {
+		t.Errorf("expected ErrStopped after Stop, got %v", err)
+	}
+}
+
+func TestStopDrain(t *testing.T) {
+	var cases = []struct {
+		name    string
+		job     time.Duration
+		timeout time.Duration
+		ok      bool
+	}{
+		{"drained", 0, time.Second, true},
+		{"timed out", time.Second, 10 * time.Millisecond, false},
+	}
+
+	for _, c := range cases {
+		var p = startPool(t, WithWorkers(2), WithQueueSize(10))
+
+		var done int64
+		var cancelled = make(chan struct{}, 10)
+		for i := 0; i < 10; i++ {
+			var err = p.Enqueue(nil, c.name, func(jc *JobContext) error {
+				select {
+				case <-time.After(c.job):
+					atomic.AddInt64(&done, 1)
+				case <-jc.Done():
+					cancelled <- struct{}{}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), c.timeout)
+		var err = p.Stop(ctx)
+		cancel()
+
+		if c.ok && (err != nil || atomic.LoadInt64(&done) != 10) {
+			t.Errorf("%s: expected the 10 jobs to finish, got %d and %v", c.name, atomic.LoadInt64(&done), err)
+		}
+		if !c.ok {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.name)
+			}
+			// The running jobs see their context cancelled
+			<-cancelled
+		}
+	}
+}
+
+func TestSubmitDetachesRequestHandler(t *testing.T) {
+	var p = startPool(t, WithWorkers(1))
+	var ctx = newContext(map[string]string{
+		echo.HeaderAuthorization: "Bearer user-token",
+		echo.HeaderXRequestID:    "request-1",
+		"X-Datadog-Trace-Id":     "42",
+		"X-Custom":               "value",
+	})
+
+	var header = make(chan http.Header, 1)
+	var requestID = make(chan string, 1)
+	var err = p.Submit(ctx, "detached", func(jc *JobContext) error {
+		header <- jc.RequestHandler.Header()
+		requestID <- jc.RequestID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got = <-header
+	var expected = map[string]string{
+		echo.HeaderAuthorization: "",
+		echo.HeaderXRequestID:    "request-1",
+		"X-Datadog-Trace-Id":     "42",
+		"X-Custom":               "",
+	}
+	for k, v := range expected {
+		if got.Get(k) != v {
+			t.Errorf("expected the job header %s to be %q, got %q", k, v, got.Get(k))
+		}
+	}
+	if id := <-requestID; id != "request-1" {
+		t.Errorf("expected the request ID of the request, got %q", id)
+	}
+	if ctx.Header().Get(echo.HeaderAuthorization) == "" {
+		t.Error("expected the request's RequestHandler to keep its headers")
+	}
+}
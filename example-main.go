@@ -3,6 +3,7 @@ package main
 // This file is for demonstration purposes only to show how emf should be used to initialize a service
 
 import (
+	"fmt"
 	"os"
 
 	emf "github.com/cambridge-blockchain/emf/emf"
@@ -12,21 +13,30 @@ import (
 
 func main() {
 	configFile := os.Getenv("CONFIG")
-	emfController := emf.New(configFile,
-		models.BuildConfig{
+	emfController, err := emf.NewController(
+		emf.WithConfigFile(configFile),
+		emf.WithBuildConfig(models.BuildConfig{
 			Version:          "v1.0.0",
 			ReleaseTimestamp: "now",
 			Component:        "xxx-component",
 			Build:            "ef45432b78291",
-		}, []notifications.NotificationType{},
+		}),
+		emf.WithNotificationCodes([]notifications.NotificationType{}),
 	)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	emfController.GetLogger().Info("Server starting...")
 
 	emfController.GetMiddlewares().UseMiddlewares(emfController.GetRouter())
 
-	quit := emfController.GetServer().Startup()
-	<-quit
+	// Blocks until SIGINT or SIGTERM, then drains in-flight requests and background work
+	if err = emfController.Run(); err != nil {
+		emfController.GetLogger().Errorf("Server stopped with error: %s", err)
+		os.Exit(1)
+	}
 
 	emfController.GetLogger().Info("Server dead, quitting...")
 }
@@ -47,6 +47,9 @@ type RequestHandler = context.RequestHandler
 // RequestHandlerType is the subset of a Context used for HTTP Requests
 type RequestHandlerType = context.RequestHandlerType
 
+// BackgroundTracker starts goroutines that must finish before the service shuts down
+type BackgroundTracker = context.BackgroundTracker
+
 // ConfigReader defines the interface for read-only config file access
 type ConfigReader = configurer.ConfigReader
 
@@ -65,6 +68,12 @@ type Middleware = router.Middleware
 // RouterGroup is the type of the echo Router Group
 type RouterGroup = router.Group
 
+// RouteMetadata is the optional documentation attached to routes with DescribeRoute
+type RouteMetadata = router.Metadata
+
+// DescribeRoute attaches RouteMetadata to the routes or groups it is passed to
+var DescribeRoute = router.Describe
+
 // DataPoint represents a data point value
 type DataPoint = configurer.DataPoint
 
@@ -44,18 +44,28 @@ type NotificationPayload struct {
 }
 
 //Send creates a new instantiation of the given notification type with the supplied metadata and sends it
+// in the background. The goroutine is tracked by the RequestHandler when it is a BackgroundTracker, so it is
+// drained on shutdown.
 func (n NotificationType) Send(rh models.RequestHandler, to, from string, mdFields ...NotificationMDField) {
-	go func(rh models.RequestHandler, payload NotificationPayload) {
-		if err := sendNotification(rh, payload); err != nil {
-			rh.Logger().Debugf("Error sending notification payload %+v\nErr: %s", n, err.Error())
-		}
-	}(rh, NotificationPayload{
+	var payload = NotificationPayload{
 		ToEntity:   to,
 		FromEntity: from,
 		Template:   n.Template,
 		Metadata:   notificationFieldsToMap(mdFields),
 		Code:       n.Code,
-	})
+	}
+
+	var send = func() {
+		if err := sendNotification(rh, payload); err != nil {
+			rh.Logger().Debugf("Error sending notification payload %+v\nErr: %s", n, err.Error())
+		}
+	}
+
+	if tracker, ok := rh.(models.BackgroundTracker); ok {
+		tracker.Go(send)
+		return
+	}
+	go send()
 }
 
 //notificationFieldsToMap is responsible for converting the list of NotificationMDFields into a map[string]string